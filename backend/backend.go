@@ -7,21 +7,69 @@ import (
 type Backend interface {
 	// State returns the current state.
 	//
-	// If the named state doesn't exist it will be created.
+	// If the named state doesn't exist ErrStateNotFound is returned.
 	State(name string) (state.State, error)
 
+	// StateExists reports whether the named state has a stored
+	// configuration.
+	StateExists(name string) (bool, error)
+
 	// DeleteState removes the named state if it exists.
 	//
 	// DeleteState does not prevent deleting a state that is in use.
 	DeleteState(name string) error
 
-	// PersistState persist the given state.
+	// PersistState persist the given state and keeps a copy of it as
+	// a new version in the state's history.
+	//
+	// If the stored configuration no longer matches the state's Revision,
+	// nothing is written and a *ConflictError is returned.
 	PersistState(state state.State) error
 
 	// States returns a list of configured named states.
 	States() ([]string, error)
 
+	// History returns the versions of the named state's configuration kept
+	// by PersistState, oldest first. The last entry is the current
+	// configuration.
+	History(name string) ([]HistoryEntry, error)
+
+	// HistoryState returns the named state's configuration at the given
+	// version.
+	HistoryState(name string, version int) (state.State, error)
+
+	// PersistHistoryState replaces the state's configuration at the given
+	// version of its history, e.g. to re-encrypt its secrets. The version
+	// must already exist.
+	PersistHistoryState(state state.State, version int) error
+
 	// StateTerraformConfig returns the path and object that
 	// represents a terraform backend configuration
 	StateTerraformConfig(name string) (string, interface{})
+
+	// TerraformEnv returns the environment variables terraform is run with
+	// for the backend configuration from StateTerraformConfig, e.g. KEY=value
+	// for credentials that aren't stored in the configuration.
+	TerraformEnv() []string
+
+	// TerraformState returns the terraform state stored by the terraform
+	// backend configuration from StateTerraformConfig.
+	//
+	// If terraform hasn't stored a state yet, nil is returned.
+	TerraformState(name string) ([]byte, error)
+
+	// PersistTerraformState replaces the named state's terraform state.
+	PersistTerraformState(name string, content []byte) error
+
+	// Lock acquires a lease on the named state's configuration for the
+	// duration of a command. An expired lease held by someone else is
+	// taken over.
+	//
+	// If the lease is held by someone else a *LockError is returned.
+	Lock(name string, info LockInfo) error
+
+	// Unlock releases the lease with the given ID on the named state.
+	//
+	// Unlock does nothing if the named state isn't locked.
+	Unlock(name, id string) error
 }
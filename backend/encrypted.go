@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+// encryptedBackend encrypts the secrets of every state before it is persisted.
+// States are returned as stored, secrets are only decrypted to run terraform.
+type encryptedBackend struct {
+	Backend
+
+	key *state.SecretKey
+}
+
+// WithSecretEncryption returns a Backend that encrypts secrets with key before persisting a state.
+func WithSecretEncryption(remoteBackend Backend, key *state.SecretKey) Backend {
+	return encryptedBackend{
+		Backend: remoteBackend,
+		key:     key,
+	}
+}
+
+func (b encryptedBackend) PersistState(currentState state.State) error {
+	// Encrypt a copy, the caller keeps using its state
+	encryptedState, err := state.New(currentState.Name, currentState.Bytes())
+	if err != nil {
+		return err
+	}
+	encryptedState.Revision = currentState.Revision
+
+	err = encryptedState.EncryptSecrets(b.key)
+	if err != nil {
+		return err
+	}
+
+	return b.Backend.PersistState(encryptedState)
+}
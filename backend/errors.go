@@ -0,0 +1,20 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStateNotFound is returned by Backend.State when the named state has no
+// stored configuration.
+var ErrStateNotFound = errors.New("state not found")
+
+// ConflictError is returned by Backend.PersistState when the stored configuration
+// changed since the state was read.
+type ConflictError struct {
+	Name string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("Cluster manager '%s' was modified by someone else, please re-run the command.", e.Name)
+}
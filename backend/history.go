@@ -0,0 +1,11 @@
+package backend
+
+import (
+	"time"
+)
+
+// HistoryEntry is a version of a cluster manager's configuration kept by PersistState.
+type HistoryEntry struct {
+	Version  int
+	Modified time.Time
+}
@@ -1,9 +1,15 @@
 package local
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/state"
@@ -16,6 +22,8 @@ const (
 	rootPathFormat            = rootDirectory + "/%s"
 	terraformConfigPathFormat = rootDirectory + "/%s/main.tf.json"
 	terraformStatePathFormat  = rootDirectory + "/%s/terraform.tfstate"
+	lockPathFormat            = rootDirectory + "/%s/lock.json"
+	historyPathFormat         = rootDirectory + "/%s/history"
 )
 
 type localBackend struct {
@@ -48,21 +56,25 @@ func (backend localBackend) State(name string) (state.State, error) {
 		return state.State{}, err
 	}
 
-	_, err = os.Stat(expandedTerraformConfigPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return state.New(name, []byte("{}"))
-		}
+	return readState(expandedTerraformConfigPath, name)
+}
 
-		return state.State{}, err
+func (backend localBackend) StateExists(name string) (bool, error) {
+	terraformConfigPath := fmt.Sprintf(terraformConfigPathFormat, name)
+	expandedTerraformConfigPath, err := homedir.Expand(terraformConfigPath)
+	if err != nil {
+		return false, err
 	}
 
-	content, err := ioutil.ReadFile(expandedTerraformConfigPath)
+	_, err = os.Stat(expandedTerraformConfigPath)
 	if err != nil {
-		return state.State{}, err
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
 	}
 
-	return state.New(name, content)
+	return true, nil
 }
 
 func (backend localBackend) DeleteState(name string) error {
@@ -91,12 +103,31 @@ func (backend localBackend) PersistState(state state.State) error {
 		return err
 	}
 
+	// Refuse to overwrite a configuration that changed since it was read
+	err = checkRevision(expandedTerraformConfigPath, state.Name, state.Revision)
+	if err != nil {
+		return err
+	}
+
+	// The configuration being replaced starts the history if there is none yet
+	previousContent, err := ioutil.ReadFile(expandedTerraformConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	err = ioutil.WriteFile(expandedTerraformConfigPath, state.Bytes(), 0644)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Keep the new configuration as the next version in the history, only once it's stored
+	historyPath := fmt.Sprintf(historyPathFormat, state.Name)
+	expandedHistoryPath, err := homedir.Expand(historyPath)
+	if err != nil {
+		return err
+	}
+
+	return appendHistory(expandedHistoryPath, previousContent, state.Bytes())
 }
 
 func (backend localBackend) States() ([]string, error) {
@@ -110,9 +141,14 @@ func (backend localBackend) States() ([]string, error) {
 		return nil, err
 	}
 
+	// Only directories with a configuration are cluster managers, others hold e.g. caches
 	states := []string{}
 	for _, f := range files {
-		if f.IsDir() {
+		if !f.IsDir() {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(expandedRootDirectory, f.Name(), "main.tf.json"))
+		if err == nil {
 			states = append(states, f.Name())
 		}
 	}
@@ -120,6 +156,59 @@ func (backend localBackend) States() ([]string, error) {
 	return states, nil
 }
 
+func (backend localBackend) History(name string) ([]backend.HistoryEntry, error) {
+	historyPath := fmt.Sprintf(historyPathFormat, name)
+	expandedHistoryPath, err := homedir.Expand(historyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return readHistory(expandedHistoryPath)
+}
+
+func (backend localBackend) HistoryState(name string, version int) (state.State, error) {
+	historyPath := fmt.Sprintf(historyPathFormat, name)
+	expandedHistoryPath, err := homedir.Expand(historyPath)
+	if err != nil {
+		return state.State{}, err
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(expandedHistoryPath, fmt.Sprintf("%d.json", version)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state.State{}, fmt.Errorf("Version %d of cluster manager '%s' does not exist.", version, name)
+		}
+		return state.State{}, err
+	}
+
+	return state.Load(name, content)
+}
+
+func (backend localBackend) PersistHistoryState(state state.State, version int) error {
+	historyPath := fmt.Sprintf(historyPathFormat, state.Name)
+	expandedHistoryPath, err := homedir.Expand(historyPath)
+	if err != nil {
+		return err
+	}
+
+	versionPath := filepath.Join(expandedHistoryPath, fmt.Sprintf("%d.json", version))
+	info, err := os.Stat(versionPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Version %d of cluster manager '%s' does not exist.", version, state.Name)
+		}
+		return err
+	}
+
+	err = ioutil.WriteFile(versionPath, state.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	// The history lists when a version was stored, not when it was last rewritten
+	return os.Chtimes(versionPath, info.ModTime(), info.ModTime())
+}
+
 func (backend localBackend) StateTerraformConfig(name string) (string, interface{}) {
 	terraformStatePath := fmt.Sprintf(terraformStatePathFormat, name)
 	expandedTerraformStatePath, _ := homedir.Expand(terraformStatePath)
@@ -130,3 +219,274 @@ func (backend localBackend) StateTerraformConfig(name string) (string, interface
 
 	return "terraform.backend.local", terraformBackendConfig
 }
+
+func (backend localBackend) TerraformEnv() []string {
+	return nil
+}
+
+func (backend localBackend) TerraformState(name string) ([]byte, error) {
+	terraformStatePath := fmt.Sprintf(terraformStatePathFormat, name)
+	expandedTerraformStatePath, err := homedir.Expand(terraformStatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(expandedTerraformStatePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return content, nil
+}
+
+func (backend localBackend) PersistTerraformState(name string, content []byte) error {
+	rootPath := fmt.Sprintf(rootPathFormat, name)
+	expandedRootPath, err := homedir.Expand(rootPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(expandedRootPath, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	terraformStatePath := fmt.Sprintf(terraformStatePathFormat, name)
+	expandedTerraformStatePath, err := homedir.Expand(terraformStatePath)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(expandedTerraformStatePath, content, 0644)
+}
+
+func (backend localBackend) Lock(name string, info backend.LockInfo) error {
+	rootPath := fmt.Sprintf(rootPathFormat, name)
+	expandedRootPath, err := homedir.Expand(rootPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(expandedRootPath, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	lockPath := fmt.Sprintf(lockPathFormat, name)
+	expandedLockPath, err := homedir.Expand(lockPath)
+	if err != nil {
+		return err
+	}
+
+	return createLockFile(expandedLockPath, name, info)
+}
+
+func (backend localBackend) Unlock(name, id string) error {
+	lockPath := fmt.Sprintf(lockPathFormat, name)
+	expandedLockPath, err := homedir.Expand(lockPath)
+	if err != nil {
+		return err
+	}
+
+	existingLock, err := readLockFile(expandedLockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if existingLock.ID != id {
+		return fmt.Errorf("Lock ID '%s' does not match the current lock '%s' held by %s", id, existingLock.ID, existingLock.Owner)
+	}
+
+	err = os.Remove(expandedLockPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Remove the cluster manager directory if the lock was the only thing in it,
+	// e.g. a manager creation that was canceled before its state was persisted.
+	rootPath := fmt.Sprintf(rootPathFormat, name)
+	expandedRootPath, err := homedir.Expand(rootPath)
+	if err != nil {
+		return err
+	}
+	os.Remove(expandedRootPath)
+
+	return nil
+}
+
+func readState(path, name string) (state.State, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state.State{}, backend.ErrStateNotFound
+		}
+		return state.State{}, err
+	}
+
+	currentState, err := state.Load(name, content)
+	if err != nil {
+		return state.State{}, err
+	}
+	currentState.Revision = contentRevision(content)
+
+	return currentState, nil
+}
+
+// History versions are stored as `history/{version}.json`
+func readHistory(historyPath string) ([]backend.HistoryEntry, error) {
+	files, err := ioutil.ReadDir(historyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []backend.HistoryEntry{}, nil
+		}
+		return nil, err
+	}
+
+	entries := []backend.HistoryEntry{}
+	for _, f := range files {
+		version, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil || f.IsDir() {
+			continue
+		}
+
+		entries = append(entries, backend.HistoryEntry{
+			Version:  version,
+			Modified: f.ModTime(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Version < entries[j].Version
+	})
+
+	return entries, nil
+}
+
+// appendHistory stores content as the next version. If there is no history yet,
+// previousContent, the configuration content replaced, is kept as the first version.
+func appendHistory(historyPath string, previousContent, content []byte) error {
+	err := os.MkdirAll(historyPath, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readHistory(historyPath)
+	if err != nil {
+		return err
+	}
+
+	nextVersion := 1
+	if len(entries) > 0 {
+		nextVersion = entries[len(entries)-1].Version + 1
+	} else if previousContent != nil {
+		err = ioutil.WriteFile(filepath.Join(historyPath, "1.json"), previousContent, 0644)
+		if err != nil {
+			return err
+		}
+		nextVersion = 2
+	}
+
+	return ioutil.WriteFile(filepath.Join(historyPath, fmt.Sprintf("%d.json", nextVersion)), content, 0644)
+}
+
+// O_EXCL guarantees only one process can create the lock file.
+// If an expired lock is found it is moved aside and creation is attempted once more.
+func createLockFile(path, name string, info backend.LockInfo) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		lockFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = lockFile.Write(info.Bytes())
+			if closeErr := lockFile.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+
+		existingLock, err := readLockFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Released in the meantime
+				continue
+			}
+			return err
+		}
+
+		if !existingLock.Expired() {
+			return &backend.LockError{Name: name, Info: existingLock}
+		}
+
+		err = removeExpiredLockFile(path, name, existingLock, info.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("Could not acquire lock for cluster manager '%s'", name)
+}
+
+// removeExpiredLockFile moves the expired lock aside with a rename, which only one of
+// several processes taking it over at the same time can do. If the lock moved aside
+// isn't the expired one, someone else took it over since it was read and it is put back.
+func removeExpiredLockFile(path, name string, expiredLock backend.LockInfo, id string) error {
+	expiredPath := fmt.Sprintf("%s.%s", path, id)
+	err := os.Rename(path, expiredPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer os.Remove(expiredPath)
+
+	movedLock, err := readLockFile(expiredPath)
+	if err != nil {
+		return err
+	}
+	if movedLock.ID != expiredLock.ID {
+		// Link doesn't replace a lock that was created in the meantime
+		os.Link(expiredPath, path)
+		return &backend.LockError{Name: name, Info: movedLock}
+	}
+
+	return nil
+}
+
+func readLockFile(path string) (backend.LockInfo, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return backend.LockInfo{}, err
+	}
+
+	return backend.ParseLockInfo(content)
+}
+
+func checkRevision(path, name, revision string) error {
+	currentRevision := ""
+	currentContent, err := ioutil.ReadFile(path)
+	if err == nil {
+		currentRevision = contentRevision(currentContent)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if currentRevision != revision {
+		return &backend.ConflictError{Name: name}
+	}
+
+	return nil
+}
+
+// contentRevision is the revision of a configuration stored on disk.
+func contentRevision(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,396 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// setupHome points the local backend at a temporary home directory.
+func setupHome(t *testing.T) (string, func()) {
+	home, err := ioutil.TempDir("", "triton-kubernetes-home-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+
+	return home, func() {
+		os.Setenv("HOME", oldHome)
+		homedir.DisableCache = false
+		os.RemoveAll(home)
+	}
+}
+
+func TestLock(t *testing.T) {
+	home, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := backend.NewLockInfo("create node")
+	err = b.Lock("dev-manager", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := backend.NewLockInfo("destroy node")
+	err = b.Lock("dev-manager", second)
+	lockErr, ok := err.(*backend.LockError)
+	if !ok {
+		t.Fatalf("Wrong output, expected *backend.LockError, received %v", err)
+	}
+	if lockErr.Info.ID != first.ID {
+		t.Errorf("Wrong output, expected %s, received %s", first.ID, lockErr.Info.ID)
+	}
+
+	err = b.Unlock("dev-manager", second.ID)
+	if err == nil {
+		t.Error("Expected unlock with a different lock ID to fail")
+	}
+
+	err = b.Unlock("dev-manager", first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing was persisted, so the manager directory should be gone
+	_, err = os.Stat(filepath.Join(home, ".triton-kubernetes", "dev-manager"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected cluster manager directory to be removed, received %v", err)
+	}
+
+	err = b.Unlock("dev-manager", first.ID)
+	if err != nil {
+		t.Errorf("Expected unlock of an unlocked state to succeed, received %v", err)
+	}
+}
+
+func TestLockTakesOverExpiredLock(t *testing.T) {
+	_, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := backend.NewLockInfo("create cluster")
+	stale.Expires = time.Now().Add(-time.Minute)
+	err = b.Lock("dev-manager", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := backend.NewLockInfo("create node")
+	err = b.Lock("dev-manager", fresh)
+	if err != nil {
+		t.Fatalf("Expected expired lock to be taken over, received %v", err)
+	}
+
+	err = b.Unlock("dev-manager", fresh.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockTakeOverRace(t *testing.T) {
+	_, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := backend.NewLockInfo("create cluster")
+	stale.Expires = time.Now().Add(-time.Minute)
+	err = b.Lock("dev-manager", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only one of the commands taking over the expired lock at the same time gets it
+	results := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			results <- b.Lock("dev-manager", backend.NewLockInfo("create node"))
+		}()
+	}
+
+	acquired := 0
+	for i := 0; i < 8; i++ {
+		err := <-results
+		if err == nil {
+			acquired++
+		} else if _, ok := err.(*backend.LockError); !ok {
+			t.Errorf("Wrong output, expected *backend.LockError, received %v", err)
+		}
+	}
+	if acquired != 1 {
+		t.Errorf("Expected exactly one command to acquire the lock, %d did", acquired)
+	}
+}
+
+func TestPersistStateConflict(t *testing.T) {
+	_, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.State("dev-manager")
+	if err != backend.ErrStateNotFound {
+		t.Fatalf("Wrong output, expected %v, received %v", backend.ErrStateNotFound, err)
+	}
+
+	initialState, _ := state.New("dev-manager", []byte(`{}`))
+	err = b.PersistState(initialState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := b.State("dev-manager")
+	second, _ := b.State("dev-manager")
+
+	first.SetManager(state.Manager{Name: "first"})
+	err = b.PersistState(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second.SetManager(state.Manager{Name: "second"})
+	err = b.PersistState(second)
+	if _, ok := err.(*backend.ConflictError); !ok {
+		t.Fatalf("Wrong output, expected *backend.ConflictError, received %v", err)
+	}
+
+	stored, _ := b.State("dev-manager")
+	name := stored.Get("module.cluster-manager.name")
+	if name != "first" {
+		t.Errorf("Wrong output, expected %s, received %s", "first", name)
+	}
+
+	newState, _ := state.New("dev-manager", []byte(`{}`))
+	err = b.PersistState(newState)
+	if _, ok := err.(*backend.ConflictError); !ok {
+		t.Errorf("Wrong output, expected *backend.ConflictError, received %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	home, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A manager persisted before history was kept
+	managerPath := filepath.Join(home, ".triton-kubernetes", "dev-manager")
+	os.MkdirAll(managerPath, os.ModePerm)
+	err = ioutil.WriteFile(filepath.Join(managerPath, "main.tf.json"), []byte(`{"module":{"cluster-manager":{"name":"v1"}}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"v2", "v3"} {
+		currentState, err := b.State("dev-manager")
+		if err != nil {
+			t.Fatal(err)
+		}
+		currentState.SetManager(state.Manager{Name: name})
+		err = b.PersistState(currentState)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := b.History("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Wrong output, expected 3 versions, received %v", history)
+	}
+
+	for i, expected := range []string{"v1", "v2", "v3"} {
+		versionState, err := b.HistoryState("dev-manager", history[i].Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := versionState.Get("module.cluster-manager.name")
+		if name != expected {
+			t.Errorf("Wrong output, expected %s, received %s", expected, name)
+		}
+	}
+
+	_, err = b.HistoryState("dev-manager", 4)
+	if err == nil {
+		t.Error("Expected an error for a version that doesn't exist")
+	}
+}
+
+func TestPersistHistoryState(t *testing.T) {
+	_, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currentState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"v1"}}}`))
+	err = b.PersistState(currentState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := b.History("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replacedState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"v1-replaced"}}}`))
+	err = b.PersistHistoryState(replacedState, history[0].Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versionState, err := b.HistoryState("dev-manager", history[0].Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := versionState.Get("module.cluster-manager.name"); name != "v1-replaced" {
+		t.Errorf("Wrong output, expected v1-replaced, received %s", name)
+	}
+
+	replacedHistory, err := b.History("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replacedHistory) != 1 || !replacedHistory[0].Modified.Equal(history[0].Modified) {
+		t.Errorf("Expected the history to be unchanged, received %v", replacedHistory)
+	}
+
+	err = b.PersistHistoryState(replacedState, 2)
+	if err == nil {
+		t.Error("Expected an error for a version that doesn't exist")
+	}
+}
+
+func TestTerraformState(t *testing.T) {
+	_, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := b.TerraformState("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != nil {
+		t.Errorf("Expected no terraform state, received %s", content)
+	}
+
+	expected := `{"version": 3}`
+	err = b.PersistTerraformState("dev-manager", []byte(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err = b.TerraformState("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Errorf("Wrong terraform state, expected %s, received %s", expected, content)
+	}
+}
+
+func TestStateExists(t *testing.T) {
+	_, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A lock alone doesn't make a cluster manager
+	lockInfo := backend.NewLockInfo("create manager")
+	err = b.Lock("dev-manager", lockInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Unlock("dev-manager", lockInfo.ID)
+
+	exists, err := b.StateExists("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Expected dev-manager not to exist")
+	}
+
+	newState, _ := state.New("dev-manager", []byte(`{}`))
+	err = b.PersistState(newState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err = b.StateExists("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("Expected dev-manager to exist")
+	}
+}
+
+func TestStates(t *testing.T) {
+	home, teardown := setupHome(t)
+	defer teardown()
+
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newState, _ := state.New("dev-manager", []byte(`{}`))
+	err = b.PersistState(newState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Directories without a configuration, like the terraform cache, aren't cluster managers
+	err = os.MkdirAll(filepath.Join(home, ".triton-kubernetes", "cache", "managers"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := b.States()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0] != "dev-manager" {
+		t.Errorf("Expected only dev-manager, got %v", states)
+	}
+}
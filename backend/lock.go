@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// DefaultLockTTL is how long a lock is held before it is considered stale and can be
+// taken over by someone else. It has to outlive the longest terraform run.
+const DefaultLockTTL = 2 * time.Hour
+
+// LockInfo is the lease stored next to a cluster manager's configuration while
+// a command is modifying it.
+type LockInfo struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Operation string    `json:"operation"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+// NewLockInfo returns a lease owned by the current user and host for the given operation.
+func NewLockInfo(operation string) LockInfo {
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+
+	owner := "unknown"
+	if currentUser, err := user.Current(); err == nil {
+		owner = currentUser.Username
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		owner = fmt.Sprintf("%s@%s", owner, hostname)
+	}
+
+	created := time.Now().UTC()
+
+	return LockInfo{
+		ID:        hex.EncodeToString(idBytes),
+		Owner:     owner,
+		Operation: operation,
+		Created:   created,
+		Expires:   created.Add(DefaultLockTTL),
+	}
+}
+
+// Expired returns true if the lease can be taken over.
+func (info LockInfo) Expired() bool {
+	return time.Now().After(info.Expires)
+}
+
+// Bytes returns the JSON representation that is stored by the backends.
+func (info LockInfo) Bytes() []byte {
+	content, _ := json.MarshalIndent(info, "", "\t")
+	return content
+}
+
+// ParseLockInfo parses a lease previously written with LockInfo.Bytes.
+func ParseLockInfo(raw []byte) (LockInfo, error) {
+	info := LockInfo{}
+	err := json.Unmarshal(raw, &info)
+	if err != nil {
+		return LockInfo{}, fmt.Errorf("Could not parse lock: %v", err)
+	}
+
+	return info, nil
+}
+
+// LockError is returned by Backend.Lock when the state is locked by someone else.
+type LockError struct {
+	Name string
+	Info LockInfo
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf(
+		"Cluster manager '%s' is locked by %s (operation: %s, created: %s, expires: %s).\n"+
+			"If the lock is stale, it can be removed with `triton-kubernetes force-unlock %s`.",
+		e.Name,
+		e.Info.Owner,
+		e.Info.Operation,
+		e.Info.Created.Format(time.RFC3339),
+		e.Info.Expires.Format(time.RFC3339),
+		e.Info.ID,
+	)
+}
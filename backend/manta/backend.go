@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/joyent/triton-kubernetes/backend"
@@ -12,14 +14,21 @@ import (
 
 	triton "github.com/joyent/triton-go"
 	"github.com/joyent/triton-go/authentication"
+	tritonErrors "github.com/joyent/triton-go/errors"
 	"github.com/joyent/triton-go/storage"
 )
 
 const (
+	// Number of entries requested per directory listing
+	listPageSize = 100
+
 	rootDirectory             = "/stor/triton-kubernetes"
 	rootPathFormat            = rootDirectory + "/%s"
 	terraformConfigPathFormat = rootDirectory + "/%s/main.tf.json"
 	terraformStatePathFormat  = rootDirectory + "/%s/terraform.tfstate"
+	lockPathFormat            = rootDirectory + "/%s/lock.json"
+	historyPathFormat         = rootDirectory + "/%s/history"
+	historyVersionPathFormat  = rootDirectory + "/%s/history/%d.json"
 
 	terraformBackendRootPathFormat = "/triton-kubernetes/%s"
 )
@@ -29,7 +38,8 @@ const (
 // and a terraform.tfstate file.
 // triton-kubernetes manages the main.tf.json file and terraform manages the terraform.tfstate file
 // Directory Path: /stor/triton-kubernetes/${CLUSTER_MANAGER_NAME}/main.tf.json
-// TODO: Lock terraform json configuration similar to how terraform locks tfstate file.
+// While a command modifies a cluster manager, a lock.json lease is kept in the same directory.
+// Conditional requests (If-None-Match/If-Match) make sure only one lease can be created.
 type mantaBackend struct {
 	tritonAccount string
 	tritonKeyPath string
@@ -95,61 +105,95 @@ func New(tritonAccount, tritonKeyPath, tritonKeyID, tritonURL, mantaURL string)
 }
 
 func (backend *mantaBackend) States() ([]string, error) {
-	input := storage.ListDirectoryInput{
-		DirectoryName: rootDirectory,
-		Limit:         100,
-	}
-
-	result, err := backend.tritonStorageClient.Dir().List(context.Background(), &input)
+	directories, err := listStates(backend.tritonStorageClient.Dir())
 	if err != nil {
 		return nil, err
 	}
 
-	if result.ResultSetSize == 0 {
-		return []string{}, nil
-	}
-
+	// Only directories with a configuration are cluster managers, a directory with only
+	// a lock or history is not
 	states := []string{}
-	for _, state := range result.Entries {
-		states = append(states, state.Name)
+	for _, name := range directories {
+		exists, err := backend.StateExists(name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			states = append(states, name)
+		}
 	}
 
 	return states, nil
 }
 
 func (backend *mantaBackend) State(name string) (state.State, error) {
-	terraformConfigPath := fmt.Sprintf(terraformConfigPathFormat, name)
+	return readState(backend.tritonStorageClient, name)
+}
 
-	getObjectInput := &storage.GetObjectInput{
-		ObjectPath: terraformConfigPath,
+func (backend *mantaBackend) StateExists(name string) (bool, error) {
+	getInfoInput := &storage.GetInfoInput{
+		ObjectPath: fmt.Sprintf(terraformConfigPathFormat, name),
 	}
-	output, err := backend.tritonStorageClient.Objects().Get(context.Background(), getObjectInput)
+	_, err := backend.tritonStorageClient.Objects().GetInfo(context.Background(), getInfoInput)
 	if err != nil {
-		// TODO: Find a better way to determine this error
-		if strings.Contains(err.Error(), "ResourceNotFound") {
-			// Since no state exists, lets create an empty one
-			return state.New(name, []byte("{}"))
+		if isNotFound(err) {
+			return false, nil
 		}
-		return state.State{}, err
+		return false, err
 	}
 
-	currentConfigBytes, err := ioutil.ReadAll(output.ObjectReader)
-	if err != nil {
-		return state.State{}, err
-	}
-
-	return state.New(name, currentConfigBytes)
+	return true, nil
 }
 
 func (backend *mantaBackend) PersistState(state state.State) error {
 	terraformConfigPath := fmt.Sprintf(terraformConfigPathFormat, state.Name)
 
-	objInput := storage.PutObjectInput{
-		ObjectPath:   terraformConfigPath,
+	history, err := backend.History(state.Name)
+	if err != nil {
+		return err
+	}
+
+	// If there is no history yet, the configuration that's about to be replaced becomes the first version
+	var previousConfig []byte
+	if len(history) == 0 && state.Revision != "" {
+		previousConfig, err = getRevision(backend.tritonStorageClient, terraformConfigPath, state.Name, state.Revision)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Refuse to overwrite a configuration that changed since it was read
+	err = putRevision(backend.tritonStorageClient, terraformConfigPath, state.Name, state.Revision, state.Bytes())
+	if err != nil {
+		return err
+	}
+
+	// The history is only appended to once the configuration was replaced
+	nextVersion := 1
+	if len(history) > 0 {
+		nextVersion = history[len(history)-1].Version + 1
+	} else if previousConfig != nil {
+		previousInput := storage.PutObjectInput{
+			ObjectPath:   fmt.Sprintf(historyVersionPathFormat, state.Name, 1),
+			ContentType:  "application/json",
+			ObjectReader: bytes.NewReader(previousConfig),
+			ForceInsert:  true,
+		}
+		err = backend.tritonStorageClient.Objects().Put(context.Background(), &previousInput)
+		if err != nil {
+			return err
+		}
+		nextVersion = 2
+	}
+
+	// Keep the new configuration as the next version in the history
+	historyInput := storage.PutObjectInput{
+		ObjectPath:   fmt.Sprintf(historyVersionPathFormat, state.Name, nextVersion),
 		ContentType:  "application/json",
 		ObjectReader: bytes.NewReader(state.Bytes()),
+		ForceInsert:  true,
 	}
-	err := backend.tritonStorageClient.Objects().Put(context.Background(), &objInput)
+	err = backend.tritonStorageClient.Objects().Put(context.Background(), &historyInput)
 	if err != nil {
 		return err
 	}
@@ -176,7 +220,28 @@ func (backend *mantaBackend) DeleteState(name string) error {
 		ObjectPath: terraformStatePath,
 	}
 	err = objClient.Delete(context.Background(), deleteObjInput)
-	if err != nil {
+	if err != nil && !tritonErrors.IsResourceNotFoundError(err) {
+		return err
+	}
+
+	// Deleting the lock.json file, if the state was locked
+	lockPath := fmt.Sprintf(lockPathFormat, name)
+	deleteObjInput = &storage.DeleteObjectInput{
+		ObjectPath: lockPath,
+	}
+	err = objClient.Delete(context.Background(), deleteObjInput)
+	if err != nil && !tritonErrors.IsResourceNotFoundError(err) {
+		return err
+	}
+
+	// Deleting the history directory
+	historyPath := fmt.Sprintf(historyPathFormat, name)
+	deleteHistoryDirInput := &storage.DeleteDirectoryInput{
+		DirectoryName: historyPath,
+		ForceDelete:   true,
+	}
+	err = backend.tritonStorageClient.Dir().Delete(context.Background(), deleteHistoryDirInput)
+	if err != nil && !tritonErrors.IsResourceNotFoundError(err) {
 		return err
 	}
 
@@ -193,6 +258,63 @@ func (backend *mantaBackend) DeleteState(name string) error {
 	return nil
 }
 
+// History versions are stored as `history/{version}.json`
+func (backend *mantaBackend) History(name string) ([]backend.HistoryEntry, error) {
+	entries, err := listDirectory(backend.tritonStorageClient.Dir(), fmt.Sprintf(historyPathFormat, name))
+	if err != nil {
+		if tritonErrors.IsResourceNotFoundError(err) || tritonErrors.IsDirectoryDoesNotExistError(err) {
+			return historyEntries(nil), nil
+		}
+		return nil, err
+	}
+
+	return historyEntries(entries), nil
+}
+
+func (backend *mantaBackend) HistoryState(name string, version int) (state.State, error) {
+	getObjectInput := &storage.GetObjectInput{
+		ObjectPath: fmt.Sprintf(historyVersionPathFormat, name, version),
+	}
+	output, err := backend.tritonStorageClient.Objects().Get(context.Background(), getObjectInput)
+	if err != nil {
+		if tritonErrors.IsResourceNotFoundError(err) {
+			return state.State{}, fmt.Errorf("Version %d of cluster manager '%s' does not exist.", version, name)
+		}
+		return state.State{}, err
+	}
+	defer output.ObjectReader.Close()
+
+	content, err := ioutil.ReadAll(output.ObjectReader)
+	if err != nil {
+		return state.State{}, err
+	}
+
+	return state.Load(name, content)
+}
+
+func (backend *mantaBackend) PersistHistoryState(state state.State, version int) error {
+	versionPath := fmt.Sprintf(historyVersionPathFormat, state.Name, version)
+
+	// Only replace an existing version
+	getInfoInput := &storage.GetInfoInput{
+		ObjectPath: versionPath,
+	}
+	_, err := backend.tritonStorageClient.Objects().GetInfo(context.Background(), getInfoInput)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("Version %d of cluster manager '%s' does not exist.", version, state.Name)
+		}
+		return err
+	}
+
+	objInput := storage.PutObjectInput{
+		ObjectPath:   versionPath,
+		ContentType:  "application/json",
+		ObjectReader: bytes.NewReader(state.Bytes()),
+	}
+	return backend.tritonStorageClient.Objects().Put(context.Background(), &objInput)
+}
+
 func (backend *mantaBackend) StateTerraformConfig(name string) (string, interface{}) {
 	terraformBackendConfig := mantaTerraformBackendConfig{
 		Account:     backend.tritonAccount,
@@ -203,3 +325,308 @@ func (backend *mantaBackend) StateTerraformConfig(name string) (string, interfac
 
 	return "terraform.backend.manta", terraformBackendConfig
 }
+
+func (backend *mantaBackend) TerraformEnv() []string {
+	return nil
+}
+
+func (backend *mantaBackend) TerraformState(name string) ([]byte, error) {
+	getObjectInput := &storage.GetObjectInput{
+		ObjectPath: fmt.Sprintf(terraformStatePathFormat, name),
+	}
+	output, err := backend.tritonStorageClient.Objects().Get(context.Background(), getObjectInput)
+	if err != nil {
+		if tritonErrors.IsResourceNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer output.ObjectReader.Close()
+
+	return ioutil.ReadAll(output.ObjectReader)
+}
+
+func (backend *mantaBackend) PersistTerraformState(name string, content []byte) error {
+	objInput := storage.PutObjectInput{
+		ObjectPath:   fmt.Sprintf(terraformStatePathFormat, name),
+		ContentType:  "application/json",
+		ObjectReader: bytes.NewReader(content),
+		ForceInsert:  true,
+	}
+
+	return backend.tritonStorageClient.Objects().Put(context.Background(), &objInput)
+}
+
+func (backend *mantaBackend) Lock(name string, info backend.LockInfo) error {
+	return putLock(backend.tritonStorageClient, name, info)
+}
+
+func (backend *mantaBackend) Unlock(name, id string) error {
+	existingLock, etag, err := getLock(backend.tritonStorageClient, name)
+	if err != nil {
+		if tritonErrors.IsResourceNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+
+	if existingLock.ID != id {
+		return fmt.Errorf("Lock ID '%s' does not match the current lock '%s' held by %s", id, existingLock.ID, existingLock.Owner)
+	}
+
+	lockPath := fmt.Sprintf(lockPathFormat, name)
+	deleteObjInput := &storage.DeleteObjectInput{
+		ObjectPath: lockPath,
+		Headers: map[string]string{
+			"If-Match": etag,
+		},
+	}
+	err = backend.tritonStorageClient.Objects().Delete(context.Background(), deleteObjInput)
+	if err != nil && !tritonErrors.IsResourceNotFoundError(err) {
+		return err
+	}
+
+	// Remove the cluster manager directory if the lock was the only thing in it,
+	// e.g. a manager creation that was canceled before its state was persisted.
+	rootPath := fmt.Sprintf(rootPathFormat, name)
+	deleteDirInput := &storage.DeleteDirectoryInput{
+		DirectoryName: rootPath,
+	}
+	err = backend.tritonStorageClient.Dir().Delete(context.Background(), deleteDirInput)
+	if err != nil && !tritonErrors.IsDirectoryNotEmptyError(err) && !tritonErrors.IsResourceNotFoundError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// putLock creates the lock.json lease. If-None-Match makes sure an existing lease
+// is never overwritten, an expired lease is replaced using If-Match on its ETag.
+func putLock(client *storage.StorageClient, name string, info backend.LockInfo) error {
+	lockPath := fmt.Sprintf(lockPathFormat, name)
+
+	objInput := storage.PutObjectInput{
+		ObjectPath:   lockPath,
+		ContentType:  "application/json",
+		ObjectReader: bytes.NewReader(info.Bytes()),
+		Headers: map[string]string{
+			"If-None-Match": "*",
+		},
+		ForceInsert: true,
+	}
+	err := client.Objects().Put(context.Background(), &objInput)
+	if err == nil {
+		return nil
+	}
+	if !tritonErrors.IsPreconditionFailedError(err) {
+		return err
+	}
+
+	existingLock, etag, err := getLock(client, name)
+	if err != nil {
+		return err
+	}
+
+	if !existingLock.Expired() {
+		return &backend.LockError{Name: name, Info: existingLock}
+	}
+
+	// Take over the expired lease
+	objInput = storage.PutObjectInput{
+		ObjectPath:   lockPath,
+		ContentType:  "application/json",
+		ObjectReader: bytes.NewReader(info.Bytes()),
+		IfMatch:      etag,
+	}
+	err = client.Objects().Put(context.Background(), &objInput)
+	if err != nil {
+		if tritonErrors.IsPreconditionFailedError(err) {
+			return fmt.Errorf("Could not acquire lock for cluster manager '%s', it was taken by someone else", name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func listStates(dirClient directoryLister) ([]string, error) {
+	entries, err := listDirectory(dirClient, rootDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each cluster manager is a directory, anything else is ignored
+	states := []string{}
+	for _, entry := range entries {
+		if entry.Type == "directory" {
+			states = append(states, entry.Name)
+		}
+	}
+
+	return states, nil
+}
+
+// directoryLister is implemented by *storage.DirectoryClient
+type directoryLister interface {
+	List(ctx context.Context, input *storage.ListDirectoryInput) (*storage.ListDirectoryOutput, error)
+}
+
+// listDirectory returns all entries of a directory, following the marker across pages.
+// Manta returns the marker entry itself as the first entry of the next page.
+func listDirectory(dirClient directoryLister, directoryName string) ([]*storage.DirectoryEntry, error) {
+	input := storage.ListDirectoryInput{
+		DirectoryName: directoryName,
+		Limit:         listPageSize,
+	}
+
+	entries := []*storage.DirectoryEntry{}
+	for {
+		result, err := dirClient.List(context.Background(), &input)
+		if err != nil {
+			return nil, err
+		}
+
+		newEntries := 0
+		for _, entry := range result.Entries {
+			if input.Marker != "" && entry.Name == input.Marker {
+				continue
+			}
+			entries = append(entries, entry)
+			newEntries++
+		}
+
+		if len(result.Entries) < listPageSize || newEntries == 0 {
+			break
+		}
+		input.Marker = result.Entries[len(result.Entries)-1].Name
+	}
+
+	return entries, nil
+}
+
+func historyEntries(entries []*storage.DirectoryEntry) []backend.HistoryEntry {
+	history := []backend.HistoryEntry{}
+	for _, entry := range entries {
+		version, err := strconv.Atoi(strings.TrimSuffix(entry.Name, ".json"))
+		if err != nil || entry.Type != "object" {
+			continue
+		}
+
+		history = append(history, backend.HistoryEntry{
+			Version:  version,
+			Modified: entry.ModifiedTime,
+		})
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version < history[j].Version
+	})
+
+	return history
+}
+
+// isNotFound reports whether err is a Manta error for a missing object or directory.
+// HEAD responses have no body, so the status code is checked as well.
+func isNotFound(err error) bool {
+	return tritonErrors.IsResourceNotFoundError(err) || tritonErrors.IsStatusNotFoundCode(err)
+}
+
+func readState(client *storage.StorageClient, name string) (state.State, error) {
+	terraformConfigPath := fmt.Sprintf(terraformConfigPathFormat, name)
+
+	getObjectInput := &storage.GetObjectInput{
+		ObjectPath: terraformConfigPath,
+	}
+	output, err := client.Objects().Get(context.Background(), getObjectInput)
+	if err != nil {
+		if isNotFound(err) {
+			return state.State{}, backend.ErrStateNotFound
+		}
+		return state.State{}, err
+	}
+	defer output.ObjectReader.Close()
+
+	currentConfigBytes, err := ioutil.ReadAll(output.ObjectReader)
+	if err != nil {
+		return state.State{}, err
+	}
+
+	currentState, err := state.Load(name, currentConfigBytes)
+	if err != nil {
+		return state.State{}, err
+	}
+	currentState.Revision = output.ETag
+
+	return currentState, nil
+}
+
+// getRevision returns the content of the object at path if its ETag is still revision.
+func getRevision(client *storage.StorageClient, path, name, revision string) ([]byte, error) {
+	getObjectInput := &storage.GetObjectInput{
+		ObjectPath: path,
+		Headers: map[string]string{
+			"If-Match": revision,
+		},
+	}
+	output, err := client.Objects().Get(context.Background(), getObjectInput)
+	if err != nil {
+		if tritonErrors.IsPreconditionFailedError(err) || isNotFound(err) {
+			return nil, &backend.ConflictError{Name: name}
+		}
+		return nil, err
+	}
+	defer output.ObjectReader.Close()
+
+	return ioutil.ReadAll(output.ObjectReader)
+}
+
+// putRevision replaces the object at path with content if its ETag is still revision, or
+// creates it if revision is empty and there is no object yet.
+func putRevision(client *storage.StorageClient, path, name, revision string, content []byte) error {
+	objInput := storage.PutObjectInput{
+		ObjectPath:   path,
+		ContentType:  "application/json",
+		ObjectReader: bytes.NewReader(content),
+	}
+	if revision != "" {
+		objInput.IfMatch = revision
+	} else {
+		objInput.Headers = map[string]string{
+			"If-None-Match": "*",
+		}
+	}
+	err := client.Objects().Put(context.Background(), &objInput)
+	if err != nil {
+		if tritonErrors.IsPreconditionFailedError(err) {
+			return &backend.ConflictError{Name: name}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func getLock(client *storage.StorageClient, name string) (backend.LockInfo, string, error) {
+	lockPath := fmt.Sprintf(lockPathFormat, name)
+
+	getObjectInput := &storage.GetObjectInput{
+		ObjectPath: lockPath,
+	}
+	output, err := client.Objects().Get(context.Background(), getObjectInput)
+	if err != nil {
+		return backend.LockInfo{}, "", err
+	}
+	defer output.ObjectReader.Close()
+
+	content, err := ioutil.ReadAll(output.ObjectReader)
+	if err != nil {
+		return backend.LockInfo{}, "", err
+	}
+
+	info, err := backend.ParseLockInfo(content)
+	if err != nil {
+		return backend.LockInfo{}, "", err
+	}
+
+	return info, output.ETag, nil
+}
@@ -0,0 +1,113 @@
+package manta
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/joyent/triton-go/storage"
+)
+
+// fakeDirectoryClient lists a single directory the way Manta does: entries are
+// sorted by name and a page starts at the marker entry, including it.
+type fakeDirectoryClient struct {
+	entries []*storage.DirectoryEntry
+	calls   int
+}
+
+func newFakeDirectoryClient(entries []*storage.DirectoryEntry) *fakeDirectoryClient {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return &fakeDirectoryClient{entries: entries}
+}
+
+func (f *fakeDirectoryClient) List(ctx context.Context, input *storage.ListDirectoryInput) (*storage.ListDirectoryOutput, error) {
+	f.calls++
+
+	start := 0
+	if input.Marker != "" {
+		start = sort.Search(len(f.entries), func(i int) bool {
+			return f.entries[i].Name >= input.Marker
+		})
+	}
+
+	end := start + int(input.Limit)
+	if end > len(f.entries) {
+		end = len(f.entries)
+	}
+
+	return &storage.ListDirectoryOutput{
+		Entries:       f.entries[start:end],
+		ResultSetSize: uint64(len(f.entries)),
+	}, nil
+}
+
+func TestListStatesPaging(t *testing.T) {
+	entries := []*storage.DirectoryEntry{}
+	for i := 0; i < 250; i++ {
+		entries = append(entries, &storage.DirectoryEntry{
+			Name: fmt.Sprintf("manager-%03d", i),
+			Type: "directory",
+		})
+	}
+	// Objects in the root directory are not cluster managers
+	entries = append(entries, &storage.DirectoryEntry{Name: "notes.txt", Type: "object"})
+
+	dirClient := newFakeDirectoryClient(entries)
+
+	states, err := listStates(dirClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(states) != 250 {
+		t.Errorf("Wrong number of states, expected 250, received %d", len(states))
+	}
+
+	seen := map[string]bool{}
+	for _, state := range states {
+		if seen[state] {
+			t.Errorf("Duplicate state %s", state)
+		}
+		seen[state] = true
+	}
+	if seen["notes.txt"] {
+		t.Error("Object notes.txt should not be listed as a state")
+	}
+
+	if dirClient.calls < 3 {
+		t.Errorf("Expected at least 3 pages to be listed, received %d", dirClient.calls)
+	}
+}
+
+func TestListStatesExactPage(t *testing.T) {
+	entries := []*storage.DirectoryEntry{}
+	for i := 0; i < listPageSize; i++ {
+		entries = append(entries, &storage.DirectoryEntry{
+			Name: fmt.Sprintf("manager-%03d", i),
+			Type: "directory",
+		})
+	}
+
+	states, err := listStates(newFakeDirectoryClient(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(states) != listPageSize {
+		t.Errorf("Wrong number of states, expected %d, received %d", listPageSize, len(states))
+	}
+}
+
+func TestListStatesEmpty(t *testing.T) {
+	states, err := listStates(newFakeDirectoryClient(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(states) != 0 {
+		t.Errorf("Expected no states, received %v", states)
+	}
+}
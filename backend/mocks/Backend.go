@@ -1,5 +1,6 @@
 package mocks
 
+import backend "github.com/joyent/triton-kubernetes/backend"
 import mock "github.com/stretchr/testify/mock"
 import state "github.com/joyent/triton-kubernetes/state"
 
@@ -22,6 +23,64 @@ func (_m *Backend) DeleteState(name string) error {
 	return r0
 }
 
+// History provides a mock function with given fields: name
+func (_m *Backend) History(name string) ([]backend.HistoryEntry, error) {
+	ret := _m.Called(name)
+
+	var r0 []backend.HistoryEntry
+	if rf, ok := ret.Get(0).(func(string) []backend.HistoryEntry); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]backend.HistoryEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HistoryState provides a mock function with given fields: name, version
+func (_m *Backend) HistoryState(name string, version int) (state.State, error) {
+	ret := _m.Called(name, version)
+
+	var r0 state.State
+	if rf, ok := ret.Get(0).(func(string, int) state.State); ok {
+		r0 = rf(name, version)
+	} else {
+		r0 = ret.Get(0).(state.State)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(name, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Lock provides a mock function with given fields: name, info
+func (_m *Backend) Lock(name string, info backend.LockInfo) error {
+	ret := _m.Called(name, info)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, backend.LockInfo) error); ok {
+		r0 = rf(name, info)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // PersistState provides a mock function with given fields: _a0
 func (_m *Backend) PersistState(_a0 state.State) error {
 	ret := _m.Called(_a0)
@@ -36,6 +95,34 @@ func (_m *Backend) PersistState(_a0 state.State) error {
 	return r0
 }
 
+// PersistHistoryState provides a mock function with given fields: _a0, version
+func (_m *Backend) PersistHistoryState(_a0 state.State, version int) error {
+	ret := _m.Called(_a0, version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(state.State, int) error); ok {
+		r0 = rf(_a0, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PersistTerraformState provides a mock function with given fields: name, content
+func (_m *Backend) PersistTerraformState(name string, content []byte) error {
+	ret := _m.Called(name, content)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []byte) error); ok {
+		r0 = rf(name, content)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // State provides a mock function with given fields: name
 func (_m *Backend) State(name string) (state.State, error) {
 	ret := _m.Called(name)
@@ -57,6 +144,27 @@ func (_m *Backend) State(name string) (state.State, error) {
 	return r0, r1
 }
 
+// StateExists provides a mock function with given fields: name
+func (_m *Backend) StateExists(name string) (bool, error) {
+	ret := _m.Called(name)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // StateTerraformConfig provides a mock function with given fields: name
 func (_m *Backend) StateTerraformConfig(name string) (string, interface{}) {
 	ret := _m.Called(name)
@@ -102,3 +210,56 @@ func (_m *Backend) States() ([]string, error) {
 
 	return r0, r1
 }
+
+// TerraformEnv provides a mock function with given fields:
+func (_m *Backend) TerraformEnv() []string {
+	ret := _m.Called()
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// TerraformState provides a mock function with given fields: name
+func (_m *Backend) TerraformState(name string) ([]byte, error) {
+	ret := _m.Called(name)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(string) []byte); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Unlock provides a mock function with given fields: name, id
+func (_m *Backend) Unlock(name string, id string) error {
+	ret := _m.Called(name, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(name, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
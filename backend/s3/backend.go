@@ -0,0 +1,606 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const (
+	rootDirectory             = "triton-kubernetes"
+	terraformConfigPathFormat = rootDirectory + "/%s/main.tf.json"
+	terraformStatePathFormat  = rootDirectory + "/%s/terraform.tfstate"
+	lockPathFormat            = rootDirectory + "/%s/lock.json"
+	historyPathFormat         = rootDirectory + "/%s/history/"
+	historyVersionPathFormat  = rootDirectory + "/%s/history/%d.json"
+)
+
+// Stores terraform json configuration files for all cluster managers in an S3 compatible
+// object store (AWS S3, MinIO, ...).
+// Each cluster manager has a separate prefix under triton-kubernetes with a main.tf.json object
+// and a terraform.tfstate object.
+// triton-kubernetes manages the main.tf.json object and terraform manages the terraform.tfstate object
+// Object Key: ${BUCKET}/triton-kubernetes/${CLUSTER_MANAGER_NAME}/main.tf.json
+// While a command modifies a cluster manager, a lock.json lease is kept under the same prefix.
+// The lease is written with a conditional write, so only one of several concurrent writers gets it.
+// Object stores that don't support conditional writes ignore the condition, on these locking is
+// advisory: the lease is read back after writing, which detects most but not all concurrent writers.
+type s3Backend struct {
+	accessKey string
+	secretKey string
+	region    string
+	endpoint  string
+	bucket    string
+
+	s3Client s3iface.S3API
+}
+
+// The credentials aren't part of the configuration, which is stored in the bucket and cached
+// by terraform, terraform gets them from the environment, see TerraformEnv
+type s3TerraformBackendConfig struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Region string `json:"region"`
+
+	// Only set for S3 compatible object stores such as MinIO
+	Endpoint                  string `json:"endpoint,omitempty"`
+	ForcePathStyle            bool   `json:"force_path_style,omitempty"`
+	SkipCredentialsValidation bool   `json:"skip_credentials_validation,omitempty"`
+	SkipRegionValidation      bool   `json:"skip_region_validation,omitempty"`
+	SkipMetadataAPICheck      bool   `json:"skip_metadata_api_check,omitempty"`
+}
+
+// New returns a backend that stores state in the given bucket. endpoint is optional,
+// when set requests are sent to that URL using path style addressing so that
+// S3 compatible object stores like MinIO can be used.
+func New(accessKey, secretKey, region, endpoint, bucket string) (backend.Backend, error) {
+	creds := credentials.NewStaticCredentials(accessKey, secretKey, "")
+	config := aws.NewConfig().WithCredentials(creds).WithRegion(region)
+	if endpoint != "" {
+		config = config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWithClient(accessKey, secretKey, region, endpoint, bucket, awss3.New(sess))
+}
+
+func newWithClient(accessKey, secretKey, region, endpoint, bucket string, s3Client s3iface.S3API) (backend.Backend, error) {
+	// Create bucket if it doesn't exist
+	_, err := s3Client.HeadBucket(&awss3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, err
+		}
+
+		_, err = s3Client.CreateBucket(&awss3.CreateBucketInput{
+			Bucket: aws.String(bucket),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &s3Backend{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+		endpoint:  endpoint,
+		bucket:    bucket,
+		s3Client:  s3Client,
+	}, nil
+}
+
+func (backend *s3Backend) States() ([]string, error) {
+	input := &awss3.ListObjectsV2Input{
+		Bucket:    aws.String(backend.bucket),
+		Prefix:    aws.String(rootDirectory + "/"),
+		Delimiter: aws.String("/"),
+	}
+
+	states := []string{}
+	for {
+		result, err := backend.s3Client.ListObjectsV2(input)
+		if err != nil {
+			return nil, err
+		}
+
+		// Each cluster manager is a common prefix `triton-kubernetes/{name}/` with a configuration,
+		// a prefix with only a lock or history is not
+		for _, prefix := range result.CommonPrefixes {
+			name := strings.TrimPrefix(aws.StringValue(prefix.Prefix), rootDirectory+"/")
+			name = strings.TrimSuffix(name, "/")
+			if name == "" {
+				continue
+			}
+
+			exists, err := backend.StateExists(name)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				states = append(states, name)
+			}
+		}
+
+		if !aws.BoolValue(result.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+
+	return states, nil
+}
+
+func (backend *s3Backend) State(name string) (state.State, error) {
+	return readState(backend.s3Client, backend.bucket, name)
+}
+
+func (backend *s3Backend) StateExists(name string) (bool, error) {
+	_, err := backend.s3Client.HeadObject(&awss3.HeadObjectInput{
+		Bucket: aws.String(backend.bucket),
+		Key:    aws.String(fmt.Sprintf(terraformConfigPathFormat, name)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (backend *s3Backend) PersistState(state state.State) error {
+	terraformConfigPath := fmt.Sprintf(terraformConfigPathFormat, state.Name)
+
+	// Fail early if the configuration changed since it was read, stores that ignore
+	// conditional writes only get this check
+	err := checkRevision(backend.s3Client, backend.bucket, terraformConfigPath, state.Name, state.Revision)
+	if err != nil {
+		return err
+	}
+
+	history, err := backend.History(state.Name)
+	if err != nil {
+		return err
+	}
+
+	// If there is no history yet, the configuration that's about to be replaced becomes the first version
+	var previousConfig []byte
+	if len(history) == 0 && state.Revision != "" {
+		previousConfig, err = getRevision(backend.s3Client, backend.bucket, terraformConfigPath, state.Name, state.Revision)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Refuse to overwrite a configuration that changed since it was read
+	err = putRevision(backend.s3Client, backend.bucket, terraformConfigPath, state.Name, state.Revision, state.Bytes())
+	if err != nil {
+		return err
+	}
+
+	// The history is only appended to once the configuration was replaced
+	nextVersion := 1
+	if len(history) > 0 {
+		nextVersion = history[len(history)-1].Version + 1
+	} else if previousConfig != nil {
+		_, err = backend.s3Client.PutObject(&awss3.PutObjectInput{
+			Bucket:      aws.String(backend.bucket),
+			Key:         aws.String(fmt.Sprintf(historyVersionPathFormat, state.Name, 1)),
+			ContentType: aws.String("application/json"),
+			Body:        bytes.NewReader(previousConfig),
+		})
+		if err != nil {
+			return err
+		}
+		nextVersion = 2
+	}
+
+	// Keep the new configuration as the next version in the history
+	_, err = backend.s3Client.PutObject(&awss3.PutObjectInput{
+		Bucket:      aws.String(backend.bucket),
+		Key:         aws.String(fmt.Sprintf(historyVersionPathFormat, state.Name, nextVersion)),
+		ContentType: aws.String("application/json"),
+		Body:        bytes.NewReader(state.Bytes()),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (backend *s3Backend) DeleteState(name string) error {
+	// Deleting the main.tf.json, terraform.tfstate, lock.json and history objects
+	keys := []string{
+		fmt.Sprintf(terraformConfigPathFormat, name),
+		fmt.Sprintf(terraformStatePathFormat, name),
+		fmt.Sprintf(lockPathFormat, name),
+	}
+	history, err := backend.History(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range history {
+		keys = append(keys, fmt.Sprintf(historyVersionPathFormat, name, entry.Version))
+	}
+
+	for _, key := range keys {
+		_, err := backend.s3Client.DeleteObject(&awss3.DeleteObjectInput{
+			Bucket: aws.String(backend.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// History versions are stored as `history/{version}.json`
+func (backend *s3Backend) History(name string) ([]backend.HistoryEntry, error) {
+	return listHistory(backend.s3Client, backend.bucket, name)
+}
+
+func (backend *s3Backend) HistoryState(name string, version int) (state.State, error) {
+	output, err := backend.s3Client.GetObject(&awss3.GetObjectInput{
+		Bucket: aws.String(backend.bucket),
+		Key:    aws.String(fmt.Sprintf(historyVersionPathFormat, name, version)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return state.State{}, fmt.Errorf("Version %d of cluster manager '%s' does not exist.", version, name)
+		}
+		return state.State{}, err
+	}
+	defer output.Body.Close()
+
+	content, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return state.State{}, err
+	}
+
+	return state.Load(name, content)
+}
+
+func (backend *s3Backend) PersistHistoryState(state state.State, version int) error {
+	versionKey := fmt.Sprintf(historyVersionPathFormat, state.Name, version)
+
+	// Only replace an existing version
+	_, err := backend.s3Client.HeadObject(&awss3.HeadObjectInput{
+		Bucket: aws.String(backend.bucket),
+		Key:    aws.String(versionKey),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("Version %d of cluster manager '%s' does not exist.", version, state.Name)
+		}
+		return err
+	}
+
+	_, err = backend.s3Client.PutObject(&awss3.PutObjectInput{
+		Bucket:      aws.String(backend.bucket),
+		Key:         aws.String(versionKey),
+		ContentType: aws.String("application/json"),
+		Body:        bytes.NewReader(state.Bytes()),
+	})
+
+	return err
+}
+
+func (backend *s3Backend) StateTerraformConfig(name string) (string, interface{}) {
+	terraformBackendConfig := s3TerraformBackendConfig{
+		Bucket: backend.bucket,
+		Key:    fmt.Sprintf(terraformStatePathFormat, name),
+		Region: backend.region,
+	}
+
+	if backend.endpoint != "" {
+		terraformBackendConfig.Endpoint = backend.endpoint
+		terraformBackendConfig.ForcePathStyle = true
+		terraformBackendConfig.SkipCredentialsValidation = true
+		terraformBackendConfig.SkipRegionValidation = true
+		terraformBackendConfig.SkipMetadataAPICheck = true
+	}
+
+	return "terraform.backend.s3", terraformBackendConfig
+}
+
+func (backend *s3Backend) TerraformEnv() []string {
+	return []string{
+		"AWS_ACCESS_KEY_ID=" + backend.accessKey,
+		"AWS_SECRET_ACCESS_KEY=" + backend.secretKey,
+	}
+}
+
+func (backend *s3Backend) TerraformState(name string) ([]byte, error) {
+	output, err := backend.s3Client.GetObject(&awss3.GetObjectInput{
+		Bucket: aws.String(backend.bucket),
+		Key:    aws.String(fmt.Sprintf(terraformStatePathFormat, name)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return ioutil.ReadAll(output.Body)
+}
+
+func (backend *s3Backend) PersistTerraformState(name string, content []byte) error {
+	_, err := backend.s3Client.PutObject(&awss3.PutObjectInput{
+		Bucket:      aws.String(backend.bucket),
+		Key:         aws.String(fmt.Sprintf(terraformStatePathFormat, name)),
+		ContentType: aws.String("application/json"),
+		Body:        bytes.NewReader(content),
+	})
+
+	return err
+}
+
+func (backend *s3Backend) Lock(name string, info backend.LockInfo) error {
+	return putLock(backend.s3Client, backend.bucket, name, info)
+}
+
+func (backend *s3Backend) Unlock(name, id string) error {
+	existingLock, _, err := getLock(backend.s3Client, backend.bucket, name)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if existingLock.ID != id {
+		return fmt.Errorf("Lock ID '%s' does not match the current lock '%s' held by %s", id, existingLock.ID, existingLock.Owner)
+	}
+
+	_, err = backend.s3Client.DeleteObject(&awss3.DeleteObjectInput{
+		Bucket: aws.String(backend.bucket),
+		Key:    aws.String(fmt.Sprintf(lockPathFormat, name)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func listHistory(s3Client s3iface.S3API, bucket, name string) ([]backend.HistoryEntry, error) {
+	historyPath := fmt.Sprintf(historyPathFormat, name)
+	input := &awss3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(historyPath),
+	}
+
+	history := []backend.HistoryEntry{}
+	for {
+		result, err := s3Client.ListObjectsV2(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			fileName := strings.TrimPrefix(aws.StringValue(object.Key), historyPath)
+			version, err := strconv.Atoi(strings.TrimSuffix(fileName, ".json"))
+			if err != nil {
+				continue
+			}
+
+			history = append(history, backend.HistoryEntry{
+				Version:  version,
+				Modified: aws.TimeValue(object.LastModified),
+			})
+		}
+
+		if !aws.BoolValue(result.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version < history[j].Version
+	})
+
+	return history, nil
+}
+
+func readState(s3Client s3iface.S3API, bucket, name string) (state.State, error) {
+	terraformConfigPath := fmt.Sprintf(terraformConfigPathFormat, name)
+
+	output, err := s3Client.GetObject(&awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(terraformConfigPath),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return state.State{}, backend.ErrStateNotFound
+		}
+		return state.State{}, err
+	}
+	defer output.Body.Close()
+
+	currentConfigBytes, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return state.State{}, err
+	}
+
+	currentState, err := state.Load(name, currentConfigBytes)
+	if err != nil {
+		return state.State{}, err
+	}
+	currentState.Revision = aws.StringValue(output.ETag)
+
+	return currentState, nil
+}
+
+func checkRevision(s3Client s3iface.S3API, bucket, key, name, revision string) error {
+	currentRevision := ""
+	output, err := s3Client.HeadObject(&awss3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		currentRevision = aws.StringValue(output.ETag)
+	} else if !isNotFound(err) {
+		return err
+	}
+
+	if currentRevision != revision {
+		return &backend.ConflictError{Name: name}
+	}
+
+	return nil
+}
+
+// getRevision returns the content of key if its ETag is still revision.
+func getRevision(s3Client s3iface.S3API, bucket, key, name, revision string) ([]byte, error) {
+	output, err := s3Client.GetObject(&awss3.GetObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		IfMatch: aws.String(revision),
+	})
+	if err != nil {
+		if isPreconditionFailed(err) || isNotFound(err) {
+			return nil, &backend.ConflictError{Name: name}
+		}
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return ioutil.ReadAll(output.Body)
+}
+
+// putRevision replaces key with content if its ETag is still revision, or creates it if
+// revision is empty and key doesn't exist yet. The condition is checked by the object
+// store (If-Match and If-None-Match), object stores that don't support conditional writes
+// ignore it.
+func putRevision(s3Client s3iface.S3API, bucket, key, name, revision string, content []byte) error {
+	header, value := "If-None-Match", "*"
+	if revision != "" {
+		header, value = "If-Match", revision
+	}
+
+	_, err := s3Client.PutObjectWithContext(aws.BackgroundContext(), &awss3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String("application/json"),
+		Body:        bytes.NewReader(content),
+	}, func(r *request.Request) {
+		r.HTTPRequest.Header.Set(header, value)
+	})
+	if err != nil {
+		if isPreconditionFailed(err) || (revision != "" && isNotFound(err)) {
+			return &backend.ConflictError{Name: name}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func putLock(s3Client s3iface.S3API, bucket, name string, info backend.LockInfo) error {
+	existingLock, revision, err := getLock(s3Client, bucket, name)
+	if err == nil && !existingLock.Expired() {
+		return &backend.LockError{Name: name, Info: existingLock}
+	}
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	// Only create the lease if there is none, or replace the expired one that was read
+	err = putRevision(s3Client, bucket, fmt.Sprintf(lockPathFormat, name), name, revision, info.Bytes())
+	if _, ok := err.(*backend.ConflictError); !ok && err != nil {
+		return err
+	}
+
+	// Read the lease back, if someone else wrote theirs at the same time only one of us wins.
+	storedLock, _, err := getLock(s3Client, bucket, name)
+	if err != nil {
+		return err
+	}
+	if storedLock.ID != info.ID {
+		return &backend.LockError{Name: name, Info: storedLock}
+	}
+
+	return nil
+}
+
+func getLock(s3Client s3iface.S3API, bucket, name string) (backend.LockInfo, string, error) {
+	output, err := s3Client.GetObject(&awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(fmt.Sprintf(lockPathFormat, name)),
+	})
+	if err != nil {
+		return backend.LockInfo{}, "", err
+	}
+	defer output.Body.Close()
+
+	content, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return backend.LockInfo{}, "", err
+	}
+
+	info, err := backend.ParseLockInfo(content)
+	if err != nil {
+		return backend.LockInfo{}, "", err
+	}
+
+	return info, aws.StringValue(output.ETag), nil
+}
+
+// isPreconditionFailed reports whether err is the response to a conditional request whose
+// condition didn't hold, or that raced another conditional write of the same object.
+func isPreconditionFailed(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		switch reqErr.StatusCode() {
+		case http.StatusPreconditionFailed, http.StatusConflict:
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNotFound reports whether err is an S3 error for a missing bucket or key.
+// HeadBucket responses have no body, so the status code is checked as well.
+func isNotFound(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case awss3.ErrCodeNoSuchKey, awss3.ErrCodeNoSuchBucket, "NotFound":
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,527 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 is an in-memory stand-in for an S3 compatible object store such as MinIO.
+// Only the calls used by s3Backend are implemented.
+type fakeS3 struct {
+	s3iface.S3API
+
+	buckets  map[string]map[string]fakeObject
+	pageSize int
+
+	// Called before a conditional put checks its condition, if set
+	beforeConditionalPut func()
+}
+
+type fakeObject struct {
+	content  []byte
+	modified time.Time
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		buckets: map[string]map[string]fakeObject{},
+	}
+}
+
+func notFoundError(code string) error {
+	return awserr.NewRequestFailure(awserr.New(code, "not found", nil), http.StatusNotFound, "")
+}
+
+func preconditionFailedError() error {
+	return awserr.NewRequestFailure(awserr.New("PreconditionFailed", "precondition failed", nil), http.StatusPreconditionFailed, "")
+}
+
+func (f *fakeS3) HeadBucket(input *awss3.HeadBucketInput) (*awss3.HeadBucketOutput, error) {
+	if _, ok := f.buckets[aws.StringValue(input.Bucket)]; !ok {
+		return nil, notFoundError("NotFound")
+	}
+	return &awss3.HeadBucketOutput{}, nil
+}
+
+func (f *fakeS3) CreateBucket(input *awss3.CreateBucketInput) (*awss3.CreateBucketOutput, error) {
+	f.buckets[aws.StringValue(input.Bucket)] = map[string]fakeObject{}
+	return &awss3.CreateBucketOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(input *awss3.GetObjectInput) (*awss3.GetObjectOutput, error) {
+	objects, ok := f.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, notFoundError(awss3.ErrCodeNoSuchBucket)
+	}
+	object, ok := objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, notFoundError(awss3.ErrCodeNoSuchKey)
+	}
+	if input.IfMatch != nil && aws.StringValue(input.IfMatch) != etag(object.content) {
+		return nil, preconditionFailedError()
+	}
+	return &awss3.GetObjectOutput{
+		Body: ioutil.NopCloser(bytes.NewReader(object.content)),
+		ETag: aws.String(etag(object.content)),
+	}, nil
+}
+
+func (f *fakeS3) HeadObject(input *awss3.HeadObjectInput) (*awss3.HeadObjectOutput, error) {
+	objects, ok := f.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, notFoundError(awss3.ErrCodeNoSuchBucket)
+	}
+	object, ok := objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, notFoundError("NotFound")
+	}
+	return &awss3.HeadObjectOutput{
+		ETag: aws.String(etag(object.content)),
+	}, nil
+}
+
+func etag(content []byte) string {
+	return fmt.Sprintf("\"%x\"", md5.Sum(content))
+}
+
+func (f *fakeS3) PutObject(input *awss3.PutObjectInput) (*awss3.PutObjectOutput, error) {
+	objects, ok := f.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, notFoundError(awss3.ErrCodeNoSuchBucket)
+	}
+	content, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	objects[aws.StringValue(input.Key)] = fakeObject{content: content, modified: time.Now()}
+	return &awss3.PutObjectOutput{}, nil
+}
+
+// PutObjectWithContext only supports the If-Match and If-None-Match headers as options.
+func (f *fakeS3) PutObjectWithContext(ctx aws.Context, input *awss3.PutObjectInput, opts ...request.Option) (*awss3.PutObjectOutput, error) {
+	req := &request.Request{HTTPRequest: &http.Request{Header: http.Header{}}}
+	req.ApplyOptions(opts...)
+
+	if f.beforeConditionalPut != nil {
+		f.beforeConditionalPut()
+	}
+
+	object, exists := f.buckets[aws.StringValue(input.Bucket)][aws.StringValue(input.Key)]
+	ifMatch := req.HTTPRequest.Header.Get("If-Match")
+	if ifMatch != "" && !exists {
+		return nil, notFoundError(awss3.ErrCodeNoSuchKey)
+	}
+	if (ifMatch != "" && ifMatch != etag(object.content)) || (req.HTTPRequest.Header.Get("If-None-Match") == "*" && exists) {
+		return nil, preconditionFailedError()
+	}
+
+	return f.PutObject(input)
+}
+
+func (f *fakeS3) DeleteObject(input *awss3.DeleteObjectInput) (*awss3.DeleteObjectOutput, error) {
+	objects, ok := f.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, notFoundError(awss3.ErrCodeNoSuchBucket)
+	}
+	delete(objects, aws.StringValue(input.Key))
+	return &awss3.DeleteObjectOutput{}, nil
+}
+
+// ListObjectsV2 returns common prefixes if a delimiter is given, objects otherwise.
+// Results are paged by pageSize if set.
+func (f *fakeS3) ListObjectsV2(input *awss3.ListObjectsV2Input) (*awss3.ListObjectsV2Output, error) {
+	objects, ok := f.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, notFoundError(awss3.ErrCodeNoSuchBucket)
+	}
+
+	prefix := aws.StringValue(input.Prefix)
+	delimiter := aws.StringValue(input.Delimiter)
+	seen := map[string]bool{}
+	keys := []string{}
+	for key := range objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			rest := key[len(prefix):]
+			i := strings.Index(rest, delimiter)
+			if i < 0 {
+				continue
+			}
+			key = prefix + rest[:i+len(delimiter)]
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	for start < len(keys) && input.ContinuationToken != nil && keys[start] <= *input.ContinuationToken {
+		start++
+	}
+	end := len(keys)
+	if f.pageSize > 0 && start+f.pageSize < end {
+		end = start + f.pageSize
+	}
+
+	output := &awss3.ListObjectsV2Output{
+		IsTruncated: aws.Bool(end < len(keys)),
+	}
+	for _, key := range keys[start:end] {
+		if delimiter != "" {
+			output.CommonPrefixes = append(output.CommonPrefixes, &awss3.CommonPrefix{Prefix: aws.String(key)})
+		} else {
+			output.Contents = append(output.Contents, &awss3.Object{
+				Key:          aws.String(key),
+				LastModified: aws.Time(objects[key].modified),
+			})
+		}
+	}
+	if end < len(keys) {
+		output.NextContinuationToken = aws.String(keys[end-1])
+	}
+
+	return output, nil
+}
+
+func TestNewCreatesBucket(t *testing.T) {
+	client := newFakeS3()
+
+	_, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := client.buckets["tk-state"]; !ok {
+		t.Error("Expected bucket 'tk-state' to be created")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	client := newFakeS3()
+	b, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.State("dev-manager")
+	if err != backend.ErrStateNotFound {
+		t.Fatalf("Wrong output, expected %v, received %v", backend.ErrStateNotFound, err)
+	}
+
+	exists, err := b.StateExists("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Expected dev-manager not to exist")
+	}
+
+	newState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"dev-manager"}}}`))
+	err = b.PersistState(newState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := client.buckets["tk-state"]["triton-kubernetes/dev-manager/main.tf.json"]; !ok {
+		t.Error("Expected main.tf.json to be stored under triton-kubernetes/dev-manager/")
+	}
+
+	exists, err = b.StateExists("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("Expected dev-manager to exist")
+	}
+
+	storedState, err := b.State("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := storedState.Get("module.cluster-manager.name")
+	if name != "dev-manager" {
+		t.Errorf("Wrong output, expected %s, received %s", "dev-manager", name)
+	}
+
+	err = b.DeleteState("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.buckets["tk-state"]) != 0 {
+		t.Errorf("Expected all objects to be deleted, found %d", len(client.buckets["tk-state"]))
+	}
+}
+
+func TestStatesPaging(t *testing.T) {
+	client := newFakeS3()
+	client.pageSize = 2
+	b, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"alpha", "beta", "delta", "gamma", "omega"}
+	for _, name := range expected {
+		s, _ := state.New(name, []byte(`{}`))
+		if err := b.PersistState(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Objects outside the root directory are not cluster managers
+	client.buckets["tk-state"]["unrelated/main.tf.json"] = fakeObject{content: []byte(`{}`)}
+	// Neither is a prefix with only a lock
+	err = b.Lock("locked", backend.NewLockInfo("create manager"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := b.States()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(states, ",") != strings.Join(expected, ",") {
+		t.Errorf("Wrong output, expected %v, received %v", expected, states)
+	}
+}
+
+func TestStateTerraformConfig(t *testing.T) {
+	b, err := newWithClient("access", "secret", "us-east-1", "http://127.0.0.1:9000", "tk-state", newFakeS3())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, obj := b.StateTerraformConfig("dev-manager")
+	if path != "terraform.backend.s3" {
+		t.Errorf("Wrong output, expected %s, received %s", "terraform.backend.s3", path)
+	}
+
+	cfg, ok := obj.(s3TerraformBackendConfig)
+	if !ok {
+		t.Fatalf("Unexpected terraform backend config type %T", obj)
+	}
+	if cfg.Key != "triton-kubernetes/dev-manager/terraform.tfstate" {
+		t.Errorf("Wrong output, expected %s, received %s", "triton-kubernetes/dev-manager/terraform.tfstate", cfg.Key)
+	}
+	if cfg.Endpoint != "http://127.0.0.1:9000" || !cfg.ForcePathStyle {
+		t.Errorf("Expected custom endpoint with path style addressing, received %+v", cfg)
+	}
+
+	// Credentials are only passed to terraform through the environment
+	content, _ := json.Marshal(obj)
+	if strings.Contains(string(content), "secret") || strings.Contains(string(content), "access") {
+		t.Errorf("Expected no credentials in the terraform backend config, received %s", content)
+	}
+	expected := []string{"AWS_ACCESS_KEY_ID=access", "AWS_SECRET_ACCESS_KEY=secret"}
+	if env := b.TerraformEnv(); strings.Join(env, ",") != strings.Join(expected, ",") {
+		t.Errorf("Wrong output, expected %v, received %v", expected, env)
+	}
+}
+
+func TestLock(t *testing.T) {
+	b, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", newFakeS3())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := backend.NewLockInfo("create node")
+	err = b.Lock("dev-manager", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.Lock("dev-manager", backend.NewLockInfo("destroy node"))
+	if _, ok := err.(*backend.LockError); !ok {
+		t.Fatalf("Wrong output, expected *backend.LockError, received %v", err)
+	}
+
+	err = b.Unlock("dev-manager", first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.Lock("dev-manager", backend.NewLockInfo("destroy node"))
+	if err != nil {
+		t.Errorf("Expected lock to be acquired after unlock, received %v", err)
+	}
+}
+
+func TestLockConcurrentWrite(t *testing.T) {
+	client := newFakeS3()
+	b, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else takes the expired lease after it was read
+	expired := backend.NewLockInfo("create node")
+	expired.Expires = time.Now().Add(-time.Minute)
+	err = b.Lock("dev-manager", expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := backend.NewLockInfo("destroy node")
+	client.beforeConditionalPut = func() {
+		client.beforeConditionalPut = nil
+		err := b.Lock("dev-manager", other)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = b.Lock("dev-manager", backend.NewLockInfo("create cluster"))
+	lockErr, ok := err.(*backend.LockError)
+	if !ok {
+		t.Fatalf("Wrong output, expected *backend.LockError, received %v", err)
+	}
+	if lockErr.Info.ID != other.ID {
+		t.Errorf("Wrong output, expected the lease %s, received %s", other.ID, lockErr.Info.ID)
+	}
+}
+
+func TestPersistStateConflict(t *testing.T) {
+	b, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", newFakeS3())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initialState, _ := state.New("dev-manager", []byte(`{"module":{}}`))
+	err = b.PersistState(initialState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := b.State("dev-manager")
+	second, _ := b.State("dev-manager")
+
+	first.SetBackup("cluster_triton_a", state.Backup{Fields: map[string]interface{}{"name": "a"}})
+	err = b.PersistState(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second.SetBackup("cluster_triton_b", state.Backup{Fields: map[string]interface{}{"name": "b"}})
+	err = b.PersistState(second)
+	if _, ok := err.(*backend.ConflictError); !ok {
+		t.Fatalf("Wrong output, expected *backend.ConflictError, received %v", err)
+	}
+
+	// A state for a manager that doesn't exist yet can't overwrite one that was created since
+	err = b.PersistState(initialState)
+	if _, ok := err.(*backend.ConflictError); !ok {
+		t.Errorf("Wrong output, expected *backend.ConflictError, received %v", err)
+	}
+}
+
+func TestPersistStateConcurrentWrite(t *testing.T) {
+	client := newFakeS3()
+	b, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initialState, _ := state.New("dev-manager", []byte(`{"module":{}}`))
+	err = b.PersistState(initialState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else writes the configuration after the revision was checked
+	currentState, _ := b.State("dev-manager")
+	client.beforeConditionalPut = func() {
+		client.beforeConditionalPut = nil
+		otherState, _ := b.State("dev-manager")
+		otherState.SetManager(state.Manager{Name: "b"})
+		err := b.PersistState(otherState)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	currentState.SetManager(state.Manager{Name: "a"})
+	err = b.PersistState(currentState)
+	if _, ok := err.(*backend.ConflictError); !ok {
+		t.Fatalf("Wrong output, expected *backend.ConflictError, received %v", err)
+	}
+
+	// The rejected configuration is not kept in the history
+	history, err := b.History("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Wrong output, expected 2 versions, received %v", history)
+	}
+	lastState, _ := b.HistoryState("dev-manager", history[1].Version)
+	if name := lastState.Get("module.cluster-manager.name"); name != "b" {
+		t.Errorf("Wrong output, expected b, received %s", name)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	client := newFakeS3()
+	b, err := newWithClient("access", "secret", "us-east-1", "", "tk-state", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A manager persisted before history was kept
+	client.buckets["tk-state"]["triton-kubernetes/dev-manager/main.tf.json"] = fakeObject{content: []byte(`{"module":{"cluster-manager":{"name":"v1"}}}`)}
+
+	for _, name := range []string{"v2", "v3"} {
+		currentState, err := b.State("dev-manager")
+		if err != nil {
+			t.Fatal(err)
+		}
+		currentState.SetManager(state.Manager{Name: name})
+		err = b.PersistState(currentState)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := b.History("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Wrong output, expected 3 versions, received %v", history)
+	}
+
+	for i, expected := range []string{"v1", "v2", "v3"} {
+		versionState, err := b.HistoryState("dev-manager", history[i].Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := versionState.Get("module.cluster-manager.name")
+		if name != expected {
+			t.Errorf("Wrong output, expected %s, received %s", expected, name)
+		}
+	}
+
+	_, err = b.HistoryState("dev-manager", 4)
+	if err == nil {
+		t.Error("Expected an error for a version that doesn't exist")
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan>",
+	Short: "Apply a plan saved with --out",
+	Long:  `Apply makes exactly the changes of a plan saved by create or destroy with --out and stores the configuration the plan was made for.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = manager.ApplyPlan(remoteBackend, shell.NewRunner(remoteBackend), args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+}
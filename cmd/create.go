@@ -5,9 +5,11 @@ import (
 	"os"
 
 	"github.com/joyent/triton-kubernetes/create"
+	"github.com/joyent/triton-kubernetes/shell"
 	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // createCmd represents the create command
@@ -15,6 +17,16 @@ var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create resources",
 	Long:  `Create allows you to create resources that triton-kubernetes can manage.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Bound here as destroy has plan flags of its own
+		viper.BindPFlag("plan", cmd.Flags().Lookup("plan"))
+		viper.BindPFlag("out", cmd.Flags().Lookup("out"))
+
+		// Saving a plan implies planning
+		if cmd.Flags().Changed("out") {
+			viper.Set("plan", true)
+		}
+	},
 }
 
 var createManagerCmd = &cobra.Command{
@@ -27,7 +39,7 @@ var createManagerCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err = create.NewManager(remoteBackend)
+		err = create.NewManager(remoteBackend, shell.NewRunner(remoteBackend))
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -45,7 +57,7 @@ var createClusterCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err = create.NewCluster(remoteBackend)
+		err = create.NewCluster(remoteBackend, shell.NewRunner(remoteBackend))
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -63,7 +75,7 @@ var createNodeCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err = create.NewNode(remoteBackend)
+		err = create.NewNode(remoteBackend, shell.NewRunner(remoteBackend))
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -81,7 +93,7 @@ var createBackupCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err = create.NewBackup(remoteBackend)
+		err = create.NewBackup(remoteBackend, shell.NewRunner(remoteBackend))
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -93,4 +105,10 @@ func init() {
 	rootCmd.AddCommand(createCmd)
 
 	createCmd.AddCommand(createManagerCmd, createClusterCmd, createNodeCmd, createBackupCmd)
+
+	createCmd.PersistentFlags().Bool("plan", false, "Show what terraform would change without applying or storing anything")
+	createCmd.PersistentFlags().String("out", "", "Save the plan to a file to apply it later with apply, implies --plan")
+
+	createNodeCmd.Flags().Bool("adopt", false, "Register running hosts over SSH instead of provisioning new machines")
+	viper.BindPFlag("adopt", createNodeCmd.Flags().Lookup("adopt"))
 }
@@ -6,9 +6,11 @@ import (
 	"os"
 
 	"github.com/joyent/triton-kubernetes/destroy"
+	"github.com/joyent/triton-kubernetes/shell"
 	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // destroyCmd represents the destroy command
@@ -30,6 +32,16 @@ var destroyCmd = &cobra.Command{
 
 		return fmt.Errorf(`invalid argument "%s" for "triton-kubernetes destory"`, args[0])
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Bound here as create has plan flags of its own
+		viper.BindPFlag("plan", cmd.Flags().Lookup("plan"))
+		viper.BindPFlag("out", cmd.Flags().Lookup("out"))
+
+		// Saving a plan implies planning
+		if cmd.Flags().Changed("out") {
+			viper.Set("plan", true)
+		}
+	},
 	Run: destroyCmdFunc,
 }
 
@@ -44,21 +56,21 @@ func destroyCmdFunc(cmd *cobra.Command, args []string) {
 	switch destroyType {
 	case "manager":
 		fmt.Println("destroy manager called")
-		err := destroy.DeleteManager(remoteBackend)
+		err := destroy.DeleteManager(remoteBackend, shell.NewRunner(remoteBackend))
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
 	case "cluster":
 		fmt.Println("destroy cluster called")
-		err := destroy.DeleteCluster(remoteBackend)
+		err := destroy.DeleteCluster(remoteBackend, shell.NewRunner(remoteBackend))
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
 	case "node":
 		fmt.Println("destroy node called")
-		err := destroy.DeleteNode(remoteBackend)
+		err := destroy.DeleteNode(remoteBackend, shell.NewRunner(remoteBackend))
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -79,4 +91,9 @@ func init() {
 	// is called directly, e.g.:
 	// destroyCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
+	destroyCmd.Flags().Bool("keep-infra", false, "Remove the cluster from the configuration without destroying its infrastructure")
+	viper.BindPFlag("keep-infra", destroyCmd.Flags().Lookup("keep-infra"))
+	destroyCmd.Flags().Bool("plan", false, "Show what terraform would destroy without destroying or storing anything")
+	destroyCmd.Flags().String("out", "", "Save the plan to a file to apply it later with apply, implies --plan")
+
 }
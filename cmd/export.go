@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export resources",
+	Long:  `Export allows you to package resources managed by triton-kubernetes, e.g. to hand them to another team.`,
+}
+
+var exportManagerCmd = &cobra.Command{
+	Use:   "manager [name]",
+	Short: "Export a cluster manager with its terraform state and local files into a bundle",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		encrypt, _ := cmd.Flags().GetBool("encrypt")
+
+		err = manager.ExportManager(remoteBackend, name, output, encrypt)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportManagerCmd.Flags().StringP("output", "o", "", "File to write the bundle to (default is <name>.tgz)")
+	exportManagerCmd.Flags().Bool("encrypt", false, "Encrypt the bundle with bundle_keyfile or bundle_passphrase")
+
+	exportCmd.AddCommand(exportManagerCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+var forceUnlockCmd = &cobra.Command{
+	Use:   "force-unlock LOCK_ID",
+	Short: "Manually unlock a cluster manager",
+	Long: `Force-unlock removes the lock on a cluster manager's configuration.
+Commands that modify a cluster manager hold a lock for the duration of the run.
+If a command was interrupted, the lock is left behind until it expires.
+The LOCK_ID is printed by the command that failed to acquire the lock.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New(`"triton-kubernetes force-unlock" requires one argument`)
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = manager.ForceUnlock(remoteBackend, args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(forceUnlockCmd)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/joyent/triton-kubernetes/get"
+	"github.com/joyent/triton-kubernetes/shell"
 	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/spf13/cobra"
@@ -34,26 +35,46 @@ var getCmd = &cobra.Command{
 }
 
 func getCmdFunc(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("output")
+	err := get.CheckOutputFormat(format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	remoteBackend, err := util.PromptForBackend()
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
+	// Only the result goes to stdout, messages and terraform's output go to stderr
+	runner := shell.NewRunnerWithOutput(remoteBackend, os.Stderr)
+
 	getType := args[0]
 	switch getType {
 	case "manager":
-		fmt.Println("get manager called")
-		err := get.GetManager(remoteBackend)
+		fmt.Fprintln(os.Stderr, "get manager called")
+		output, err := get.GetManager(remoteBackend, runner)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = get.Print(os.Stdout, format, output)
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 	case "cluster":
-		fmt.Println("get cluster called")
-		err := get.GetCluster(remoteBackend)
+		fmt.Fprintln(os.Stderr, "get cluster called")
+		output, err := get.GetCluster(remoteBackend, runner)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = get.Print(os.Stdout, format, output)
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 	}
@@ -72,4 +93,6 @@ func init() {
 	// is called directly, e.g.:
 	// getCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
+	getCmd.Flags().StringP("output", "o", "table", "Output format: table, json or yaml")
+
 }
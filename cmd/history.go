@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Display configuration history",
+	Long:  `History lists the stored versions of a cluster manager's configuration.`,
+}
+
+var historyManagerCmd = &cobra.Command{
+	Use:   "manager [name]",
+	Short: "Display cluster manager configuration history",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		err = manager.History(remoteBackend, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.AddCommand(historyManagerCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/create"
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import existing resources",
+	Long:  `Import allows you to manage existing resources with triton-kubernetes.`,
+}
+
+var importClusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Import a Kubernetes Cluster registered in Rancher",
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = create.ImportCluster(remoteBackend, shell.NewRunner(remoteBackend))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var importManagerCmd = &cobra.Command{
+	Use:   "manager <bundle>",
+	Short: "Import a cluster manager from a bundle created by export manager",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = manager.ImportManager(remoteBackend, args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.AddCommand(importClusterCmd, importManagerCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateBackendCmd = &cobra.Command{
+	Use:   "migrate-backend [name]",
+	Short: "Move a cluster manager to another backend",
+	Long: `Migrate-backend copies a cluster manager's configuration and terraform state
+from one backend to another (e.g. from Local to Manta) and points terraform
+at the new backend. The source is deleted once the copy has been verified.
+The destination backend is configured by the backend keys prefixed with "destination_",
+e.g. destination_backend_provider.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		destinationBackend, err := util.PromptForDestinationBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		err = manager.MigrateBackend(sourceBackend, destinationBackend, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateBackendCmd)
+}
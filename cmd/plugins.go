@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/shell"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// pluginsCmd represents the plugins command
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Manage third party terraform plugins",
+	Long:  `Plugins allows you to manage the third party terraform plugins triton-kubernetes installs.`,
+}
+
+var pluginsSyncCmd = &cobra.Command{
+	Use:   "sync [directory]",
+	Short: "Download the plugins of the plugin manifest into a plugin mirror",
+	Long:  `Sync downloads the plugins of the plugin manifest for every platform it lists, and the manifest itself, into a directory that can be used as plugin_mirror on hosts without internet access. The directory defaults to plugin_mirror.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mirror := viper.GetString("plugin_mirror")
+		if len(args) > 0 {
+			mirror = args[0]
+		}
+		if mirror == "" {
+			fmt.Println(errors.New("A directory or plugin_mirror must be specified"))
+			os.Exit(1)
+		}
+
+		expandedMirror, err := homedir.Expand(mirror)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = shell.SyncPlugins(expandedMirror)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+
+	pluginsCmd.AddCommand(pluginsSyncCmd)
+}
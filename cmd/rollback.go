@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore a previous configuration",
+	Long:  `Rollback restores a previous version of a cluster manager's configuration, as listed by "triton-kubernetes history".`,
+}
+
+var rollbackManagerCmd = &cobra.Command{
+	Use:   "manager [name]",
+	Short: "Restore a previous cluster manager configuration",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		version, _ := cmd.Flags().GetInt("to")
+
+		err = manager.Rollback(remoteBackend, name, version)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+
+	rollbackManagerCmd.Flags().Int("to", 0, "Version to restore")
+	rollbackManagerCmd.MarkFlagRequired("to")
+
+	rollbackCmd.AddCommand(rollbackManagerCmd)
+}
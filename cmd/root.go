@@ -39,6 +39,9 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.triton-kubernetes.yaml)")
 	rootCmd.PersistentFlags().Bool("non-interactive", false, "Prevent interactive prompts")
 	rootCmd.PersistentFlags().Bool("terraform-configuration", false, "Create terraform configuration only")
+	rootCmd.PersistentFlags().Bool("allow-exec-secrets", false, "Run the commands of exec: secret references, only with the local backend")
+	rootCmd.PersistentFlags().Bool("allow-secret-references", false, "Resolve env: and file: secret references of configurations in a remote backend")
+	rootCmd.PersistentFlags().String("terraform", "", "Path of the terraform binary to run (default is the one installed by 'terraform install' or on PATH)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -47,14 +50,21 @@ func init() {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	// Messages go to stderr, stdout is kept for results, e.g. of get --output json
 	viper.BindPFlag("non-interactive", rootCmd.Flags().Lookup("non-interactive"))
 	if viper.GetBool("non-interactive") {
-		fmt.Println("Running in non interactive mode")
+		fmt.Fprintln(os.Stderr, "Running in non interactive mode")
 	}
 	viper.BindPFlag("terraform-configuration", rootCmd.Flags().Lookup("terraform-configuration"))
 	if viper.GetBool("terraform-configuration") {
-		fmt.Println("Will not create infrastructure, only terraform configuration")
+		fmt.Fprintln(os.Stderr, "Will not create infrastructure, only terraform configuration")
 	}
+	viper.BindPFlag("terraform_path", rootCmd.Flags().Lookup("terraform"))
+	// Only the flags allow exec:, env: and file: references, never a config file or environment variable
+	allowExecSecrets, _ := rootCmd.Flags().GetBool("allow-exec-secrets")
+	viper.Set("allow-exec-secrets", allowExecSecrets)
+	allowSecretReferences, _ := rootCmd.Flags().GetBool("allow-secret-references")
+	viper.Set("allow-secret-references", allowSecretReferences)
 	if cfgFile != "" { // enable ability to specify config file via flag
 		viper.SetConfigFile(cfgFile)
 	} else {
@@ -66,6 +76,6 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
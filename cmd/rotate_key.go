@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+var rotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key [name]",
+	Short: "Re-encrypt stored secrets with a new key",
+	Long: `Rotate-key re-encrypts the secrets stored in the configuration of a cluster manager,
+or of all cluster managers, with the key given by new_encryption_keyfile or new_encryption_passphrase.
+The current key is given by encryption_keyfile or encryption_passphrase, if secrets are stored
+in plaintext it can be left out and they are encrypted with the new key.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		oldKey, err := util.GetSecretKey()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		newKey, err := util.GetNewSecretKey()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		err = manager.RotateKey(remoteBackend, name, oldKey, newKey)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotateKeyCmd)
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/manager"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/cobra"
+)
+
+// stateCmd represents the state command
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage stored cluster manager configurations",
+	Long:  `State manages the configurations of cluster managers stored in the backend.`,
+}
+
+var stateUpgradeCmd = &cobra.Command{
+	Use:   "upgrade [name]",
+	Short: "Upgrade a cluster manager configuration to the current schema version",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		err = manager.UpgradeState(remoteBackend, name, dryRun)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var stateValidateCmd = &cobra.Command{
+	Use:   "validate [name]",
+	Short: "Check a cluster manager configuration for problems",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		err = manager.ValidateState(remoteBackend, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:   "show [name] [path]",
+	Short: "Print a cluster manager configuration, or the part of it at path",
+	Args:  cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		path := ""
+		if len(args) > 1 {
+			path = args[1]
+		}
+
+		err = manager.ShowState(remoteBackend, name, path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var stateGetCmd = &cobra.Command{
+	Use:   "get <name> <path>",
+	Short: "Print the value at path, e.g. module.cluster-manager.name",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = manager.GetStateValue(remoteBackend, args[0], args[1])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var stateSetCmd = &cobra.Command{
+	Use:   "set <name> <path> <value>",
+	Short: "Set the value at path",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		isJSON, _ := cmd.Flags().GetBool("json")
+		force, _ := cmd.Flags().GetBool("force")
+
+		err = manager.SetStateValue(remoteBackend, args[0], args[1], args[2], isJSON, force)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var stateRmCmd = &cobra.Command{
+	Use:   "rm <name> <path>",
+	Short: "Remove the value at path",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteBackend, err := util.PromptForBackend()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+
+		err = manager.RemoveStateValue(remoteBackend, args[0], args[1], force)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+
+	stateUpgradeCmd.Flags().Bool("dry-run", false, "Show what would change without storing the upgraded configuration")
+
+	stateSetCmd.Flags().Bool("json", false, "Parse the value as JSON instead of storing it as a string")
+	stateSetCmd.Flags().Bool("force", false, "Store the change even if it introduces problems")
+	stateRmCmd.Flags().Bool("force", false, "Store the change even if it introduces problems")
+
+	stateCmd.AddCommand(stateUpgradeCmd)
+	stateCmd.AddCommand(stateValidateCmd)
+	stateCmd.AddCommand(stateShowCmd)
+	stateCmd.AddCommand(stateGetCmd)
+	stateCmd.AddCommand(stateSetCmd)
+	stateCmd.AddCommand(stateRmCmd)
+}
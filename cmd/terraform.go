@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/shell"
+
+	"github.com/spf13/cobra"
+)
+
+// terraformCmd represents the terraform command
+var terraformCmd = &cobra.Command{
+	Use:   "terraform",
+	Short: "Manage the terraform binary triton-kubernetes runs",
+	Long: `Terraform allows you to check and install the terraform binary triton-kubernetes runs.
+
+triton-kubernetes runs the terraform set by --terraform or terraform_path, the one
+installed by 'terraform install', or the first one on PATH, in that order. It must
+be one of the supported versions: ` + shell.SupportedTerraformVersions,
+}
+
+var terraformCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Print which terraform is run and check its version",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		terraformPath, err := shell.Terraform()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		terraformVersion, err := shell.TerraformVersion(terraformPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Terraform %s at %s is supported\n", terraformVersion, terraformPath)
+	},
+}
+
+var terraformInstallCmd = &cobra.Command{
+	Use:   "install [version]",
+	Short: "Install terraform into ~/.triton-kubernetes/bin",
+	Long: `Install downloads terraform for this platform, verifies it against the release's
+SHA256SUMS and installs it into ~/.triton-kubernetes/bin, or bin_dir, where it's
+run instead of the terraform on PATH. The version defaults to ` + shell.PinnedTerraformVersion + `.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		terraformVersion := shell.PinnedTerraformVersion
+		if len(args) > 0 {
+			terraformVersion = args[0]
+		}
+
+		terraformPath, err := shell.InstallTerraform(terraformVersion)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Installed terraform %s at %s\n", terraformVersion, terraformPath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(terraformCmd)
+
+	terraformCmd.AddCommand(terraformCheckCmd)
+	terraformCmd.AddCommand(terraformInstallCmd)
+}
@@ -23,7 +23,7 @@ type baseBackupTerraformConfig struct {
 	RancherClusterID string `json:"rancher_cluster_id"`
 }
 
-func NewBackup(remoteBackend backend.Backend) error {
+func NewBackup(remoteBackend backend.Backend, runner shell.Runner) error {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
@@ -71,6 +71,14 @@ func NewBackup(remoteBackend backend.Backend) error {
 		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
 	}
 
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("create backup")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
 	currentState, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
 		return err
@@ -162,6 +170,15 @@ func NewBackup(remoteBackend backend.Backend) error {
 		return err
 	}
 
+	// With --plan only show what terraform would change, nothing is stored
+	if viper.GetBool("plan") {
+		err = runner.Init(currentState)
+		if err != nil {
+			return err
+		}
+		return runner.Plan(currentState, []string{}, currentState)
+	}
+
 	if !nonInteractiveMode {
 		label := "Proceed with the backup creation"
 		selected := "Proceed"
@@ -176,7 +193,11 @@ func NewBackup(remoteBackend backend.Backend) error {
 	}
 
 	// Run terraform apply with state
-	err = shell.RunTerraformApplyWithState(currentState)
+	err = runner.Init(currentState)
+	if err != nil {
+		return err
+	}
+	err = runner.Apply(currentState)
 	if err != nil {
 		return err
 	}
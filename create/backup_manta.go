@@ -124,7 +124,7 @@ func newMantaBackup(selectedClusterKey string, currentState state.State) error {
 		cfg.MantaSubuser = result
 	}
 
-	err = currentState.AddBackup(selectedClusterKey, cfg)
+	err = setBackup(currentState, selectedClusterKey, cfg)
 	if err != nil {
 		return err
 	}
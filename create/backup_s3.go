@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -88,7 +89,15 @@ func newS3Backup(selectedClusterKey string, currentState state.State) error {
 	}
 
 	// We now have enough information to init an aws client
-	creds := credentials.NewStaticCredentials(cfg.AWSAccessKey, cfg.AWSSecretKey, "")
+	awsAccessKey, err := util.ResolveSecret(cfg.AWSAccessKey)
+	if err != nil {
+		return err
+	}
+	awsSecretKey, err := util.ResolveSecret(cfg.AWSSecretKey)
+	if err != nil {
+		return err
+	}
+	creds := credentials.NewStaticCredentials(awsAccessKey, awsSecretKey, "")
 
 	// Using us-west-1 region by default. The configuration needs a region set to
 	// get all regions available to the aws user.
@@ -229,7 +238,7 @@ func newS3Backup(selectedClusterKey string, currentState state.State) error {
 		cfg.AWSS3Bucket = bucketNames[i]
 	}
 
-	err = currentState.AddBackup(selectedClusterKey, cfg)
+	err = setBackup(currentState, selectedClusterKey, cfg)
 	if err != nil {
 		return err
 	}
@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/joyent/triton-kubernetes/shell"
-	"github.com/joyent/triton-kubernetes/state"
 	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/joyent/triton-kubernetes/backend"
@@ -45,7 +44,7 @@ type baseClusterTerraformConfig struct {
 	KubernetesRegistryPassword string `json:"k8s_registry_password,omitempty"`
 }
 
-func NewCluster(remoteBackend backend.Backend) error {
+func NewCluster(remoteBackend backend.Backend, runner shell.Runner) error {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
@@ -93,6 +92,14 @@ func NewCluster(remoteBackend backend.Backend) error {
 		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
 	}
 
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("create cluster")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
 	currentState, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
 		return err
@@ -150,14 +157,6 @@ func NewCluster(remoteBackend backend.Backend) error {
 		return err
 	}
 
-	// TODO: Find a fix - state.Clusters() doesn't return any clusters added via state.Add().
-	// However, the new clusters appear in the result of state.Bytes(). The current workaround
-	// is to create a new state object that has the same bytes as the previous state object.
-	currentState, err = state.New(currentState.Name, currentState.Bytes())
-	if err != nil {
-		return err
-	}
-
 	// Get the new cluster key given the cluster name
 	clusterMap, err := currentState.Clusters()
 	if err != nil {
@@ -185,9 +184,16 @@ func NewCluster(remoteBackend backend.Backend) error {
 			viper.Set("node_count", nodeToAdd["node_count"])
 			viper.Set("hostname", nodeToAdd["hostname"])
 			viper.Set("docker_engine_install_url", nodeToAdd["docker_engine_install_url"])
+			viper.Set("adopt", nodeToAdd["adopt"])
 
 			// Figure out cloud provider
-			if selectedCloudProvider == "aws" {
+			if nodeToAdd["adopt"] == true || selectedCloudProvider == "baremetal" {
+				// Copy variables of the bare metal host module to viper
+				viper.Set("ssh_user", nodeToAdd["ssh_user"])
+				viper.Set("key_path", nodeToAdd["key_path"])
+				viper.Set("bastion_host", nodeToAdd["bastion_host"])
+				viper.Set("hosts", nodeToAdd["hosts"])
+			} else if selectedCloudProvider == "aws" {
 				// Copy aws node variables to viper
 				viper.Set("aws_ami_id", nodeToAdd["aws_ami_id"])
 				viper.Set("aws_instance_type", nodeToAdd["aws_instance_type"])
@@ -208,11 +214,6 @@ func NewCluster(remoteBackend backend.Backend) error {
 				viper.Set("azure_size", nodeToAdd["azure_size"])
 				viper.Set("azure_ssh_user", nodeToAdd["azure_ssh_user"])
 				viper.Set("azure_public_key_path", nodeToAdd["azure_public_key_path"])
-			} else if selectedCloudProvider == "baremetal" {
-				viper.Set("ssh_user", nodeToAdd["ssh_user"])
-				viper.Set("key_path", nodeToAdd["key_path"])
-				viper.Set("bastion_host", nodeToAdd["bastion_host"])
-				viper.Set("hosts", nodeToAdd["hosts"])
 			}
 
 			// Create the new node
@@ -269,21 +270,36 @@ func NewCluster(remoteBackend backend.Backend) error {
 			shouldCreateNode = createNodeOptions[i].Value
 		}
 
-		// Confirmation
-		label := "Proceed with cluster creation"
-		selected := "Proceed"
-		confirmed, err := util.PromptForConfirmation(label, selected)
+		// Confirmation, not needed when only planning
+		if !viper.GetBool("plan") {
+			label := "Proceed with cluster creation"
+			selected := "Proceed"
+			confirmed, err := util.PromptForConfirmation(label, selected)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println("Cluster creation canceled.")
+				return nil
+			}
+		}
+	}
+
+	// With --plan only show what terraform would change, nothing is stored
+	if viper.GetBool("plan") {
+		err = runner.Init(currentState)
 		if err != nil {
 			return err
 		}
-		if !confirmed {
-			fmt.Println("Cluster creation canceled.")
-			return nil
-		}
+		return runner.Plan(currentState, []string{}, currentState)
 	}
 
 	// Run terraform apply with state
-	err = shell.RunTerraformApplyWithState(currentState)
+	err = runner.Init(currentState)
+	if err != nil {
+		return err
+	}
+	err = runner.Apply(currentState)
 	if err != nil {
 		return err
 	}
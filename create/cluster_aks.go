@@ -11,6 +11,7 @@ import (
 
 	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-04-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2017-09-30/containerservice"
@@ -239,7 +240,11 @@ func newAKSCluster(remoteBackend backend.Backend, currentState state.State) (str
 		return "", err
 	}
 
-	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, cfg.AzureClientSecret, azureEnv.ResourceManagerEndpoint)
+	azureClientSecret, err := util.ResolveSecret(cfg.AzureClientSecret)
+	if err != nil {
+		return "", err
+	}
+	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, azureClientSecret, azureEnv.ResourceManagerEndpoint)
 	if err != nil {
 		return "", err
 	}
@@ -513,7 +518,7 @@ func newAKSCluster(remoteBackend backend.Backend, currentState state.State) (str
 	cfg.NodeCount = nodeCount
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("aks", cfg.Name, &cfg)
+	err = setCluster(currentState, "aks", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -98,7 +99,15 @@ func newAWSCluster(remoteBackend backend.Backend, currentState state.State) (str
 	}
 
 	// We now have enough information to init an aws client
-	creds := credentials.NewStaticCredentials(cfg.AWSAccessKey, cfg.AWSSecretKey, "")
+	awsAccessKey, err := util.ResolveSecret(cfg.AWSAccessKey)
+	if err != nil {
+		return "", err
+	}
+	awsSecretKey, err := util.ResolveSecret(cfg.AWSSecretKey)
+	if err != nil {
+		return "", err
+	}
+	creds := credentials.NewStaticCredentials(awsAccessKey, awsSecretKey, "")
 
 	// Using us-west-1 region by default. The configuration needs a region set to
 	// get all regions available to the aws user.
@@ -355,7 +364,7 @@ func newAWSCluster(remoteBackend backend.Backend, currentState state.State) (str
 	}
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("aws", cfg.Name, &cfg)
+	err = setCluster(currentState, "aws", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
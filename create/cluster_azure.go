@@ -8,6 +8,7 @@ import (
 
 	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2016-06-01/subscriptions"
 	"github.com/Azure/go-autorest/autorest"
@@ -181,7 +182,11 @@ func newAzureCluster(remoteBackend backend.Backend, currentState state.State) (s
 		return "", err
 	}
 
-	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, cfg.AzureClientSecret, azureEnv.ResourceManagerEndpoint)
+	azureClientSecret, err := util.ResolveSecret(cfg.AzureClientSecret)
+	if err != nil {
+		return "", err
+	}
+	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, azureClientSecret, azureEnv.ResourceManagerEndpoint)
 	if err != nil {
 		return "", err
 	}
@@ -242,7 +247,7 @@ func newAzureCluster(remoteBackend backend.Backend, currentState state.State) (s
 	}
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("azure", cfg.Name, &cfg)
+	err = setCluster(currentState, "azure", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
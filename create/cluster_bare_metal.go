@@ -27,7 +27,7 @@ func newBareMetalCluster(remoteBackend backend.Backend, currentState state.State
 	}
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("baremetal", cfg.Name, &cfg)
+	err = setCluster(currentState, "baremetal", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"strings"
 
@@ -55,6 +54,10 @@ func newGCPCluster(remoteBackend backend.Backend, currentState state.State) (str
 		prompt := promptui.Prompt{
 			Label: "Path to Google Cloud Platform Credentials File",
 			Validate: func(input string) error {
+				if state.IsSecretReference(input) {
+					return nil
+				}
+
 				expandedPath, err := homedir.Expand(input)
 				if err != nil {
 					return err
@@ -77,16 +80,11 @@ func newGCPCluster(remoteBackend backend.Backend, currentState state.State) (str
 		rawGCPPathToCredentials = result
 	}
 
-	expandedGCPPathToCredentials, err := homedir.Expand(rawGCPPathToCredentials)
-	if err != nil {
-		return "", err
-	}
-	cfg.GCPPathToCredentials = expandedGCPPathToCredentials
-
-	gcpCredentials, err := ioutil.ReadFile(cfg.GCPPathToCredentials)
+	gcpPathToCredentials, gcpCredentials, err := readGCPCredentials(rawGCPPathToCredentials)
 	if err != nil {
 		return "", err
 	}
+	cfg.GCPPathToCredentials = gcpPathToCredentials
 
 	jwtCfg, err := google.JWTConfigFromJSON(gcpCredentials, "https://www.googleapis.com/auth/compute.readonly")
 	if err != nil {
@@ -159,7 +157,7 @@ func newGCPCluster(remoteBackend backend.Backend, currentState state.State) (str
 	}
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("gcp", cfg.Name, &cfg)
+	err = setCluster(currentState, "gcp", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
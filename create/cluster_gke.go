@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"regexp"
 	"strconv"
@@ -109,6 +108,10 @@ func newGKECluster(remoteBackend backend.Backend, currentState state.State) (str
 		prompt := promptui.Prompt{
 			Label: "Path to Google Cloud Platform Credentials File",
 			Validate: func(input string) error {
+				if state.IsSecretReference(input) {
+					return nil
+				}
+
 				expandedPath, err := homedir.Expand(input)
 				if err != nil {
 					return err
@@ -131,16 +134,11 @@ func newGKECluster(remoteBackend backend.Backend, currentState state.State) (str
 		rawGCPPathToCredentials = result
 	}
 
-	expandedGCPPathToCredentials, err := homedir.Expand(rawGCPPathToCredentials)
-	if err != nil {
-		return "", err
-	}
-	cfg.GCPPathToCredentials = expandedGCPPathToCredentials
-
-	gcpCredentials, err := ioutil.ReadFile(cfg.GCPPathToCredentials)
+	gcpPathToCredentials, gcpCredentials, err := readGCPCredentials(rawGCPPathToCredentials)
 	if err != nil {
 		return "", err
 	}
+	cfg.GCPPathToCredentials = gcpPathToCredentials
 
 	jwtCfg, err := google.JWTConfigFromJSON(gcpCredentials, "https://www.googleapis.com/auth/compute.readonly", "https://www.googleapis.com/auth/cloud-platform")
 	if err != nil {
@@ -510,7 +508,7 @@ func newGKECluster(remoteBackend backend.Backend, currentState state.State) (str
 	}
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("gke", cfg.Name, &cfg)
+	err = setCluster(currentState, "gke", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,226 @@
+package create
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/viper"
+)
+
+const (
+	importedRancherKubernetesTerraformModulePath = "terraform/modules/imported-rancher-k8s"
+)
+
+// This struct represents the definition of a Terraform .tf file.
+// Marshalled into json this struct can be passed directly to Terraform.
+type importedClusterTerraformConfig struct {
+	Source string `json:"source"`
+
+	Name string `json:"name"`
+
+	RancherAPIURL    string `json:"rancher_api_url"`
+	RancherAccessKey string `json:"rancher_access_key"`
+	RancherSecretKey string `json:"rancher_secret_key"`
+	RancherClusterID string `json:"rancher_cluster_id"`
+}
+
+// ImportCluster adds a cluster that was registered into the cluster manager's Rancher outside of
+// triton-kubernetes. Its module only references the Rancher cluster, no infrastructure is created.
+func ImportCluster(remoteBackend backend.Backend, runner shell.Runner) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+	clusterManagers, err := remoteBackend.States()
+	if err != nil {
+		return err
+	}
+
+	if len(clusterManagers) == 0 {
+		return fmt.Errorf("No cluster managers, please create a cluster manager before importing a kubernetes cluster.")
+	}
+
+	selectedClusterManager := ""
+	if viper.IsSet("cluster_manager") {
+		selectedClusterManager = viper.GetString("cluster_manager")
+	} else if nonInteractiveMode {
+		return errors.New("cluster_manager must be specified")
+	} else {
+		prompt := promptui.Select{
+			Label: "Cluster Manager",
+			Items: clusterManagers,
+			Templates: &promptui.SelectTemplates{
+				Label:    "{{ . }}?",
+				Active:   fmt.Sprintf(`%s {{ . | underline }}`, promptui.IconSelect),
+				Inactive: `  {{ . }}`,
+				Selected: fmt.Sprintf(`{{ "%s" | green }} {{ "Cluster Manager:" | bold}} {{ . }}`, promptui.IconGood),
+			},
+		}
+
+		_, value, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+
+		selectedClusterManager = value
+	}
+
+	// Verify selected cluster manager exists
+	found := false
+	for _, clusterManager := range clusterManagers {
+		if selectedClusterManager == clusterManager {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("import cluster")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
+	currentState, err := remoteBackend.State(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	cfg := importedClusterTerraformConfig{
+		RancherAPIURL:    "${module.cluster-manager.rancher_url}",
+		RancherAccessKey: "${module.cluster-manager.rancher_access_key}",
+		RancherSecretKey: "${module.cluster-manager.rancher_secret_key}",
+	}
+
+	baseSource := defaultSourceURL
+	if viper.IsSet("source_url") {
+		baseSource = viper.GetString("source_url")
+	}
+
+	baseSourceRef := defaultSourceRef
+	if viper.IsSet("source_ref") {
+		baseSourceRef = viper.GetString("source_ref")
+	}
+
+	// Module Source location e.g. github.com/joyent/triton-kubernetes//terraform/modules/imported-rancher-k8s?ref=master
+	cfg.Source = fmt.Sprintf("%s//%s?ref=%s", baseSource, importedRancherKubernetesTerraformModulePath, baseSourceRef)
+
+	// Name
+	clusterNameRegexp := regexp.MustCompile("^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$")
+	if viper.IsSet("name") {
+		cfg.Name = viper.GetString("name")
+	} else if nonInteractiveMode {
+		return errors.New("name must be specified")
+	} else {
+		prompt := promptui.Prompt{
+			Label: "Cluster Name",
+			Validate: func(input string) error {
+				if !clusterNameRegexp.MatchString(input) {
+					return errors.New("A DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character")
+				}
+
+				return nil
+			},
+		}
+
+		result, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+		cfg.Name = result
+	}
+
+	if cfg.Name == "" || !clusterNameRegexp.MatchString(cfg.Name) {
+		return errors.New("Invalid Cluster Name")
+	}
+
+	// Cluster names are used to select clusters, so they must be unique across providers
+	clusters, err := currentState.Clusters()
+	if err != nil {
+		return err
+	}
+	if _, ok := clusters[cfg.Name]; ok {
+		return fmt.Errorf("A cluster named '%s' already exists.", cfg.Name)
+	}
+
+	// Rancher Cluster ID
+	if viper.IsSet("rancher_cluster_id") {
+		cfg.RancherClusterID = viper.GetString("rancher_cluster_id")
+	} else if nonInteractiveMode {
+		return errors.New("rancher_cluster_id must be specified")
+	} else {
+		prompt := promptui.Prompt{
+			Label: "Rancher Cluster ID",
+			Validate: func(input string) error {
+				if input == "" {
+					return errors.New("Invalid Rancher Cluster ID")
+				}
+
+				return nil
+			},
+		}
+
+		result, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+		cfg.RancherClusterID = result
+	}
+
+	if cfg.RancherClusterID == "" {
+		return errors.New("Invalid Rancher Cluster ID")
+	}
+
+	for name, clusterKey := range clusters {
+		cluster, err := currentState.Cluster(clusterKey)
+		if err != nil {
+			return err
+		}
+		if cluster.RancherClusterID == cfg.RancherClusterID {
+			return fmt.Errorf("Rancher cluster '%s' was already imported as '%s'.", cfg.RancherClusterID, name)
+		}
+	}
+
+	err = setCluster(currentState, "imported", cfg.Name, &cfg)
+	if err != nil {
+		return err
+	}
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("Import Rancher cluster %q as %q", cfg.RancherClusterID, cfg.Name)
+		selected := "Import"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Cluster import canceled.")
+			return nil
+		}
+	}
+
+	// Run terraform apply with state
+	err = runner.Init(currentState)
+	if err != nil {
+		return err
+	}
+	err = runner.Apply(currentState)
+	if err != nil {
+		return err
+	}
+
+	// After terraform succeeds, commit state
+	err = remoteBackend.PersistState(currentState)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
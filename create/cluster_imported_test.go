@@ -0,0 +1,86 @@
+package create
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+var importedClusterManager = []byte(`{
+	"module": {
+		"cluster-manager": {"source": "./terraform/modules/bare-metal-rancher", "name": "dev-manager"},
+		"cluster_imported_existing": {
+			"//": {"role": "cluster", "provider": "imported", "cluster": "existing"},
+			"source": "./terraform/modules/imported-rancher-k8s",
+			"name": "existing",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_access_key": "${module.cluster-manager.rancher_access_key}",
+			"rancher_secret_key": "${module.cluster-manager.rancher_secret_key}",
+			"rancher_cluster_id": "c-abcde"
+		}
+	}
+}`)
+
+func TestImportCluster(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("terraform-configuration", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("name", "legacy")
+	viper.Set("rancher_cluster_id", "c-4x8tq")
+
+	currentState, _ := state.Load("dev-manager", importedClusterManager)
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+	localBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		clusters, err := s.Clusters()
+		if err != nil || clusters["legacy"] != "cluster_imported_legacy" {
+			return false
+		}
+
+		cluster, err := s.Cluster("cluster_imported_legacy")
+		return err == nil && cluster.RancherClusterID == "c-4x8tq" && len(s.Validate()) == 0
+	})).Return(nil)
+
+	err := ImportCluster(localBackend, shelltest.New())
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
+
+func TestImportClusterAlreadyImported(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("terraform-configuration", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("name", "legacy")
+	viper.Set("rancher_cluster_id", "c-abcde")
+
+	currentState, _ := state.Load("dev-manager", importedClusterManager)
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	expected := "Rancher cluster 'c-abcde' was already imported as 'existing'."
+
+	err := ImportCluster(localBackend, shelltest.New())
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	localBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
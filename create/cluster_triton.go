@@ -131,7 +131,7 @@ func newTritonCluster(remoteBackend backend.Backend, currentState state.State) (
 	}
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("triton", cfg.Name, &cfg)
+	err = setCluster(currentState, "triton", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
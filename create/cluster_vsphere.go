@@ -201,7 +201,7 @@ func newVSphereCluster(remoteBackend backend.Backend, currentState state.State)
 	}
 
 	// Add new cluster to terraform config
-	err = currentState.AddCluster("vsphere", cfg.Name, &cfg)
+	err = setCluster(currentState, "vsphere", cfg.Name, &cfg)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,148 @@
+package create
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// Every config struct written to a state, added the way the create commands add them.
+// Golden files are in testdata/golden, run `go test ./create -update` to regenerate them.
+var configGoldenTestCases = []struct {
+	Name string
+	Add  func(currentState *state.State, cfg interface{}) error
+	Cfg  interface{}
+}{
+	{"manager_aws", goldenManager, &awsManagerTerraformConfig{}},
+	{"manager_azure", goldenManager, &azureManagerTerraformConfig{}},
+	{"manager_bare_metal", goldenManager, &bareMetalManagerTerraformConfig{}},
+	{"manager_gcp", goldenManager, &gcpManagerTerraformConfig{}},
+	{"manager_triton", goldenManager, &tritonManagerTerraformConfig{}},
+
+	{"cluster_aks", goldenCluster("aks"), &aksClusterTerraformConfig{}},
+	{"cluster_aws", goldenCluster("aws"), &awsClusterTerraformConfig{}},
+	{"cluster_azure", goldenCluster("azure"), &azureClusterTerraformConfig{}},
+	{"cluster_bare_metal", goldenCluster("baremetal"), &bareMetalClusterTerraformConfig{}},
+	{"cluster_gcp", goldenCluster("gcp"), &gcpClusterTerraformConfig{}},
+	{"cluster_gke", goldenCluster("gke"), &gkeClusterTerraformConfig{}},
+	{"cluster_imported", goldenCluster("imported"), &importedClusterTerraformConfig{}},
+	{"cluster_triton", goldenCluster("triton"), &tritonClusterTerraformConfig{}},
+	{"cluster_vsphere", goldenCluster("vsphere"), &vSphereClusterTerraformConfig{}},
+
+	{"node_aws", goldenNode("aws"), &awsNodeTerraformConfig{}},
+	{"node_azure", goldenNode("azure"), &azureNodeTerraformConfig{}},
+	{"node_bare_metal", goldenNode("baremetal"), &bareMetalNodeTerraformConfig{}},
+	{"node_gcp", goldenNode("gcp"), &gcpNodeTerraformConfig{}},
+	{"node_triton", goldenNode("triton"), &tritonNodeTerraformConfig{}},
+	{"node_vsphere", goldenNode("vsphere"), &vSphereNodeTerraformConfig{}},
+
+	{"backup_manta", goldenBackup, &mantaBackupTerraformConfig{}},
+	{"backup_s3", goldenBackup, &s3BackupTerraformConfig{}},
+}
+
+func goldenManager(currentState *state.State, cfg interface{}) error {
+	return setManager(*currentState, cfg)
+}
+
+func goldenCluster(provider string) func(currentState *state.State, cfg interface{}) error {
+	return func(currentState *state.State, cfg interface{}) error {
+		return setCluster(*currentState, provider, "dev", cfg)
+	}
+}
+
+func goldenNode(provider string) func(currentState *state.State, cfg interface{}) error {
+	return func(currentState *state.State, cfg interface{}) error {
+		return setNode(*currentState, state.ClusterKey(provider, "dev"), "dev-worker-1", cfg)
+	}
+}
+
+func goldenBackup(currentState *state.State, cfg interface{}) error {
+	return setBackup(*currentState, state.ClusterKey("triton", "dev"), cfg)
+}
+
+func TestConfigGolden(t *testing.T) {
+	for _, tc := range configGoldenTestCases {
+		cfg := reflect.ValueOf(tc.Cfg).Elem()
+		fillConfig(cfg, "")
+
+		// Nodes are added with their hostname as the name
+		if hostname := cfg.FieldByName("Hostname"); hostname.IsValid() {
+			hostname.SetString("dev-worker-1")
+		}
+
+		currentState, err := state.New("dev-manager", []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tc.Add(&currentState, tc.Cfg)
+		if err != nil {
+			t.Errorf("%s: %s", tc.Name, err)
+			continue
+		}
+
+		// Adding the same config again must not change anything
+		first := currentState.Bytes()
+		err = tc.Add(&currentState, tc.Cfg)
+		if err != nil {
+			t.Errorf("%s: %s", tc.Name, err)
+			continue
+		}
+		output := currentState.Bytes()
+		if !bytes.Equal(first, output) {
+			t.Errorf("%s: serialization is not stable, got:\n%s\nthen:\n%s", tc.Name, first, output)
+		}
+
+		goldenPath := filepath.Join("testdata", "golden", tc.Name+".json")
+		if *updateGolden {
+			err = ioutil.WriteFile(goldenPath, output, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(goldenPath)
+		if err != nil {
+			t.Errorf("%s: %s, run with -update to create it", tc.Name, err)
+			continue
+		}
+		if !bytes.Equal(expected, output) {
+			t.Errorf("%s: output doesn't match %s, got:\n%s\nwant:\n%s", tc.Name, goldenPath, output, expected)
+		}
+	}
+}
+
+// fillConfig sets every field of a config struct to a value derived from its JSON name,
+// so that optional fields are part of the golden files too.
+func fillConfig(v reflect.Value, name string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+			if jsonName == "-" || (field.PkgPath != "" && !field.Anonymous) {
+				continue
+			}
+			fillConfig(v.Field(i), jsonName)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(fmt.Sprintf("<%s>", name))
+		}
+	case reflect.Int:
+		v.SetInt(3)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf([]string{fmt.Sprintf("<%s_1>", name), fmt.Sprintf("<%s_2>", name)}))
+	}
+}
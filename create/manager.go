@@ -8,6 +8,7 @@ import (
 
 	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/state"
 	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/manifoldco/promptui"
@@ -29,7 +30,7 @@ type baseManagerTerraformConfig struct {
 	RancherRegistryPassword string `json:"rancher_registry_password,omitempty"`
 }
 
-func NewManager(remoteBackend backend.Backend) error {
+func NewManager(remoteBackend backend.Backend, runner shell.Runner) error {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 
 	selectedCloudProvider := ""
@@ -103,7 +104,15 @@ func NewManager(remoteBackend backend.Backend) error {
 		return fmt.Errorf("A Cluster Manager with the name '%s' already exists.", name)
 	}
 
-	currentState, err := remoteBackend.State(name)
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("create manager")
+	err = remoteBackend.Lock(name, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(name, lockInfo.ID)
+
+	currentState, err := state.Load(name, []byte("{}"))
 	if err != nil {
 		return err
 	}
@@ -127,6 +136,17 @@ func NewManager(remoteBackend backend.Backend) error {
 		return err
 	}
 
+	currentState.SetTerraformBackendConfig(remoteBackend.StateTerraformConfig(name))
+
+	// With --plan only show what terraform would change, nothing is stored
+	if viper.GetBool("plan") {
+		err = runner.Init(currentState)
+		if err != nil {
+			return err
+		}
+		return runner.Plan(currentState, []string{}, currentState)
+	}
+
 	if !nonInteractiveMode {
 		label := "Proceed with the manager creation"
 		selected := "Proceed"
@@ -140,9 +160,11 @@ func NewManager(remoteBackend backend.Backend) error {
 		}
 	}
 
-	currentState.SetTerraformBackendConfig(remoteBackend.StateTerraformConfig(name))
-
-	err = shell.RunTerraformApplyWithState(currentState)
+	err = runner.Init(currentState)
+	if err != nil {
+		return err
+	}
+	err = runner.Apply(currentState)
 	if err != nil {
 		return err
 	}
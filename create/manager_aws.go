@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -103,7 +104,15 @@ func newAWSManager(currentState state.State, name string) error {
 	}
 
 	// We now have enough information to init an aws client
-	creds := credentials.NewStaticCredentials(cfg.AWSAccessKey, cfg.AWSSecretKey, "")
+	awsAccessKey, err := util.ResolveSecret(cfg.AWSAccessKey)
+	if err != nil {
+		return err
+	}
+	awsSecretKey, err := util.ResolveSecret(cfg.AWSSecretKey)
+	if err != nil {
+		return err
+	}
+	creds := credentials.NewStaticCredentials(awsAccessKey, awsSecretKey, "")
 
 	// Using us-west-1 region by default. The configuration needs a region set to
 	// get all regions available to the aws user.
@@ -509,7 +518,5 @@ func newAWSManager(currentState state.State, name string) error {
 		cfg.AWSInstanceType = result
 	}
 
-	currentState.SetManager(&cfg)
-
-	return nil
+	return setManager(currentState, &cfg)
 }
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
 	homedir "github.com/mitchellh/go-homedir"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-04-01/compute"
@@ -334,7 +335,11 @@ func newAzureManager(currentState state.State, name string) error {
 		return err
 	}
 
-	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, cfg.AzureClientSecret, azureEnv.ResourceManagerEndpoint)
+	azureClientSecret, err := util.ResolveSecret(cfg.AzureClientSecret)
+	if err != nil {
+		return err
+	}
+	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, azureClientSecret, azureEnv.ResourceManagerEndpoint)
 	if err != nil {
 		return err
 	}
@@ -572,7 +577,5 @@ func newAzureManager(currentState state.State, name string) error {
 		cfg.AzurePrivateKeyPath = expandedPrivateKeyPath
 	}
 
-	currentState.SetManager(&cfg)
-
-	return nil
+	return setManager(currentState, &cfg)
 }
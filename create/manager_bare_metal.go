@@ -144,7 +144,5 @@ func newBareMetalManager(currentState state.State, name string) error {
 	}
 	cfg.KeyPath = key_path
 
-	currentState.SetManager(&cfg)
-
-	return nil
+	return setManager(currentState, &cfg)
 }
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
 	"github.com/manifoldco/promptui"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -62,6 +63,10 @@ func newGCPManager(currentState state.State, name string) error {
 		prompt := promptui.Prompt{
 			Label: "Path to Google Cloud Platform Credentials File",
 			Validate: func(input string) error {
+				if state.IsSecretReference(input) {
+					return nil
+				}
+
 				expandedPath, err := homedir.Expand(input)
 				if err != nil {
 					return err
@@ -84,16 +89,11 @@ func newGCPManager(currentState state.State, name string) error {
 		rawGCPPathToCredentials = result
 	}
 
-	expandedGCPPathToCredentials, err := homedir.Expand(rawGCPPathToCredentials)
-	if err != nil {
-		return err
-	}
-	cfg.GCPPathToCredentials = expandedGCPPathToCredentials
-
-	gcpCredentials, err := ioutil.ReadFile(cfg.GCPPathToCredentials)
+	gcpPathToCredentials, gcpCredentials, err := readGCPCredentials(rawGCPPathToCredentials)
 	if err != nil {
 		return err
 	}
+	cfg.GCPPathToCredentials = gcpPathToCredentials
 
 	jwtCfg, err := google.JWTConfigFromJSON(gcpCredentials, "https://www.googleapis.com/auth/compute.readonly")
 	if err != nil {
@@ -416,7 +416,36 @@ func newGCPManager(currentState state.State, name string) error {
 		cfg.GCPSSHUser = result
 	}
 
-	currentState.SetManager(&cfg)
+	return setManager(currentState, &cfg)
+}
+
+// readGCPCredentials returns the value of gcp_path_to_credentials to store and the contents
+// of the credentials file. The value is the path of the file, or an encrypted value or
+// secret reference that resolves to the path, e.g. env:GCP_CREDENTIALS_PATH. A plain path
+// is stored with ~ expanded.
+func readGCPCredentials(value string) (string, []byte, error) {
+	if strings.HasPrefix(value, "file:") {
+		return "", nil, errors.New("gcp_path_to_credentials is the path of the credentials file, give the path instead of a file: reference")
+	}
+
+	gcpPathToCredentials, err := util.ResolveSecret(value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	expandedPath, err := homedir.Expand(gcpPathToCredentials)
+	if err != nil {
+		return "", nil, err
+	}
+
+	gcpCredentials, err := ioutil.ReadFile(expandedPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if gcpPathToCredentials == value {
+		return expandedPath, gcpCredentials, nil
+	}
 
-	return nil
+	return value, gcpCredentials, nil
 }
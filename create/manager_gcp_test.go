@@ -0,0 +1,51 @@
+package create
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadGCPCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	credentialsPath := filepath.Join(dir, "gcp.json")
+	ioutil.WriteFile(credentialsPath, []byte(`{"type": "service_account"}`), 0600)
+
+	os.Setenv("TK_TEST_GCP_CREDENTIALS", credentialsPath)
+	defer os.Unsetenv("TK_TEST_GCP_CREDENTIALS")
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		// A path is stored as is, a reference to a path is stored instead of the path
+		{credentialsPath, credentialsPath},
+		{"env:TK_TEST_GCP_CREDENTIALS", "env:TK_TEST_GCP_CREDENTIALS"},
+	}
+
+	for _, test := range tests {
+		actual, credentials, err := readGCPCredentials(test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.value, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("%s: wrong output, expected %s, received %s", test.value, test.expected, actual)
+		}
+		if string(credentials) != `{"type": "service_account"}` {
+			t.Errorf("%s: wrong credentials, received %s", test.value, credentials)
+		}
+	}
+
+	// The value is a path, a file: reference would resolve to the credentials themselves
+	_, _, err = readGCPCredentials("file:" + credentialsPath)
+	if err == nil {
+		t.Error("Expected a file: reference to be refused")
+	}
+}
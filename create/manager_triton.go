@@ -393,7 +393,5 @@ func newTritonManager(currentState state.State, name string) error {
 		cfg.MasterTritonMachinePackage = packages[i].Name
 	}
 
-	currentState.SetManager(&cfg)
-
-	return nil
+	return setManager(currentState, &cfg)
 }
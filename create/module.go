@@ -0,0 +1,79 @@
+package create
+
+import (
+	"encoding/json"
+
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+)
+
+// The provider specific configs, e.g. tritonClusterTerraformConfig, are stored as the typed
+// module blocks of the state package. Fields a typed block doesn't cover, e.g. machine
+// packages, are kept in its Fields, so the stored JSON is the JSON the config encodes to.
+
+func setManager(currentState state.State, cfg interface{}) error {
+	var manager state.Manager
+	err := decodeConfig(cfg, &manager)
+	if err != nil {
+		return err
+	}
+
+	return currentState.SetManager(manager)
+}
+
+func setCluster(currentState state.State, provider, name string, cfg interface{}) error {
+	var cluster state.Cluster
+	err := decodeConfig(cfg, &cluster)
+	if err != nil {
+		return err
+	}
+
+	return currentState.SetCluster(provider, name, cluster)
+}
+
+func setNode(currentState state.State, clusterKey, name string, cfg interface{}) error {
+	var node state.Node
+	err := decodeConfig(cfg, &node)
+	if err != nil {
+		return err
+	}
+
+	return currentState.SetNode(clusterKey, name, node)
+}
+
+func setBackup(currentState state.State, clusterKey string, cfg interface{}) error {
+	var backup state.Backup
+	err := decodeConfig(cfg, &backup)
+	if err != nil {
+		return err
+	}
+
+	return currentState.SetBackup(clusterKey, backup)
+}
+
+// clusterWithSecrets returns the cluster with clusterKey with its secrets in plaintext.
+// Encrypted secrets only decrypt where they are stored, so the secrets a node copies from
+// its cluster are copied in plaintext and encrypted again when the node is stored.
+func clusterWithSecrets(currentState state.State, clusterKey string) (state.Cluster, error) {
+	decrypted, err := currentState.Clone()
+	if err != nil {
+		return state.Cluster{}, err
+	}
+
+	err = util.DecryptSecrets(&decrypted)
+	if err != nil {
+		return state.Cluster{}, err
+	}
+
+	return decrypted.Cluster(clusterKey)
+}
+
+// decodeConfig decodes the JSON cfg encodes to into module.
+func decodeConfig(cfg interface{}, module interface{}) error {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, module)
+}
@@ -43,7 +43,7 @@ type rancherHostLabelsConfig struct {
 	Worker  string `json:"worker,omitempty"`
 }
 
-func NewNode(remoteBackend backend.Backend) error {
+func NewNode(remoteBackend backend.Backend, runner shell.Runner) error {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
@@ -91,6 +91,14 @@ func NewNode(remoteBackend backend.Backend) error {
 		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
 	}
 
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("create node")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
 	currentState, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
 		return err
@@ -142,6 +150,15 @@ func NewNode(remoteBackend backend.Backend) error {
 		return err
 	}
 
+	// With --plan only show what terraform would change, nothing is stored
+	if viper.GetBool("plan") {
+		err = runner.Init(currentState)
+		if err != nil {
+			return err
+		}
+		return runner.Plan(currentState, []string{}, currentState)
+	}
+
 	// Confirmation Prompt
 	if !nonInteractiveMode {
 		label := "Proceed with the node creation"
@@ -157,7 +174,11 @@ func NewNode(remoteBackend backend.Backend) error {
 	}
 
 	// Get the new state and run terraform apply
-	err = shell.RunTerraformApplyWithState(currentState)
+	err = runner.Init(currentState)
+	if err != nil {
+		return err
+	}
+	err = runner.Apply(currentState)
 	if err != nil {
 		return err
 	}
@@ -173,13 +194,22 @@ func NewNode(remoteBackend backend.Backend) error {
 
 func newNode(selectedClusterManager, selectedClusterKey string, remoteBackend backend.Backend, currentState state.State) ([]string, error) {
 	// Determine which cloud the selected cluster is in and call the appropriate newNode func
-	parts := strings.Split(selectedClusterKey, "_")
-	if len(parts) < 3 {
-		// clusterKey is `cluster_{provider}_{clusterName}`
+	provider, err := currentState.ClusterProvider(selectedClusterKey)
+	if err != nil {
 		return []string{}, fmt.Errorf("Could not determine cloud provider for cluster '%s'", selectedClusterKey)
 	}
 
-	switch parts[1] {
+	// Running hosts are adopted with the bare metal host module, which only needs SSH access
+	// to them, so it works for clusters of any provider that registers custom nodes
+	if viper.GetBool("adopt") {
+		switch provider {
+		case "gke", "aks", "imported":
+			return []string{}, fmt.Errorf("Cloud provider '%s' doesn't support adopting nodes", provider)
+		}
+		return newBareMetalNode(selectedClusterManager, selectedClusterKey, remoteBackend, currentState)
+	}
+
+	switch provider {
 	case "triton":
 		return newTritonNode(selectedClusterManager, selectedClusterKey, remoteBackend, currentState)
 	case "aws":
@@ -193,24 +223,34 @@ func newNode(selectedClusterManager, selectedClusterKey string, remoteBackend ba
 	case "vsphere":
 		return newVSphereNode(selectedClusterManager, selectedClusterKey, remoteBackend, currentState)
 	default:
-		return []string{}, fmt.Errorf("Unsupported cloud provider '%s', cannot create node", parts[1])
+		return []string{}, fmt.Errorf("Unsupported cloud provider '%s', cannot create node", provider)
 	}
 }
 
 func getBaseNodeTerraformConfig(terraformModulePath, selectedCluster string, currentState state.State) (baseNodeTerraformConfig, error) {
+	manager, err := currentState.Manager()
+	if err != nil {
+		return baseNodeTerraformConfig{}, err
+	}
+
+	cluster, err := clusterWithSecrets(currentState, selectedCluster)
+	if err != nil {
+		return baseNodeTerraformConfig{}, err
+	}
+
 	cfg := baseNodeTerraformConfig{
 		RancherAPIURL:                   "${module.cluster-manager.rancher_url}",
 		RancherClusterRegistrationToken: fmt.Sprintf("${module.%s.rancher_cluster_registration_token}", selectedCluster),
 		RancherClusterCAChecksum:        fmt.Sprintf("${module.%s.rancher_cluster_ca_checksum}", selectedCluster),
 
-		RancherAgentImage: currentState.Get("module.cluster-manager.rancher_agent_image"),
+		RancherAgentImage: manager.RancherAgentImage,
 
 		// Grab registry variables from cluster config
-		RancherRegistry:         currentState.Get(fmt.Sprintf("module.%s.rancher_registry", selectedCluster)),
-		RancherRegistryUsername: currentState.Get(fmt.Sprintf("module.%s.rancher_registry_username", selectedCluster)),
-		RancherRegistryPassword: currentState.Get(fmt.Sprintf("module.%s.rancher_registry_password", selectedCluster)),
+		RancherRegistry:         cluster.RancherRegistry,
+		RancherRegistryUsername: cluster.RancherRegistryUsername,
+		RancherRegistryPassword: cluster.RancherRegistryPassword,
 
-		DockerEngineInstallURL: currentState.Get(fmt.Sprintf("module.%s.docker_engine_install_url", selectedCluster)),
+		DockerEngineInstallURL: cluster.DockerEngineInstallURL,
 	}
 
 	baseSource := defaultSourceURL
@@ -223,7 +263,7 @@ func getBaseNodeTerraformConfig(terraformModulePath, selectedCluster string, cur
 		baseSourceRef = viper.GetString("source_ref")
 	}
 
-	_, err := os.Stat(baseSource)
+	_, err = os.Stat(baseSource)
 	if err != nil {
 		// Module Source location e.g. github.com/joyent/triton-kubernetes//terraform/modules/triton-rancher?ref=master
 		cfg.Source = fmt.Sprintf("%s//%s?ref=%s", baseSource, terraformModulePath, baseSourceRef)
@@ -0,0 +1,103 @@
+package create
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+var adoptNodeClusterManager = []byte(`{
+	"module": {
+		"cluster-manager": {"source": "./terraform/modules/aws-rancher", "name": "dev-manager"},
+		"cluster_aws_dev": {
+			"source": "./terraform/modules/aws-rancher-k8s",
+			"name": "dev",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_access_key": "${module.cluster-manager.rancher_access_key}",
+			"rancher_secret_key": "${module.cluster-manager.rancher_secret_key}",
+			"aws_access_key": "AKIAEXAMPLE",
+			"aws_secret_key": "secret",
+			"aws_region": "us-west-2"
+		},
+		"cluster_gke_hosted": {
+			"source": "./terraform/modules/gke-rancher-k8s",
+			"name": "hosted"
+		}
+	}
+}`)
+
+func setAdoptNodeConfig(clusterName string) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("terraform-configuration", true)
+	viper.Set("adopt", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", clusterName)
+	viper.Set("rancher_host_label", "worker")
+	viper.Set("ssh_user", "ubuntu")
+	viper.Set("bastion_host", "")
+	viper.Set("key_path", "~/.ssh/id_rsa")
+	viper.Set("hosts", []string{"10.0.0.5", "10.0.0.6"})
+}
+
+func TestAdoptNode(t *testing.T) {
+	setAdoptNodeConfig("dev")
+
+	currentState, _ := state.Load("dev-manager", adoptNodeClusterManager)
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+	localBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		nodes, err := s.Nodes("cluster_aws_dev")
+		if err != nil || len(nodes) != 2 {
+			return false
+		}
+
+		nodeKey := nodes["10.0.0.5"]
+		for _, finding := range s.Validate() {
+			if finding.Module == nodeKey {
+				return false
+			}
+		}
+
+		return strings.Contains(s.Get("module."+nodeKey+".source"), bareMetalRancherKubernetesHostTerraformModulePath) &&
+			s.Get("module."+nodeKey+".host") == "10.0.0.5"
+	})).Return(nil)
+
+	err := NewNode(localBackend, shelltest.New())
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
+
+func TestAdoptNodeUnsupportedProvider(t *testing.T) {
+	setAdoptNodeConfig("hosted")
+
+	currentState, _ := state.Load("dev-manager", adoptNodeClusterManager)
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	expected := "Cloud provider 'gke' doesn't support adopting nodes"
+
+	err := NewNode(localBackend, shelltest.New())
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	localBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
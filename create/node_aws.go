@@ -80,13 +80,18 @@ func newAWSNode(selectedClusterManager, selectedCluster string, remoteBackend ba
 		return []string{}, err
 	}
 
+	cluster, err := clusterWithSecrets(currentState, selectedCluster)
+	if err != nil {
+		return []string{}, err
+	}
+
 	cfg := awsNodeTerraformConfig{
 		baseNodeTerraformConfig: baseConfig,
 
 		// Grab variables from cluster config
-		AWSAccessKey: currentState.Get(fmt.Sprintf("module.%s.aws_access_key", selectedCluster)),
-		AWSSecretKey: currentState.Get(fmt.Sprintf("module.%s.aws_secret_key", selectedCluster)),
-		AWSRegion:    currentState.Get(fmt.Sprintf("module.%s.aws_region", selectedCluster)),
+		AWSAccessKey: cluster.AWSAccessKey,
+		AWSSecretKey: cluster.AWSSecretKey,
+		AWSRegion:    cluster.AWSRegion,
 
 		// Reference terraform output variables from cluster module
 		AWSSubnetID:        fmt.Sprintf("${module.%s.aws_subnet_id}", selectedCluster),
@@ -94,7 +99,15 @@ func newAWSNode(selectedClusterManager, selectedCluster string, remoteBackend ba
 		AWSKeyName:         fmt.Sprintf("${module.%s.aws_key_name}", selectedCluster),
 	}
 
-	creds := credentials.NewStaticCredentials(cfg.AWSAccessKey, cfg.AWSSecretKey, "")
+	awsAccessKey, err := util.ResolveSecret(cfg.AWSAccessKey)
+	if err != nil {
+		return []string{}, err
+	}
+	awsSecretKey, err := util.ResolveSecret(cfg.AWSSecretKey)
+	if err != nil {
+		return []string{}, err
+	}
+	creds := credentials.NewStaticCredentials(awsAccessKey, awsSecretKey, "")
 
 	awsConfig := aws.NewConfig().WithCredentials(creds).WithRegion(cfg.AWSRegion)
 	sess, err := session.NewSession(awsConfig)
@@ -354,7 +367,7 @@ func newAWSNode(selectedClusterManager, selectedCluster string, remoteBackend ba
 	for _, newHostname := range newHostnames {
 		cfgCopy := cfg
 		cfgCopy.Hostname = newHostname
-		err = currentState.AddNode(selectedCluster, newHostname, cfgCopy)
+		err = setNode(currentState, selectedCluster, newHostname, cfgCopy)
 		if err != nil {
 			return []string{}, err
 		}
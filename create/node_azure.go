@@ -73,16 +73,21 @@ func newAzureNode(selectedClusterManager, selectedCluster string, remoteBackend
 		return []string{}, err
 	}
 
+	cluster, err := clusterWithSecrets(currentState, selectedCluster)
+	if err != nil {
+		return []string{}, err
+	}
+
 	cfg := azureNodeTerraformConfig{
 		baseNodeTerraformConfig: baseConfig,
 
 		// Grab variables from cluster config
-		AzureSubscriptionID: currentState.Get(fmt.Sprintf("module.%s.azure_subscription_id", selectedCluster)),
-		AzureClientID:       currentState.Get(fmt.Sprintf("module.%s.azure_client_id", selectedCluster)),
-		AzureClientSecret:   currentState.Get(fmt.Sprintf("module.%s.azure_client_secret", selectedCluster)),
-		AzureTenantID:       currentState.Get(fmt.Sprintf("module.%s.azure_tenant_id", selectedCluster)),
-		AzureEnvironment:    currentState.Get(fmt.Sprintf("module.%s.azure_environment", selectedCluster)),
-		AzureLocation:       currentState.Get(fmt.Sprintf("module.%s.azure_location", selectedCluster)),
+		AzureSubscriptionID: cluster.AzureSubscriptionID,
+		AzureClientID:       cluster.AzureClientID,
+		AzureClientSecret:   cluster.AzureClientSecret,
+		AzureTenantID:       cluster.AzureTenantID,
+		AzureEnvironment:    cluster.AzureEnvironment,
+		AzureLocation:       cluster.AzureLocation,
 
 		// Reference terraform output variables from cluster module
 		AzureResourceGroupName:      fmt.Sprintf("${module.%s.azure_resource_group_name}", selectedCluster),
@@ -102,7 +107,11 @@ func newAzureNode(selectedClusterManager, selectedCluster string, remoteBackend
 		return []string{}, err
 	}
 
-	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, cfg.AzureClientSecret, azureEnv.ResourceManagerEndpoint)
+	azureClientSecret, err := util.ResolveSecret(cfg.AzureClientSecret)
+	if err != nil {
+		return []string{}, err
+	}
+	azureSPT, err := adal.NewServicePrincipalToken(*oauthConfig, cfg.AzureClientID, azureClientSecret, azureEnv.ResourceManagerEndpoint)
 	if err != nil {
 		return []string{}, err
 	}
@@ -315,7 +324,7 @@ func newAzureNode(selectedClusterManager, selectedCluster string, remoteBackend
 	for _, newHostname := range newHostnames {
 		cfgCopy := cfg
 		cfgCopy.Hostname = newHostname
-		err = currentState.AddNode(selectedCluster, newHostname, cfgCopy)
+		err = setNode(currentState, selectedCluster, newHostname, cfgCopy)
 		if err != nil {
 			return []string{}, err
 		}
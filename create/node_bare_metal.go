@@ -28,6 +28,7 @@ type bareMetalNodeTerraformConfig struct {
 }
 
 // Adds new Bare Metal nodes to the given cluster and manager.
+// Also used to adopt running hosts as nodes of clusters of other providers.
 // Returns:
 // - a slice of the hostnames added
 // - the new state
@@ -188,7 +189,7 @@ func newBareMetalNode(selectedClusterManager, selectedCluster string, remoteBack
 		// State names can't contain . so replace with _
 		hostname := strings.Replace(host, ".", "_", -1)
 
-		err = currentState.AddNode(selectedCluster, hostname, cfgCopy)
+		err = setNode(currentState, selectedCluster, hostname, cfgCopy)
 		if err != nil {
 			return []string{}, err
 		}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"sort"
 	"strings"
 
@@ -62,20 +61,25 @@ func newGCPNode(selectedClusterManager, selectedCluster string, remoteBackend ba
 		return []string{}, err
 	}
 
+	cluster, err := clusterWithSecrets(currentState, selectedCluster)
+	if err != nil {
+		return []string{}, err
+	}
+
 	cfg := gcpNodeTerraformConfig{
 		baseNodeTerraformConfig: baseConfig,
 
 		// Grab variables from cluster config
-		GCPPathToCredentials: currentState.Get(fmt.Sprintf("module.%s.gcp_path_to_credentials", selectedCluster)),
-		GCPProjectID:         currentState.Get(fmt.Sprintf("module.%s.gcp_project_id", selectedCluster)),
-		GCPComputeRegion:     currentState.Get(fmt.Sprintf("module.%s.gcp_compute_region", selectedCluster)),
+		GCPPathToCredentials: cluster.GCPPathToCredentials,
+		GCPProjectID:         cluster.GCPProjectID,
+		GCPComputeRegion:     cluster.GCPComputeRegion,
 
 		// Reference terraform output variables from cluster module
 		GCPComputeNetworkName:     fmt.Sprintf("${module.%s.gcp_compute_network_name}", selectedCluster),
 		GCPComputeFirewallHostTag: fmt.Sprintf("${module.%s.gcp_compute_firewall_host_tag}", selectedCluster),
 	}
 
-	gcpCredentials, err := ioutil.ReadFile(cfg.GCPPathToCredentials)
+	_, gcpCredentials, err := readGCPCredentials(cfg.GCPPathToCredentials)
 	if err != nil {
 		return []string{}, err
 	}
@@ -368,7 +372,7 @@ func newGCPNode(selectedClusterManager, selectedCluster string, remoteBackend ba
 	for _, newHostname := range newHostnames {
 		cfgCopy := cfg
 		cfgCopy.Hostname = newHostname
-		err = currentState.AddNode(selectedCluster, newHostname, cfgCopy)
+		err = setNode(currentState, selectedCluster, newHostname, cfgCopy)
 		if err != nil {
 			return []string{}, err
 		}
@@ -3,6 +3,10 @@ package create
 import (
 	"fmt"
 	"testing"
+
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
 )
 
 var getNewHostnamesTestCases = []struct {
@@ -46,3 +50,18 @@ func isEqual(expected, actual []string) bool {
 	}
 	return true
 }
+
+func TestGetBaseNodeTerraformConfigWithoutManager(t *testing.T) {
+	viper.Reset()
+	viper.Set("rancher_host_label", "worker")
+	defer viper.Reset()
+
+	// A cluster without the cluster manager module its nodes register with
+	currentState, _ := state.Load("dev-manager", []byte(`{"module":{"cluster_triton_dev":{"name":"dev"}}}`))
+
+	_, err := getBaseNodeTerraformConfig(tritonRancherKubernetesHostTerraformModulePath, "cluster_triton_dev", currentState)
+	expected := "Could not find module 'cluster-manager'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
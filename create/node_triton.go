@@ -60,14 +60,19 @@ func newTritonNode(selectedClusterManager, selectedCluster string, remoteBackend
 		return []string{}, err
 	}
 
+	cluster, err := clusterWithSecrets(currentState, selectedCluster)
+	if err != nil {
+		return []string{}, err
+	}
+
 	cfg := tritonNodeTerraformConfig{
 		baseNodeTerraformConfig: baseConfig,
 
 		// Grab variables from cluster config
-		TritonAccount: currentState.Get(fmt.Sprintf("module.%s.triton_account", selectedCluster)),
-		TritonKeyPath: currentState.Get(fmt.Sprintf("module.%s.triton_key_path", selectedCluster)),
-		TritonKeyID:   currentState.Get(fmt.Sprintf("module.%s.triton_key_id", selectedCluster)),
-		TritonURL:     currentState.Get(fmt.Sprintf("module.%s.triton_url", selectedCluster)),
+		TritonAccount: cluster.TritonAccount,
+		TritonKeyPath: cluster.TritonKeyPath,
+		TritonKeyID:   cluster.TritonKeyID,
+		TritonURL:     cluster.TritonURL,
 	}
 
 	keyMaterial, err := ioutil.ReadFile(cfg.TritonKeyPath)
@@ -318,7 +323,7 @@ func newTritonNode(selectedClusterManager, selectedCluster string, remoteBackend
 	for _, newHostname := range newHostnames {
 		cfgCopy := cfg
 		cfgCopy.Hostname = newHostname
-		err = currentState.AddNode(selectedCluster, newHostname, cfgCopy)
+		err = setNode(currentState, selectedCluster, newHostname, cfgCopy)
 		if err != nil {
 			return []string{}, err
 		}
@@ -56,13 +56,18 @@ func newVSphereNode(selectedClusterManager, selectedCluster string, remoteBacken
 		return []string{}, err
 	}
 
+	cluster, err := clusterWithSecrets(currentState, selectedCluster)
+	if err != nil {
+		return []string{}, err
+	}
+
 	cfg := vSphereNodeTerraformConfig{
 		baseNodeTerraformConfig: baseConfig,
 
 		// Grab variables from cluster config
-		VSphereUser:     currentState.Get(fmt.Sprintf("module.%s.vsphere_user", selectedCluster)),
-		VSpherePassword: currentState.Get(fmt.Sprintf("module.%s.vsphere_password", selectedCluster)),
-		VSphereServer:   currentState.Get(fmt.Sprintf("module.%s.vsphere_server", selectedCluster)),
+		VSphereUser:     cluster.VSphereUser,
+		VSpherePassword: cluster.VSpherePassword,
+		VSphereServer:   cluster.VSphereServer,
 
 		// Reference terraform output variables from cluster module
 		VSphereDatacenterName:   fmt.Sprintf("${module.%s.vsphere_datacenter_name}", selectedCluster),
@@ -170,7 +175,7 @@ func newVSphereNode(selectedClusterManager, selectedCluster string, remoteBacken
 	for _, newHostname := range newHostnames {
 		cfgCopy := cfg
 		cfgCopy.Hostname = newHostname
-		err = currentState.AddNode(selectedCluster, newHostname, cfgCopy)
+		err = setNode(currentState, selectedCluster, newHostname, cfgCopy)
 		if err != nil {
 			return []string{}, err
 		}
@@ -7,13 +7,14 @@ import (
 
 	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/state"
 	"github.com/joyent/triton-kubernetes/util"
 
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/viper"
 )
 
-func DeleteCluster(remoteBackend backend.Backend) error {
+func DeleteCluster(remoteBackend backend.Backend, runner shell.Runner) error {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
@@ -61,6 +62,23 @@ func DeleteCluster(remoteBackend backend.Backend) error {
 		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
 	}
 
+	// The directory can exist without a configuration, e.g. a canceled create
+	exists, err := remoteBackend.StateExists(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Cluster manager '%s' not found.", selectedClusterManager)
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("destroy cluster")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
 	state, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
 		return err
@@ -109,10 +127,14 @@ func DeleteCluster(remoteBackend backend.Backend) error {
 		selectedClusterKey = clusters[value]
 	}
 
-	// Confirmation
-	if !nonInteractiveMode {
+	// Confirmation, not needed when only planning
+	if !nonInteractiveMode && !viper.GetBool("plan") {
 		label := fmt.Sprintf("Are you sure you want to destroy %q", clusterName)
 		selected := fmt.Sprintf("Destroy %q", clusterName)
+		if viper.GetBool("keep-infra") {
+			label = fmt.Sprintf("Are you sure you want to remove %q and keep its infrastructure", clusterName)
+			selected = fmt.Sprintf("Remove %q", clusterName)
+		}
 		confirmed, err := util.PromptForConfirmation(label, selected)
 		if err != nil {
 			return err
@@ -128,49 +150,96 @@ func DeleteCluster(remoteBackend backend.Backend) error {
 		return err
 	}
 
-	args := []string{
-		fmt.Sprintf("-target=module.%s", selectedClusterKey),
-	}
+	moduleKeys := []string{selectedClusterKey}
 
-	// Delete all nodes in the selected cluster
+	// Delete all nodes in the selected cluster, in a stable order
+	nodeKeys := []string{}
 	for _, node := range nodes {
-		args = append(args, fmt.Sprintf("-target=module.%s", node))
+		nodeKeys = append(nodeKeys, node)
 	}
+	sort.Strings(nodeKeys)
+	moduleKeys = append(moduleKeys, nodeKeys...)
 
 	// Delete the cluster backup
 	backupKey := state.Backup(selectedClusterKey)
 	if backupKey != "" {
-		args = append(args, fmt.Sprintf("-target=module.%s", backupKey))
+		moduleKeys = append(moduleKeys, backupKey)
 	}
 
-	// Run terraform destroy
-	err = shell.RunTerraformDestroyWithState(state, args)
-	if err != nil {
-		return err
+	// Terraform addresses of the modules, e.g. module.cluster_triton_dev
+	modules := []string{}
+	for _, key := range moduleKeys {
+		modules = append(modules, fmt.Sprintf("module.%s", key))
 	}
 
-	// Remove cluster from terraform config
-	err = state.Delete(fmt.Sprintf("module.%s", selectedClusterKey))
-	if err != nil {
-		return err
-	}
+	// With --plan only show what would be removed, nothing is stored
+	if viper.GetBool("plan") {
+		if viper.GetBool("keep-infra") {
+			if viper.GetString("out") != "" {
+				return errors.New("--keep-infra only changes the terraform state, there is no plan to save")
+			}
+			fmt.Println("Modules that would be removed from the terraform state, their infrastructure is kept:")
+			for _, module := range modules {
+				fmt.Printf("  - %s\n", module)
+			}
+			return nil
+		}
 
-	// Remove all nodes associated to this cluster from terraform config
-	for _, node := range nodes {
-		err = state.Delete(fmt.Sprintf("module.%s", node))
+		args := []string{"-destroy"}
+		for _, module := range modules {
+			args = append(args, fmt.Sprintf("-target=%s", module))
+		}
+
+		// A saved plan stores the configuration without the destroyed modules
+		plannedState, err := state.Clone()
 		if err != nil {
 			return err
 		}
+		err = deleteModules(&plannedState, moduleKeys)
+		if err != nil {
+			return err
+		}
+
+		err = runner.Init(state)
+		if err != nil {
+			return err
+		}
+		return runner.Plan(state, args, plannedState)
 	}
 
-	// Remove backup from terraform config
-	if backupKey != "" {
-		err = state.Delete(fmt.Sprintf("module.%s", backupKey))
+	if viper.GetBool("keep-infra") {
+		// Only forget the modules, the infrastructure and the Rancher cluster are kept
+		err = runner.Init(state)
+		if err != nil {
+			return err
+		}
+		err = runner.StateRm(state, modules)
+		if err != nil {
+			return err
+		}
+	} else {
+		args := []string{}
+		for _, module := range modules {
+			args = append(args, fmt.Sprintf("-target=%s", module))
+		}
+
+		// Run terraform destroy
+		err = runner.Init(state)
+		if err != nil {
+			return err
+		}
+		err = runner.Destroy(state, args)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Remove the cluster, its nodes and its backup from terraform config
+	err = deleteModules(&state, moduleKeys)
+	if err != nil {
+		return err
+	}
+
 	// After terraform succeeds, commit state
 	err = remoteBackend.PersistState(state)
 	if err != nil {
@@ -179,3 +248,15 @@ func DeleteCluster(remoteBackend backend.Backend) error {
 
 	return nil
 }
+
+// deleteModules removes the modules with the given keys from the terraform config.
+func deleteModules(currentState *state.State, moduleKeys []string) error {
+	for _, key := range moduleKeys {
+		err := currentState.DeleteModule(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,11 +1,15 @@
 package destroy
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
 	"github.com/joyent/triton-kubernetes/state"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
 )
 
 var mockClusters = []byte(`{
@@ -23,7 +27,7 @@ func TestDeleteClusterNoClusterManager(t *testing.T) {
 
 	expected := "No cluster managers."
 
-	err := DeleteCluster(localBackend)
+	err := DeleteCluster(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -38,7 +42,7 @@ func TestDeleteClusterMissingClusterManager(t *testing.T) {
 
 	expected := "cluster_manager must be specified"
 
-	err := DeleteCluster(localBackend)
+	err := DeleteCluster(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -54,7 +58,7 @@ func TestDeleteClusterManagerNotExist(t *testing.T) {
 
 	expected := "Selected cluster manager 'prod-manager' does not exist."
 
-	err := DeleteCluster(localBackend)
+	err := DeleteCluster(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -65,15 +69,18 @@ func TestDeleteClusterMustSpecifyClusterName(t *testing.T) {
 	viper.Set("non-interactive", true)
 	viper.Set("cluster_manager", "dev-manager")
 
-	stateObj, _ := state.New("ClusterState", mockClusters)
+	stateObj, _ := state.Load("ClusterState", mockClusters)
 
 	backend := &mocks.Backend{}
 	backend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
 	backend.On("State", "dev-manager").Return(stateObj, nil)
 
 	expected := "cluster_name must be specified"
 
-	err := DeleteCluster(backend)
+	err := DeleteCluster(backend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -85,16 +92,243 @@ func TestDeleteClusterNotExist(t *testing.T) {
 	viper.Set("cluster_manager", "dev-manager")
 	viper.Set("cluster_name", "cluster_alpha")
 
-	stateObj, _ := state.New("ClusterState", mockClusters)
+	stateObj, _ := state.Load("ClusterState", mockClusters)
 
 	backend := &mocks.Backend{}
 	backend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
 	backend.On("State", "dev-manager").Return(stateObj, nil)
 
 	expected := "A cluster named 'cluster_alpha', does not exist."
 
-	err := DeleteCluster(backend)
+	err := DeleteCluster(backend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
 }
+
+func TestDeleteClusterKeepInfra(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("keep-infra", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "existing")
+
+	stateObj, _ := state.Load("dev-manager", []byte(`{
+		"module": {
+			"cluster-manager": {"name": "dev-manager"},
+			"cluster_imported_existing": {"name": "existing", "rancher_cluster_id": "c-abcde"},
+			"backup_cluster_imported_existing": {"rancher_cluster_id": "${module.cluster_imported_existing.rancher_cluster_id}"}
+		}
+	}`))
+
+	backend := &mocks.Backend{}
+	backend.On("States").Return([]string{"dev-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
+	backend.On("State", "dev-manager").Return(stateObj, nil)
+	backend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		clusters, err := s.Clusters()
+		return err == nil && len(clusters) == 0 && s.Backup("cluster_imported_existing") == ""
+	})).Return(nil)
+
+	runner := shelltest.New()
+	err := DeleteCluster(backend, runner)
+	if err != nil {
+		t.Error(err)
+	}
+
+	backend.AssertExpectations(t)
+
+	// Only forgotten by terraform, nothing is destroyed
+	expectCommands(t, runner, "init", "state-rm")
+	stateRm, _ := runner.Last("state-rm")
+	expectArgs(t, stateRm, "module.cluster_imported_existing", "module.backup_cluster_imported_existing")
+}
+
+func TestDeleteClusterKeepInfraPlan(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("keep-infra", true)
+	viper.Set("plan", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "existing")
+
+	stateObj, _ := state.Load("dev-manager", []byte(`{
+		"module": {
+			"cluster-manager": {"name": "dev-manager"},
+			"cluster_imported_existing": {"name": "existing", "rancher_cluster_id": "c-abcde"}
+		}
+	}`))
+
+	// PersistState is not expected, planning never stores the configuration
+	backend := &mocks.Backend{}
+	backend.On("States").Return([]string{"dev-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
+	backend.On("State", "dev-manager").Return(stateObj, nil)
+
+	runner := shelltest.New()
+	err := DeleteCluster(backend, runner)
+	if err != nil {
+		t.Error(err)
+	}
+
+	backend.AssertExpectations(t)
+	backend.AssertNotCalled(t, "PersistState", mock.Anything)
+	expectCommands(t, runner)
+}
+
+// newTestClusterState returns the configuration of dev-manager with the triton cluster dev,
+// its nodes dev-worker-1 and dev-worker-2 and a backup.
+func newTestClusterState(t *testing.T) state.State {
+	currentState, _ := state.New("dev-manager", []byte(`{}`))
+
+	err := currentState.SetManager(state.Manager{Name: "dev-manager"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = currentState.SetCluster("triton", "dev", state.Cluster{Name: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hostname := range []string{"dev-worker-1", "dev-worker-2"} {
+		err = currentState.SetNode("cluster_triton_dev", hostname, state.Node{Hostname: hostname})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = currentState.SetBackup("cluster_triton_dev", state.Backup{RancherClusterID: "c-abcde"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return currentState
+}
+
+// newTestClusterBackend returns a backend with dev-manager, see newTestClusterState.
+func newTestClusterBackend(currentState state.State) *mocks.Backend {
+	backend := &mocks.Backend{}
+	backend.On("States").Return([]string{"dev-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
+	backend.On("State", "dev-manager").Return(currentState, nil)
+
+	return backend
+}
+
+func expectCommands(t *testing.T, runner *shelltest.Runner, expected ...string) {
+	if expected == nil {
+		expected = []string{}
+	}
+	if !reflect.DeepEqual(runner.Commands(), expected) {
+		t.Errorf("Expected terraform commands %v, got %v", expected, runner.Commands())
+	}
+}
+
+func expectArgs(t *testing.T, invocation shelltest.Invocation, expected ...string) {
+	if expected == nil {
+		expected = []string{}
+	}
+	if !reflect.DeepEqual(invocation.Args, expected) {
+		t.Errorf("Expected terraform %s arguments %v, got %v", invocation.Command, expected, invocation.Args)
+	}
+}
+
+func TestDeleteCluster(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "dev")
+
+	backend := newTestClusterBackend(newTestClusterState(t))
+	backend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		clusters, err := s.Clusters()
+		_, hasNode := s.Value("module.node_triton_dev__dev-worker-1")
+		return err == nil && len(clusters) == 0 && !hasNode && s.Backup("cluster_triton_dev") == ""
+	})).Return(nil)
+
+	runner := shelltest.New()
+	err := DeleteCluster(backend, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.AssertExpectations(t)
+	expectCommands(t, runner, "init", "destroy")
+
+	// The cluster, all of its nodes and its backup are targeted
+	destroy, _ := runner.Last("destroy")
+	expectArgs(t, destroy,
+		"-target=module.cluster_triton_dev",
+		"-target=module.node_triton_dev__dev-worker-1",
+		"-target=module.node_triton_dev__dev-worker-2",
+		"-target=module.backup_cluster_triton_dev",
+	)
+}
+
+func TestDeleteClusterPlan(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("plan", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "dev")
+
+	backend := newTestClusterBackend(newTestClusterState(t))
+
+	runner := shelltest.New()
+	err := DeleteCluster(backend, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.AssertNotCalled(t, "PersistState", mock.Anything)
+	expectCommands(t, runner, "init", "plan")
+
+	plan, _ := runner.Last("plan")
+	expectArgs(t, plan,
+		"-destroy",
+		"-target=module.cluster_triton_dev",
+		"-target=module.node_triton_dev__dev-worker-1",
+		"-target=module.node_triton_dev__dev-worker-2",
+		"-target=module.backup_cluster_triton_dev",
+	)
+
+	// A saved plan stores the configuration without the destroyed modules
+	for _, module := range []string{"cluster_triton_dev", "node_triton_dev__dev-worker-1", "node_triton_dev__dev-worker-2", "backup_cluster_triton_dev"} {
+		if _, ok := plan.PlannedState.Value("module." + module); ok {
+			t.Errorf("Expected module.%s to be removed from the planned configuration", module)
+		}
+		if _, ok := plan.State.Value("module." + module); !ok {
+			t.Errorf("Expected module.%s to be kept in the configuration terraform plans with", module)
+		}
+	}
+	if _, ok := plan.PlannedState.Value("module.cluster-manager"); !ok {
+		t.Error("Expected the cluster manager to be kept in the planned configuration")
+	}
+}
+
+func TestDeleteClusterDestroyFails(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "dev")
+
+	backend := newTestClusterBackend(newTestClusterState(t))
+
+	runner := shelltest.New()
+	runner.Errors["destroy"] = errors.New("exit status 1")
+
+	err := DeleteCluster(backend, runner)
+	if err == nil || err.Error() != "exit status 1" {
+		t.Errorf("Expected the terraform error, got %v", err)
+	}
+
+	// The configuration still has the cluster terraform failed to destroy
+	backend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
@@ -13,7 +13,7 @@ import (
 	"github.com/spf13/viper"
 )
 
-func DeleteManager(remoteBackend backend.Backend) error {
+func DeleteManager(remoteBackend backend.Backend, runner shell.Runner) error {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
@@ -62,11 +62,38 @@ func DeleteManager(remoteBackend backend.Backend) error {
 		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
 	}
 
+	// The directory can exist without a configuration, e.g. a canceled create
+	exists, err := remoteBackend.StateExists(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Cluster manager '%s' not found.", selectedClusterManager)
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("destroy manager")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
 	state, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
 		return err
 	}
 
+	// With --plan only show what terraform would destroy, nothing is stored
+	if viper.GetBool("plan") {
+		err = runner.Init(state)
+		if err != nil {
+			return err
+		}
+		// The whole configuration is deleted once the plan is applied, see DeletesManager
+		return runner.Plan(state, []string{"-destroy"}, state)
+	}
+
 	if !nonInteractiveMode {
 		// Confirmation
 		label := fmt.Sprintf("Are you sure you want to destroy %q", selectedClusterManager)
@@ -82,7 +109,11 @@ func DeleteManager(remoteBackend backend.Backend) error {
 	}
 
 	// Run Terraform destroy
-	err = shell.RunTerraformDestroyWithState(state, []string{})
+	err = runner.Init(state)
+	if err != nil {
+		return err
+	}
+	err = runner.Destroy(state, []string{})
 	if err != nil {
 		return err
 	}
@@ -93,5 +124,10 @@ func DeleteManager(remoteBackend backend.Backend) error {
 		return err
 	}
 
+	err = runner.RemoveWorkingDirectory(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
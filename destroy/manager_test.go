@@ -3,8 +3,11 @@ package destroy
 import (
 	"testing"
 
+	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestDeleteManagerNoClusterManager(t *testing.T) {
@@ -14,7 +17,7 @@ func TestDeleteManagerNoClusterManager(t *testing.T) {
 
 	expected := "No cluster managers, please create a cluster manager before creating a kubernetes cluster."
 
-	err := DeleteManager(localBackend)
+	err := DeleteManager(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -29,7 +32,7 @@ func TestDeleteManagerMissingClusterManager(t *testing.T) {
 
 	expected := "cluster_manager must be specified"
 
-	err := DeleteManager(localBackend)
+	err := DeleteManager(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -45,8 +48,70 @@ func TestDeleteManagerNotExist(t *testing.T) {
 
 	expected := "Selected cluster manager 'prod-cluster' does not exist."
 
-	err := DeleteManager(localBackend)
+	err := DeleteManager(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
 }
+
+func TestDeleteManagerLocked(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+
+	lockErr := &backend.LockError{Name: "dev-manager", Info: backend.NewLockInfo("create node")}
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	localBackend.On("StateExists", "dev-manager").Return(true, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(lockErr)
+
+	err := DeleteManager(localBackend, shelltest.New())
+	if err != lockErr {
+		t.Errorf("Wrong output, expected %v, received %v", lockErr, err)
+	}
+
+	localBackend.AssertNotCalled(t, "State", "dev-manager")
+	localBackend.AssertNotCalled(t, "Unlock", "dev-manager", mock.Anything)
+}
+
+func TestDeleteManagerWithoutConfiguration(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	localBackend.On("StateExists", "dev-manager").Return(false, nil)
+
+	expected := "Cluster manager 'dev-manager' not found."
+
+	err := DeleteManager(localBackend, shelltest.New())
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	localBackend.AssertNotCalled(t, "Lock", "dev-manager", mock.Anything)
+}
+
+func TestDeleteManager(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+
+	backend := newTestClusterBackend(newTestClusterState(t))
+	backend.On("DeleteState", "dev-manager").Return(nil)
+
+	runner := shelltest.New()
+	err := DeleteManager(backend, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.AssertExpectations(t)
+
+	// Everything is destroyed, no targets
+	expectCommands(t, runner, "init", "destroy", "remove-working-directory")
+	destroy, _ := runner.Last("destroy")
+	expectArgs(t, destroy)
+}
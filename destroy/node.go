@@ -13,7 +13,7 @@ import (
 	"github.com/spf13/viper"
 )
 
-func DeleteNode(remoteBackend backend.Backend) error {
+func DeleteNode(remoteBackend backend.Backend, runner shell.Runner) error {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
@@ -61,6 +61,23 @@ func DeleteNode(remoteBackend backend.Backend) error {
 		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
 	}
 
+	// The directory can exist without a configuration, e.g. a canceled create
+	exists, err := remoteBackend.StateExists(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Cluster manager '%s' not found.", selectedClusterManager)
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("destroy node")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
 	state, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
 		return err
@@ -149,6 +166,27 @@ func DeleteNode(remoteBackend backend.Backend) error {
 		selectedNodeKey = nodes[value]
 	}
 
+	targetArg := fmt.Sprintf("-target=module.%s", selectedNodeKey)
+
+	// With --plan only show what terraform would destroy, nothing is stored
+	if viper.GetBool("plan") {
+		// A saved plan stores the configuration without the destroyed node
+		plannedState, err := state.Clone()
+		if err != nil {
+			return err
+		}
+		err = plannedState.DeleteModule(selectedNodeKey)
+		if err != nil {
+			return err
+		}
+
+		err = runner.Init(state)
+		if err != nil {
+			return err
+		}
+		return runner.Plan(state, []string{"-destroy", targetArg}, plannedState)
+	}
+
 	if !nonInteractiveMode {
 		// Confirmation
 		label := fmt.Sprintf("Are you sure you want to destroy %q", nodeHostname)
@@ -164,14 +202,17 @@ func DeleteNode(remoteBackend backend.Backend) error {
 	}
 
 	// Run terraform destroy
-	targetArg := fmt.Sprintf("-target=module.%s", selectedNodeKey)
-	err = shell.RunTerraformDestroyWithState(state, []string{targetArg})
+	err = runner.Init(state)
+	if err != nil {
+		return err
+	}
+	err = runner.Destroy(state, []string{targetArg})
 	if err != nil {
 		return err
 	}
 
 	// Remove node from terraform config
-	err = state.Delete(fmt.Sprintf("module.%s", selectedNodeKey))
+	err = state.DeleteModule(selectedNodeKey)
 	if err != nil {
 		return err
 	}
@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
 	"github.com/joyent/triton-kubernetes/state"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
 )
 
 var mockNodeHost = []byte(`{
@@ -21,7 +23,7 @@ func TestDeleteNodeNoClusterManager(t *testing.T) {
 
 	expected := "No cluster managers, please create a cluster manager before creating a kubernetes node."
 
-	err := DeleteNode(localBackend)
+	err := DeleteNode(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -36,7 +38,7 @@ func TestDeleteNodeMissingClusterManager(t *testing.T) {
 
 	expected := "cluster_manager must be specified"
 
-	err := DeleteNode(localBackend)
+	err := DeleteNode(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -52,7 +54,7 @@ func TestDeleteNodeManagerNotExist(t *testing.T) {
 
 	expected := "Selected cluster manager 'prod-manager' does not exist."
 
-	err := DeleteNode(localBackend)
+	err := DeleteNode(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -62,15 +64,18 @@ func TestDeleteNodeMustSpecifyClusterName(t *testing.T) {
 	viper.Set("non-interactive", true)
 	viper.Set("cluster_manager", "dev-manager")
 
-	stateObj, _ := state.New("NodeState", mockClusters)
+	stateObj, _ := state.Load("NodeState", mockClusters)
 
 	backend := &mocks.Backend{}
 	backend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
 	backend.On("State", "dev-manager").Return(stateObj, nil)
 
 	expected := "cluster_name must be specified"
 
-	err := DeleteNode(backend)
+	err := DeleteNode(backend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -82,15 +87,18 @@ func TestDeleteNodeClusterNotExist(t *testing.T) {
 	viper.Set("cluster_manager", "dev-manager")
 	viper.Set("cluster_name", "cluster_alpha")
 
-	stateObj, _ := state.New("NodeState", mockClusters)
+	stateObj, _ := state.Load("NodeState", mockClusters)
 
 	backend := &mocks.Backend{}
 	backend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
 	backend.On("State", "dev-manager").Return(stateObj, nil)
 
 	expected := "A cluster named 'cluster_alpha', does not exist."
 
-	err := DeleteNode(backend)
+	err := DeleteNode(backend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -102,15 +110,18 @@ func TestDeleteNodeHostNameMustSpecified(t *testing.T) {
 	viper.Set("cluster_manager", "dev-manager")
 	viper.Set("cluster_name", "dev_cluster")
 
-	stateObj, _ := state.New("NodeState", mockNodeHost)
+	stateObj, _ := state.Load("NodeState", mockNodeHost)
 
 	backend := &mocks.Backend{}
 	backend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
 	backend.On("State", "dev-manager").Return(stateObj, nil)
 
 	expected := "hostname must be specified"
 
-	err := DeleteNode(backend)
+	err := DeleteNode(backend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -123,16 +134,79 @@ func TestDeleteNodeNotExist(t *testing.T) {
 	viper.Set("cluster_name", "dev_cluster")
 	viper.Set("hostname", "dev_node_host")
 
-	stateObj, _ := state.New("NodeState", mockNodeHost)
+	stateObj, _ := state.Load("NodeState", mockNodeHost)
 
 	backend := &mocks.Backend{}
 	backend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	backend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	backend.On("StateExists", "dev-manager").Return(true, nil)
 	backend.On("State", "dev-manager").Return(stateObj, nil)
 
 	expected := "A node named 'dev_node_host', does not exist."
 
-	err := DeleteNode(backend)
+	err := DeleteNode(backend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
+
+	backend.AssertCalled(t, "Unlock", "dev-manager", mock.Anything)
+}
+
+func TestDeleteNode(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "dev")
+	viper.Set("hostname", "dev-worker-2")
+
+	backend := newTestClusterBackend(newTestClusterState(t))
+	backend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		nodes, err := s.Nodes("cluster_triton_dev")
+		_, hasOther := nodes["dev-worker-1"]
+		return err == nil && len(nodes) == 1 && hasOther
+	})).Return(nil)
+
+	runner := shelltest.New()
+	err := DeleteNode(backend, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.AssertExpectations(t)
+
+	// Only the node is targeted
+	expectCommands(t, runner, "init", "destroy")
+	destroy, _ := runner.Last("destroy")
+	expectArgs(t, destroy, "-target=module.node_triton_dev__dev-worker-2")
+}
+
+func TestDeleteNodePlan(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("plan", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "dev")
+	viper.Set("hostname", "dev-worker-2")
+
+	backend := newTestClusterBackend(newTestClusterState(t))
+
+	runner := shelltest.New()
+	err := DeleteNode(backend, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.AssertNotCalled(t, "PersistState", mock.Anything)
+	expectCommands(t, runner, "init", "plan")
+	plan, _ := runner.Last("plan")
+	expectArgs(t, plan, "-destroy", "-target=module.node_triton_dev__dev-worker-2")
+
+	// A saved plan stores the configuration without the destroyed node
+	if _, ok := plan.PlannedState.Value("module.node_triton_dev__dev-worker-2"); ok {
+		t.Error("Expected the node to be removed from the planned configuration")
+	}
+	if _, ok := plan.PlannedState.Value("module.node_triton_dev__dev-worker-1"); !ok {
+		t.Error("Expected the other node to be kept in the planned configuration")
+	}
 }
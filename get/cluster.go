@@ -12,22 +12,22 @@ import (
 	"github.com/spf13/viper"
 )
 
-func GetCluster(remoteBackend backend.Backend) error {
+func GetCluster(remoteBackend backend.Backend, runner shell.Runner) (ClusterOutput, error) {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
-		return err
+		return ClusterOutput{}, err
 	}
 
 	if len(clusterManagers) == 0 {
-		return fmt.Errorf("No cluster managers.")
+		return ClusterOutput{}, fmt.Errorf("No cluster managers.")
 	}
 
 	selectedClusterManager := ""
 	if viper.IsSet("cluster_manager") {
 		selectedClusterManager = viper.GetString("cluster_manager")
 	} else if nonInteractiveMode {
-		return errors.New("cluster_manager must be specified")
+		return ClusterOutput{}, errors.New("cluster_manager must be specified")
 	} else {
 		prompt := promptui.Select{
 			Label: "Cluster Manager",
@@ -36,7 +36,7 @@ func GetCluster(remoteBackend backend.Backend) error {
 
 		_, value, err := prompt.Run()
 		if err != nil {
-			return err
+			return ClusterOutput{}, err
 		}
 
 		selectedClusterManager = value
@@ -51,22 +51,31 @@ func GetCluster(remoteBackend backend.Backend) error {
 		}
 	}
 	if !found {
-		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
+		return ClusterOutput{}, fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
+	}
+
+	// The directory can exist without a configuration, e.g. a canceled create
+	exists, err := remoteBackend.StateExists(selectedClusterManager)
+	if err != nil {
+		return ClusterOutput{}, err
+	}
+	if !exists {
+		return ClusterOutput{}, fmt.Errorf("Cluster manager '%s' not found.", selectedClusterManager)
 	}
 
 	state, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
-		return err
+		return ClusterOutput{}, err
 	}
 
 	// Get existing clusters
 	clusters, err := state.Clusters()
 	if err != nil {
-		return err
+		return ClusterOutput{}, err
 	}
 
 	if len(clusters) == 0 {
-		return fmt.Errorf("No clusters.")
+		return ClusterOutput{}, fmt.Errorf("No clusters.")
 	}
 
 	selectedClusterKey := ""
@@ -74,12 +83,12 @@ func GetCluster(remoteBackend backend.Backend) error {
 		clusterName := viper.GetString("cluster_name")
 		clusterKey, ok := clusters[clusterName]
 		if !ok {
-			return fmt.Errorf("A cluster named '%s', does not exist.", clusterName)
+			return ClusterOutput{}, fmt.Errorf("A cluster named '%s', does not exist.", clusterName)
 		}
 
 		selectedClusterKey = clusterKey
 	} else if nonInteractiveMode {
-		return errors.New("cluster_name must be specified")
+		return ClusterOutput{}, errors.New("cluster_name must be specified")
 	} else {
 		clusterNames := make([]string, 0, len(clusters))
 		for name := range clusters {
@@ -99,15 +108,28 @@ func GetCluster(remoteBackend backend.Backend) error {
 
 		_, value, err := prompt.Run()
 		if err != nil {
-			return err
+			return ClusterOutput{}, err
 		}
 		selectedClusterKey = clusters[value]
 	}
 
-	err = shell.RunTerraformOutputWithState(state, selectedClusterKey)
+	err = runner.Init(state)
+	if err != nil {
+		return ClusterOutput{}, err
+	}
+
+	output := ClusterOutput{}
+	err = runner.Output(state, selectedClusterKey, &output)
 	if err != nil {
-		return err
+		return ClusterOutput{}, err
+	}
+
+	// The cluster modules don't output their name
+	for name, clusterKey := range clusters {
+		if clusterKey == selectedClusterKey {
+			output.Name = name
+		}
 	}
 
-	return nil
+	return output, nil
 }
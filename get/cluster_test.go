@@ -3,6 +3,7 @@ package get
 import (
 	"testing"
 	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
 	"github.com/joyent/triton-kubernetes/state"
 	"github.com/spf13/viper"
 )
@@ -12,7 +13,7 @@ func TestGetClusterNoClusterManager(t *testing.T) {
 
 	localBackend.On("States").Return([]string{}, nil)
 
-	err := GetCluster(localBackend)
+	_, err := GetCluster(localBackend, shelltest.New())
 
 	expected := "No cluster managers."
 
@@ -30,7 +31,7 @@ func TestMissingClusterManagerNonInteractiveMode(t *testing.T){
 
 	localBackend.On("States").Return([]string{"dev-manager", "test-manager"}, nil)
 
-	err := GetCluster(localBackend)
+	_, err := GetCluster(localBackend, shelltest.New())
 
 	expected := "cluster_manager must be specified"
 
@@ -50,7 +51,7 @@ func TestUnidentifiedClusterManagerNonInteractiveMode(t *testing.T){
 
 	localBackend.On("States").Return([]string{"dev-manager", "test-manager"}, nil)
 
-	err := GetCluster(localBackend)
+	_, err := GetCluster(localBackend, shelltest.New())
 
 	expected := "Selected cluster manager 'xyz' does not exist."
 
@@ -66,7 +67,7 @@ func TestNoCluster(t *testing.T){
 
 	defer viper.Reset()
 
-	stateObj, _ := state.New("ClusterState", []byte(`{
+	stateObj, _ := state.Load("ClusterState", []byte(`{
 		"module":{}
 				}`))
 
@@ -74,9 +75,11 @@ func TestNoCluster(t *testing.T){
 
 	clusterManagerBackend.On("States").Return([]string{"dev-manager", "test-manager"}, nil)
 
+	clusterManagerBackend.On("StateExists", "dev-manager").Return(true, nil)
+
 	clusterManagerBackend.On("State", "dev-manager").Return(stateObj, nil)
 
-	err:= GetCluster(clusterManagerBackend)
+	_, err := GetCluster(clusterManagerBackend, shelltest.New())
 
 	expected := "No clusters."
 
@@ -92,7 +95,7 @@ func TestNoClusterNameNonInterative(t *testing.T){
 
 	defer viper.Reset()
 
-	stateObj, _ := state.New("ClusterState", []byte(`{
+	stateObj, _ := state.Load("ClusterState", []byte(`{
 		"module":{
 					"cluster_1":{"name":"dev_cluster"},
 					"cluster_2":{"name":"beta_cluster"},
@@ -104,9 +107,11 @@ func TestNoClusterNameNonInterative(t *testing.T){
 
 	clusterManagerBackend.On("States").Return([]string{"dev-manager", "test-manager"}, nil)
 
+	clusterManagerBackend.On("StateExists", "dev-manager").Return(true, nil)
+
 	clusterManagerBackend.On("State", "dev-manager").Return(stateObj, nil)
 
-	err:= GetCluster(clusterManagerBackend)
+	_, err := GetCluster(clusterManagerBackend, shelltest.New())
 
 	expected := "cluster_name must be specified"
 
@@ -123,7 +128,7 @@ func TestUnidentifiedCluster(t *testing.T){
 
 	defer viper.Reset()
 
-	stateObj, _ := state.New("ClusterState", []byte(`{
+	stateObj, _ := state.Load("ClusterState", []byte(`{
 		"module":{
 					"cluster_1":{"name":"dev_cluster"},
 					"cluster_2":{"name":"beta_cluster"},
@@ -135,9 +140,11 @@ func TestUnidentifiedCluster(t *testing.T){
 
 	clusterManagerBackend.On("States").Return([]string{"dev-manager", "test-manager"}, nil)
 
+	clusterManagerBackend.On("StateExists", "dev-manager").Return(true, nil)
+
 	clusterManagerBackend.On("State", "dev-manager").Return(stateObj, nil)
 
-	err:= GetCluster(clusterManagerBackend)
+	_, err := GetCluster(clusterManagerBackend, shelltest.New())
 
 	expected := "A cluster named 'cluster_xyz', does not exist."
 
@@ -11,22 +11,22 @@ import (
 	"github.com/spf13/viper"
 )
 
-func GetManager(remoteBackend backend.Backend) error {
+func GetManager(remoteBackend backend.Backend, runner shell.Runner) (ManagerOutput, error) {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 	clusterManagers, err := remoteBackend.States()
 	if err != nil {
-		return err
+		return ManagerOutput{}, err
 	}
 
 	if len(clusterManagers) == 0 {
-		return fmt.Errorf("No cluster managers.")
+		return ManagerOutput{}, fmt.Errorf("No cluster managers.")
 	}
 
 	selectedClusterManager := ""
 	if viper.IsSet("cluster_manager") {
 		selectedClusterManager = viper.GetString("cluster_manager")
 	} else if nonInteractiveMode {
-		return errors.New("cluster_manager must be specified")
+		return ManagerOutput{}, errors.New("cluster_manager must be specified")
 	} else {
 		prompt := promptui.Select{
 			Label: "Cluster Manager",
@@ -35,7 +35,7 @@ func GetManager(remoteBackend backend.Backend) error {
 
 		_, value, err := prompt.Run()
 		if err != nil {
-			return err
+			return ManagerOutput{}, err
 		}
 
 		selectedClusterManager = value
@@ -50,18 +50,33 @@ func GetManager(remoteBackend backend.Backend) error {
 		}
 	}
 	if !found {
-		return fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
+		return ManagerOutput{}, fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
+	}
+
+	// The directory can exist without a configuration, e.g. a canceled create
+	exists, err := remoteBackend.StateExists(selectedClusterManager)
+	if err != nil {
+		return ManagerOutput{}, err
+	}
+	if !exists {
+		return ManagerOutput{}, fmt.Errorf("Cluster manager '%s' not found.", selectedClusterManager)
 	}
 
 	state, err := remoteBackend.State(selectedClusterManager)
 	if err != nil {
-		return err
+		return ManagerOutput{}, err
+	}
+
+	err = runner.Init(state)
+	if err != nil {
+		return ManagerOutput{}, err
 	}
 
-	err = shell.RunTerraformOutputWithState(state, "cluster-manager")
+	output := ManagerOutput{Name: selectedClusterManager}
+	err = runner.Output(state, "cluster-manager", &output)
 	if err != nil {
-		return err
+		return ManagerOutput{}, err
 	}
 
-	return nil
+	return output, nil
 }
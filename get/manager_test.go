@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
 	"github.com/spf13/viper"
 )
 
@@ -14,7 +15,7 @@ func TestNoClusterManager(t *testing.T) {
 
 	expected := "No cluster managers."
 
-	err := GetManager(localBackend)
+	_, err := GetManager(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -28,7 +29,7 @@ func TestMissingClusterManager(t *testing.T) {
 
 	expected := "cluster_manager must be specified"
 
-	err := GetManager(localBackend)
+	_, err := GetManager(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
@@ -42,7 +43,7 @@ func TestClusterManagerNotExists(t *testing.T) {
 
 	expected := "Selected cluster manager 'prod-cluster' does not exist."
 
-	err := GetManager(localBackend)
+	_, err := GetManager(localBackend, shelltest.New())
 	if expected != err.Error() {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
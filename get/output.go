@@ -0,0 +1,92 @@
+package get
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Formats the results of get can be printed in, see Print
+var OutputFormats = []string{"table", "json", "yaml"}
+
+// ManagerOutput is what get manager returns. Fields are named like the outputs of the
+// cluster manager modules.
+type ManagerOutput struct {
+	Name             string `json:"name" yaml:"name"`
+	RancherURL       string `json:"rancher_url" yaml:"rancher_url"`
+	RancherAccessKey string `json:"rancher_access_key" yaml:"rancher_access_key"`
+	RancherSecretKey string `json:"rancher_secret_key" yaml:"rancher_secret_key"`
+}
+
+func (output ManagerOutput) tableRows() [][]string {
+	return [][]string{
+		{"Name", output.Name},
+		{"Rancher URL", output.RancherURL},
+		{"Rancher Access Key", output.RancherAccessKey},
+		{"Rancher Secret Key", output.RancherSecretKey},
+	}
+}
+
+// ClusterOutput is what get cluster returns. Fields are named like the outputs of the
+// cluster modules.
+type ClusterOutput struct {
+	Name                     string `json:"name" yaml:"name"`
+	RancherClusterID         string `json:"rancher_cluster_id" yaml:"rancher_cluster_id"`
+	RancherRegistrationToken string `json:"rancher_cluster_registration_token" yaml:"rancher_cluster_registration_token"`
+	RancherCAChecksum        string `json:"rancher_cluster_ca_checksum" yaml:"rancher_cluster_ca_checksum"`
+}
+
+func (output ClusterOutput) tableRows() [][]string {
+	return [][]string{
+		{"Name", output.Name},
+		{"Rancher Cluster ID", output.RancherClusterID},
+		{"Registration Token", output.RancherRegistrationToken},
+		{"CA Checksum", output.RancherCAChecksum},
+	}
+}
+
+type tableOutput interface {
+	tableRows() [][]string
+}
+
+// CheckOutputFormat returns an error if format isn't one of the OutputFormats.
+func CheckOutputFormat(format string) error {
+	for _, outputFormat := range OutputFormats {
+		if format == outputFormat {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Output format '%s' is not supported, use table, json or yaml.", format)
+}
+
+// Print writes result to w as a table, JSON or YAML.
+func Print(w io.Writer, format string, result tableOutput) error {
+	switch format {
+	case "table":
+		tableWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, row := range result.tableRows() {
+			fmt.Fprintf(tableWriter, "%s\t%s\n", row[0], row[1])
+		}
+		return tableWriter.Flush()
+	case "json":
+		content, err := json.MarshalIndent(result, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", content)
+		return err
+	case "yaml":
+		content, err := yaml.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	}
+
+	return CheckOutputFormat(format)
+}
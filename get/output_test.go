@@ -0,0 +1,135 @@
+package get
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetManagerOutput(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+	defer viper.Reset()
+
+	stateObj, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"dev-manager"}}}`))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("StateExists", "dev-manager").Return(true, nil)
+	localBackend.On("State", "dev-manager").Return(stateObj, nil)
+
+	runner := shelltest.New()
+	runner.Outputs["cluster-manager"] = `{
+		"rancher_url": "https://10.0.0.1",
+		"rancher_access_key": "token-abcde",
+		"rancher_secret_key": "secret"
+	}`
+
+	output, err := GetManager(localBackend, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ManagerOutput{
+		Name:             "dev-manager",
+		RancherURL:       "https://10.0.0.1",
+		RancherAccessKey: "token-abcde",
+		RancherSecretKey: "secret",
+	}
+	if output != expected {
+		t.Errorf("Wrong output, expected %+v, received %+v", expected, output)
+	}
+}
+
+func TestGetClusterOutput(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+	viper.Set("cluster_name", "dev")
+	defer viper.Reset()
+
+	stateObj, _ := state.New("dev-manager", []byte(`{}`))
+	stateObj.SetManager(state.Manager{Name: "dev-manager"})
+	stateObj.SetCluster("triton", "dev", state.Cluster{Name: "dev"})
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("StateExists", "dev-manager").Return(true, nil)
+	localBackend.On("State", "dev-manager").Return(stateObj, nil)
+
+	// Provider specific outputs are left out
+	runner := shelltest.New()
+	runner.Outputs["cluster_triton_dev"] = `{
+		"rancher_cluster_id": "c-abcde",
+		"rancher_cluster_registration_token": "registration-token",
+		"rancher_cluster_ca_checksum": "checksum",
+		"triton_network": "joyent"
+	}`
+
+	output, err := GetCluster(localBackend, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ClusterOutput{
+		Name:                     "dev",
+		RancherClusterID:         "c-abcde",
+		RancherRegistrationToken: "registration-token",
+		RancherCAChecksum:        "checksum",
+	}
+	if output != expected {
+		t.Errorf("Wrong output, expected %+v, received %+v", expected, output)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	output := ClusterOutput{
+		Name:                     "dev",
+		RancherClusterID:         "c-abcde",
+		RancherRegistrationToken: "registration-token",
+		RancherCAChecksum:        "checksum",
+	}
+
+	tests := map[string]string{
+		"table": "Name                dev\n" +
+			"Rancher Cluster ID  c-abcde\n" +
+			"Registration Token  registration-token\n" +
+			"CA Checksum         checksum\n",
+		"json": "{\n" +
+			"\t\"name\": \"dev\",\n" +
+			"\t\"rancher_cluster_id\": \"c-abcde\",\n" +
+			"\t\"rancher_cluster_registration_token\": \"registration-token\",\n" +
+			"\t\"rancher_cluster_ca_checksum\": \"checksum\"\n" +
+			"}\n",
+		"yaml": "name: dev\n" +
+			"rancher_cluster_id: c-abcde\n" +
+			"rancher_cluster_registration_token: registration-token\n" +
+			"rancher_cluster_ca_checksum: checksum\n",
+	}
+
+	for format, expected := range tests {
+		var buf bytes.Buffer
+		err := Print(&buf, format, output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected {
+			t.Errorf("Wrong %s output, expected:\n%s\nreceived:\n%s", format, expected, buf.String())
+		}
+	}
+}
+
+func TestPrintUnsupportedFormat(t *testing.T) {
+	expected := "Output format 'xml' is not supported, use table, json or yaml."
+
+	err := Print(&bytes.Buffer{}, "xml", ManagerOutput{})
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/viper"
+)
+
+// ApplyPlan applies a plan saved with --out and stores the configuration it was made for.
+// The plan is refused if the cluster manager's configuration changed since it was made,
+// and nothing is stored if terraform can't apply it.
+func ApplyPlan(remoteBackend backend.Backend, runner shell.Runner, planPath string) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+
+	savedPlan, err := shell.ReadSavedPlan(planPath)
+	if err != nil {
+		return err
+	}
+	name := savedPlan.Name
+
+	savedState, err := savedPlan.State()
+	if err != nil {
+		return err
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("apply")
+	err = remoteBackend.Lock(name, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(name, lockInfo.ID)
+
+	// The plan must have been made against the configuration that is stored now
+	exists, err := remoteBackend.StateExists(name)
+	if err != nil {
+		return err
+	}
+	currentRevision := ""
+	if exists {
+		currentState, err := remoteBackend.State(name)
+		if err != nil {
+			return err
+		}
+		currentRevision = currentState.Revision
+	}
+	if currentRevision != savedPlan.Revision {
+		return fmt.Errorf("The configuration of cluster manager '%s' changed since the plan was made, make a new plan.", name)
+	}
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("Apply the plan for %q made %s", name, savedPlan.Created.Local().Format("2006-01-02 15:04"))
+		selected := "Apply"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Apply canceled.")
+			return nil
+		}
+	}
+
+	err = runner.Init(savedState)
+	if err != nil {
+		return err
+	}
+	err = runner.ApplyPlan(savedPlan)
+	if err != nil {
+		return err
+	}
+
+	// After terraform succeeds, store the planned configuration
+	if savedPlan.DeletesManager() {
+		err = remoteBackend.DeleteState(name)
+		if err != nil {
+			return err
+		}
+
+		return runner.RemoveWorkingDirectory(name)
+	}
+
+	return remoteBackend.PersistState(savedState)
+}
@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/shell/shelltest"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApplyPlanChangedConfiguration(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	planPath := filepath.Join(dir, "plan.tfplan")
+	err = shell.WriteSavedPlan(planPath, shell.SavedPlan{
+		Name:     "dev-manager",
+		Revision: "1",
+		Args:     []string{"-destroy", "-target=module.cluster_triton_dev"},
+		Config:   []byte(`{"module":{"cluster-manager":{}}}`),
+		Plan:     []byte("plan"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currentState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{},"cluster_triton_dev":{}}}`))
+	currentState.Revision = "2"
+
+	// PersistState is not expected, terraform never runs for a stale plan
+	localBackend := &mocks.Backend{}
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("StateExists", "dev-manager").Return(true, nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	expected := "The configuration of cluster manager 'dev-manager' changed since the plan was made, make a new plan."
+
+	err = ApplyPlan(localBackend, shelltest.New(), planPath)
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
+
+func TestApplyPlanTargetedDestroy(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// destroy cluster --out saves the configuration without the destroyed modules
+	planPath := filepath.Join(dir, "plan.tfplan")
+	err = shell.WriteSavedPlan(planPath, shell.SavedPlan{
+		Name:     "dev-manager",
+		Revision: "1",
+		Args:     []string{"-destroy", "-target=module.cluster_triton_dev", "-target=module.node_triton_dev__dev-worker-1"},
+		Config:   []byte(`{"module":{"cluster-manager":{"name":"dev-manager"}}}`),
+		Plan:     []byte("plan"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currentState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"dev-manager"},"cluster_triton_dev":{},"node_triton_dev__dev-worker-1":{}}}`))
+	currentState.Revision = "1"
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("StateExists", "dev-manager").Return(true, nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+	localBackend.On("PersistState", mock.Anything).Return(nil)
+
+	runner := shelltest.New()
+	err = ApplyPlan(localBackend, runner, planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	localBackend.AssertNotCalled(t, "DeleteState", mock.Anything)
+	localBackend.AssertCalled(t, "PersistState", mock.MatchedBy(func(persisted state.State) bool {
+		_, hasCluster := persisted.Value("module.cluster_triton_dev")
+		_, hasNode := persisted.Value("module.node_triton_dev__dev-worker-1")
+		return !hasCluster && !hasNode && persisted.Get("module.cluster-manager.name") == "dev-manager" && persisted.Revision == "1"
+	}))
+
+	applyPlan, ok := runner.Last("apply-plan")
+	if !ok {
+		t.Fatal("Expected the plan to be applied")
+	}
+	if len(applyPlan.Args) != 3 {
+		t.Errorf("Expected the plan's arguments, got %v", applyPlan.Args)
+	}
+}
+
+func TestApplyPlanNotAPlan(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	planPath := filepath.Join(dir, "plan.tfplan")
+	err = ioutil.WriteFile(planPath, []byte("PK"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	localBackend := &mocks.Backend{}
+
+	expected := "'" + planPath + "' is not a plan saved with --out"
+
+	err = ApplyPlan(localBackend, shelltest.New(), planPath)
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
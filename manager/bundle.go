@@ -0,0 +1,241 @@
+package manager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+)
+
+// Version of the bundle layout written by ExportManager
+const bundleVersion = 1
+
+// Paths inside a bundle
+const (
+	bundleManifestPath       = "manifest.json"
+	bundleConfigPath         = "main.tf.json"
+	bundleTerraformStatePath = "terraform.tfstate"
+	bundleFilesDirectory     = "files"
+)
+
+// Context an encrypted bundle is bound to, see state.EncryptSecret
+const bundleSecretContext = "triton-kubernetes bundle"
+
+// Configuration keys that hold the path of a local key or credentials file
+var localFileKeys = map[string]bool{
+	"aws_private_key_path":    true,
+	"aws_public_key_path":     true,
+	"azure_private_key_path":  true,
+	"azure_public_key_path":   true,
+	"gcp_path_to_credentials": true,
+	"gcp_private_key_path":    true,
+	"gcp_public_key_path":     true,
+	"key_path":                true,
+	"tls_cert_path":           true,
+	"tls_private_key_path":    true,
+	"triton_key_path":         true,
+}
+
+// bundleManifest describes the contents of a bundle.
+type bundleManifest struct {
+	Version        int          `json:"version"`
+	Name           string       `json:"name"`
+	Created        time.Time    `json:"created"`
+	TerraformState bool         `json:"terraform_state"`
+	Files          []bundleFile `json:"files"`
+}
+
+// bundleFile is a local file referenced by the configuration. Path is the path as it
+// appears in the configuration, e.g. ~/.ssh/id_rsa.
+type bundleFile struct {
+	Path       string `json:"path"`
+	BundlePath string `json:"bundle_path"`
+}
+
+type bundleEntry struct {
+	name    string
+	content []byte
+}
+
+// bundle is the unpacked contents of a bundle.
+type bundle struct {
+	Manifest       bundleManifest
+	Config         []byte
+	TerraformState []byte
+	Files          map[string][]byte
+}
+
+// referencedLocalFiles returns the local files a configuration references, either by one
+// of the localFileKeys or by a `file:` secret reference, sorted by path.
+// Encrypted values and interpolations are skipped.
+func referencedLocalFiles(currentState state.State) []string {
+	modules, _ := currentState.Value("module")
+
+	found := map[string]bool{}
+	collectLocalFiles("", modules, found)
+
+	paths := []string{}
+	for filePath := range found {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func collectLocalFiles(key string, data interface{}, found map[string]bool) {
+	switch node := data.(type) {
+	case string:
+		if node == "" || state.IsEncryptedSecret(node) || strings.Contains(node, "${") {
+			return
+		}
+		if strings.HasPrefix(node, "file:") {
+			found[strings.TrimPrefix(node, "file:")] = true
+		} else if localFileKeys[key] && !state.IsSecretReference(node) {
+			found[node] = true
+		}
+	case map[string]interface{}:
+		for childKey, child := range node {
+			collectLocalFiles(childKey, child, found)
+		}
+	case []interface{}:
+		for _, child := range node {
+			collectLocalFiles(key, child, found)
+		}
+	}
+}
+
+// writeBundle packs b into a gzipped tarball.
+func writeBundle(b bundle) ([]byte, error) {
+	manifest, err := json.MarshalIndent(b.Manifest, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	entries := []bundleEntry{
+		{bundleManifestPath, manifest},
+		{bundleConfigPath, b.Config},
+	}
+	if b.Manifest.TerraformState {
+		entries = append(entries, bundleEntry{bundleTerraformStatePath, b.TerraformState})
+	}
+	for _, file := range b.Manifest.Files {
+		entries = append(entries, bundleEntry{file.BundlePath, b.Files[file.BundlePath]})
+	}
+
+	for _, entry := range entries {
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name:    entry.name,
+			Mode:    0600,
+			Size:    int64(len(entry.content)),
+			ModTime: b.Manifest.Created,
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, err = tarWriter.Write(entry.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readBundle unpacks a bundle written by writeBundle, encrypted bundles are decrypted
+// with the key given by bundle_keyfile or bundle_passphrase.
+func readBundle(content []byte) (bundle, error) {
+	if state.IsEncryptedSecret(string(content)) {
+		secretKey, err := util.GetBundleSecretKey()
+		if err != nil {
+			return bundle{}, err
+		}
+		if secretKey == nil {
+			return bundle{}, errors.New("Bundle is encrypted, bundle_keyfile or bundle_passphrase must be specified")
+		}
+
+		decrypted, err := state.DecryptSecret(secretKey, string(content), bundleSecretContext)
+		if err != nil {
+			return bundle{}, fmt.Errorf("Could not decrypt bundle: %s", err)
+		}
+		content = []byte(decrypted)
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return bundle{}, errors.New("Not a cluster manager bundle")
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	entries := map[string][]byte{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return bundle{}, err
+		}
+
+		entry, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return bundle{}, err
+		}
+		entries[path.Clean(header.Name)] = entry
+	}
+
+	b := bundle{
+		Config:         entries[bundleConfigPath],
+		TerraformState: entries[bundleTerraformStatePath],
+		Files:          map[string][]byte{},
+	}
+
+	manifest, ok := entries[bundleManifestPath]
+	if !ok || b.Config == nil {
+		return bundle{}, errors.New("Not a cluster manager bundle")
+	}
+	err = json.Unmarshal(manifest, &b.Manifest)
+	if err != nil {
+		return bundle{}, err
+	}
+	if b.Manifest.Version > bundleVersion {
+		return bundle{}, fmt.Errorf("Bundle version %d is not supported, update triton-kubernetes", b.Manifest.Version)
+	}
+	if b.Manifest.Name == "" {
+		return bundle{}, errors.New("Bundle does not name a cluster manager")
+	}
+
+	for _, file := range b.Manifest.Files {
+		content, ok := entries[path.Clean(file.BundlePath)]
+		if !ok {
+			return bundle{}, fmt.Errorf("Bundle is missing file '%s'", file.Path)
+		}
+		b.Files[file.BundlePath] = content
+	}
+
+	return b, nil
+}
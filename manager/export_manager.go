@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// ExportManager writes a bundle with a cluster manager's configuration, its terraform state
+// and the local key and credential files the configuration references to output. With
+// encrypt the bundle is encrypted with the key given by bundle_keyfile or bundle_passphrase.
+func ExportManager(remoteBackend backend.Backend, name, output string, encrypt bool) error {
+	selectedClusterManager, err := selectClusterManager(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = fmt.Sprintf("%s.tgz", selectedClusterManager)
+	}
+
+	var secretKey *state.SecretKey
+	if encrypt {
+		secretKey, err = util.GetBundleSecretKey()
+		if err != nil {
+			return err
+		}
+		if secretKey == nil {
+			return errors.New("bundle_keyfile or bundle_passphrase must be specified to encrypt the bundle")
+		}
+	}
+
+	// Lock the cluster manager configuration, so the configuration and terraform state match
+	lockInfo := backend.NewLockInfo("export manager")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
+	currentState, err := remoteBackend.State(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	terraformState, err := remoteBackend.TerraformState(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	b := bundle{
+		Manifest: bundleManifest{
+			Version:        bundleVersion,
+			Name:           selectedClusterManager,
+			Created:        time.Now().UTC(),
+			TerraformState: terraformState != nil,
+			Files:          []bundleFile{},
+		},
+		Config:         currentState.Bytes(),
+		TerraformState: terraformState,
+		Files:          map[string][]byte{},
+	}
+
+	for i, filePath := range referencedLocalFiles(currentState) {
+		expandedPath, err := homedir.Expand(filePath)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(expandedPath)
+		if err != nil {
+			return fmt.Errorf("Could not read '%s', which is referenced by the configuration: %s", filePath, err)
+		}
+
+		bundlePath := fmt.Sprintf("%s/%d-%s", bundleFilesDirectory, i, path.Base(expandedPath))
+		b.Manifest.Files = append(b.Manifest.Files, bundleFile{Path: filePath, BundlePath: bundlePath})
+		b.Files[bundlePath] = content
+	}
+
+	content, err := writeBundle(b)
+	if err != nil {
+		return err
+	}
+
+	if secretKey != nil {
+		encrypted, err := state.EncryptSecret(secretKey, string(content), bundleSecretContext)
+		if err != nil {
+			return err
+		}
+		content = []byte(encrypted)
+	}
+
+	err = ioutil.WriteFile(output, content, 0600)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cluster manager '%s' exported to '%s' with %d local file(s).\n", selectedClusterManager, output, len(b.Manifest.Files))
+	if secretKey == nil {
+		fmt.Println("Warning: the bundle is not encrypted and contains secrets like keys, credentials and the terraform state, keep it safe or export it with --encrypt.")
+	}
+	if currentState.HasEncryptedSecrets() {
+		fmt.Println("Warning: the configuration contains encrypted secrets, importing the bundle also requires its encryption key.")
+	}
+
+	return nil
+}
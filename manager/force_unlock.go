@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/viper"
+)
+
+// ForceUnlock removes the lock with the given ID from a cluster manager.
+// Used when a command was interrupted and left a stale lock behind.
+func ForceUnlock(remoteBackend backend.Backend, lockID string) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+
+	selectedClusterManager, err := selectClusterManager(remoteBackend, "")
+	if err != nil {
+		return err
+	}
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("Are you sure you want to remove lock %q from %q", lockID, selectedClusterManager)
+		selected := "Force unlock"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Force unlock canceled.")
+			return nil
+		}
+	}
+
+	err = remoteBackend.Unlock(selectedClusterManager, lockID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cluster manager '%s' has been unlocked.\n", selectedClusterManager)
+
+	return nil
+}
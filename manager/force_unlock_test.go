@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/spf13/viper"
+)
+
+func TestForceUnlockNoClusterManager(t *testing.T) {
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{}, nil)
+
+	expected := "No cluster managers."
+
+	err := ForceUnlock(localBackend, "abc")
+	if expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
+	}
+}
+
+func TestForceUnlockMissingClusterManager(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+
+	expected := "cluster_manager must be specified"
+
+	err := ForceUnlock(localBackend, "abc")
+	if expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
+	}
+}
+
+func TestForceUnlockWrongLockID(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	localBackend.On("Unlock", "dev-manager", "abc").Return(errors.New("Lock ID 'abc' does not match the current lock"))
+
+	expected := "Lock ID 'abc' does not match the current lock"
+
+	err := ForceUnlock(localBackend, "abc")
+	if expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("cluster_manager", "dev-manager")
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+	localBackend.On("Unlock", "dev-manager", "abc").Return(nil)
+
+	err := ForceUnlock(localBackend, "abc")
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
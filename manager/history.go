@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+// History prints the versions of a cluster manager's configuration along with
+// a summary of the modules each version added (+), changed (~) or removed (-).
+func History(remoteBackend backend.Backend, name string) error {
+	selectedClusterManager, err := selectClusterManager(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+
+	history, err := remoteBackend.History(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	if len(history) == 0 {
+		return fmt.Errorf("No history for cluster manager '%s'.", selectedClusterManager)
+	}
+
+	previousState, err := state.New(selectedClusterManager, []byte("{}"))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tMODIFIED\tCHANGES")
+	for _, entry := range history {
+		versionState, err := remoteBackend.HistoryState(selectedClusterManager, entry.Version)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", entry.Version, entry.Modified.Format(time.RFC3339), summarizeChanges(previousState, versionState))
+		previousState = versionState
+	}
+
+	return w.Flush()
+}
+
+// summarizeChanges returns e.g. `+cluster_triton_dev ~cluster-manager -node_triton_dev_dev-1`
+func summarizeChanges(from, to state.State) string {
+	added, removed, changed := state.ModuleChanges(from, to)
+
+	changes := []string{}
+	for _, key := range added {
+		changes = append(changes, "+"+key)
+	}
+	for _, key := range changed {
+		changes = append(changes, "~"+key)
+	}
+	for _, key := range removed {
+		changes = append(changes, "-"+key)
+	}
+
+	if len(changes) == 0 {
+		return "no module changes"
+	}
+
+	return strings.Join(changes, " ")
+}
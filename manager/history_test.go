@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+)
+
+func TestHistory(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	firstState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{}}}`))
+	secondState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{},"cluster_triton_dev":{}}}`))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("History", "dev-manager").Return([]backend.HistoryEntry{
+		{Version: 1, Modified: time.Now()},
+		{Version: 2, Modified: time.Now()},
+	}, nil)
+	localBackend.On("HistoryState", "dev-manager", 1).Return(firstState, nil)
+	localBackend.On("HistoryState", "dev-manager", 2).Return(secondState, nil)
+
+	err := History(localBackend, "dev-manager")
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
+
+func TestSummarizeChanges(t *testing.T) {
+	from, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"a"},"node_triton_dev_1":{}}}`))
+	to, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"b"},"cluster_triton_dev":{}}}`))
+
+	expected := "+cluster_triton_dev ~cluster-manager -node_triton_dev_1"
+	actual := summarizeChanges(from, to)
+	if expected != actual {
+		t.Errorf("Wrong output, expected %s, received %s", expected, actual)
+	}
+}
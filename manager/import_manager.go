@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// Cluster manager names are held to the same rules as cluster names
+var managerNameRegexp = regexp.MustCompile("^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$")
+
+// ImportManager restores a cluster manager from a bundle written by ExportManager into
+// remoteBackend. Local files are restored to the paths the configuration references, as long
+// as they are in the home directory. Files that already exist with different content are
+// never overwritten.
+func ImportManager(remoteBackend backend.Backend, bundlePath string) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+
+	content, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		return err
+	}
+
+	b, err := readBundle(content)
+	if err != nil {
+		return err
+	}
+	name := b.Manifest.Name
+
+	// The name becomes a directory or object prefix in the backend
+	if !managerNameRegexp.MatchString(name) {
+		return fmt.Errorf("Invalid cluster manager name '%s' in the bundle", name)
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("import manager")
+	err = remoteBackend.Lock(name, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(name, lockInfo.ID)
+
+	clusterManagers, err := remoteBackend.States()
+	if err != nil {
+		return err
+	}
+	for _, clusterManager := range clusterManagers {
+		if clusterManager == name {
+			return fmt.Errorf("Cluster manager '%s' already exists.", name)
+		}
+	}
+
+	importedState, err := state.Load(name, b.Config)
+	if err != nil {
+		return err
+	}
+
+	// Whoever made the bundle could run commands on this machine through exec: references
+	if importedState.HasExecReferences() {
+		return errors.New("The bundle's configuration has exec: secret references, which are never imported. Replace them with env: or file: references before exporting it.")
+	}
+
+	// Point terraform at this backend
+	if _, ok := importedState.Value("terraform.backend"); ok {
+		err = importedState.Delete("terraform.backend")
+		if err != nil {
+			return err
+		}
+	}
+	err = importedState.SetTerraformBackendConfig(remoteBackend.StateTerraformConfig(name))
+	if err != nil {
+		return err
+	}
+
+	// Find the files to restore before writing anything. Only files the configuration
+	// references are restored, and only into the home directory.
+	referencedFiles := map[string]bool{}
+	for _, filePath := range referencedLocalFiles(importedState) {
+		referencedFiles[filePath] = true
+	}
+	filesToRestore := map[string][]byte{}
+	restoredPaths := []string{}
+	for _, file := range b.Manifest.Files {
+		if !referencedFiles[file.Path] {
+			return fmt.Errorf("File '%s' in the bundle is not referenced by the configuration.", file.Path)
+		}
+
+		expandedPath, err := restorePath(file.Path)
+		if err != nil {
+			return err
+		}
+
+		existing, err := ioutil.ReadFile(expandedPath)
+		if err == nil {
+			if !bytes.Equal(existing, b.Files[file.BundlePath]) {
+				return fmt.Errorf("File '%s' already exists with different content, move it away to import the bundle.", file.Path)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		filesToRestore[expandedPath] = b.Files[file.BundlePath]
+		restoredPaths = append(restoredPaths, expandedPath)
+	}
+
+	if len(restoredPaths) > 0 {
+		fmt.Println("Local files restored from the bundle:")
+		for _, filePath := range restoredPaths {
+			fmt.Printf("  - %s\n", filePath)
+		}
+	}
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("Import %q and restore %d local file(s)", name, len(filesToRestore))
+		selected := "Import"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Import canceled.")
+			return nil
+		}
+	}
+
+	for filePath, fileContent := range filesToRestore {
+		err = os.MkdirAll(filepath.Dir(filePath), 0700)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filePath, fileContent, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = remoteBackend.PersistState(importedState)
+	if err != nil {
+		return err
+	}
+
+	if b.Manifest.TerraformState {
+		err = remoteBackend.PersistTerraformState(name, b.TerraformState)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Cluster manager '%s' imported.\n", name)
+
+	return nil
+}
+
+// restorePath returns the path a file of a bundle is restored to. Only paths in the home
+// directory are restored, so a bundle can't create e.g. files in system directories.
+func restorePath(filePath string) (string, error) {
+	for _, part := range strings.Split(filepath.ToSlash(filePath), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("File '%s' in the bundle is not restored, its path contains '..'.", filePath)
+		}
+	}
+
+	expandedPath, err := homedir.Expand(filePath)
+	if err != nil {
+		return "", err
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(expandedPath) || !strings.HasPrefix(expandedPath, filepath.Clean(home)+string(filepath.Separator)) {
+		return "", fmt.Errorf("File '%s' in the bundle is not restored, only files in the home directory are.", filePath)
+	}
+
+	return expandedPath, nil
+}
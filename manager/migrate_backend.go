@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/viper"
+)
+
+// MigrateBackend moves a cluster manager's configuration and terraform state from
+// sourceBackend to destinationBackend. The source is only deleted once the copy
+// has been read back from the destination and matches.
+func MigrateBackend(sourceBackend, destinationBackend backend.Backend, name string) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+
+	selectedClusterManager, err := selectClusterManager(sourceBackend, name)
+	if err != nil {
+		return err
+	}
+
+	// Lock the cluster manager in the destination backend until both its configuration and
+	// terraform state are there, so nothing else creates it in the meantime
+	lockInfo := backend.NewLockInfo("migrate-backend")
+	err = destinationBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer destinationBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
+	destinationClusterManagers, err := destinationBackend.States()
+	if err != nil {
+		return err
+	}
+	for _, clusterManager := range destinationClusterManagers {
+		if clusterManager == selectedClusterManager {
+			return fmt.Errorf("Cluster manager '%s' already exists in the destination backend.", selectedClusterManager)
+		}
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	err = sourceBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer sourceBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
+	sourceState, err := sourceBackend.State(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	// A configuration from a shared backend could run commands through exec: references once
+	// it's local, and exec: references never run from a shared backend
+	if sourceState.HasExecReferences() {
+		return errors.New("The configuration has exec: secret references, which are never migrated. Replace them with env: or file: references first.")
+	}
+
+	terraformState, err := sourceBackend.TerraformState(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	// Point terraform at the destination backend
+	destinationState, err := state.New(selectedClusterManager, sourceState.Bytes())
+	if err != nil {
+		return err
+	}
+	err = destinationState.Delete("terraform.backend")
+	if err != nil {
+		return err
+	}
+	err = destinationState.SetTerraformBackendConfig(destinationBackend.StateTerraformConfig(selectedClusterManager))
+	if err != nil {
+		return err
+	}
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("Migrate %q to the destination backend", selectedClusterManager)
+		selected := "Migrate"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Migration canceled.")
+			return nil
+		}
+	}
+
+	// The history isn't copied, older versions point terraform at the source backend.
+	err = destinationBackend.PersistState(destinationState)
+	if err != nil {
+		return err
+	}
+
+	if terraformState != nil {
+		err = destinationBackend.PersistTerraformState(selectedClusterManager, terraformState)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = verifyMigration(destinationBackend, destinationState, terraformState)
+	if err != nil {
+		return err
+	}
+
+	err = sourceBackend.DeleteState(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cluster manager '%s' migrated.\n", selectedClusterManager)
+
+	return nil
+}
+
+// verifyMigration checks that the destination backend returns exactly what was copied to it.
+// Secrets are compared decrypted, the destination encrypts them if a key is configured.
+func verifyMigration(destinationBackend backend.Backend, expectedState state.State, expectedTerraformState []byte) error {
+	copiedState, err := destinationBackend.State(expectedState.Name)
+	if err != nil {
+		return err
+	}
+
+	secretKey, err := util.GetSecretKey()
+	if err != nil {
+		return err
+	}
+	if secretKey != nil {
+		expectedState, err = expectedState.Clone()
+		if err != nil {
+			return err
+		}
+		err = expectedState.DecryptSecrets(secretKey)
+		if err != nil {
+			return err
+		}
+		copiedState, err = copiedState.Clone()
+		if err != nil {
+			return err
+		}
+		err = copiedState.DecryptSecrets(secretKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !bytes.Equal(copiedState.Bytes(), expectedState.Bytes()) {
+		return fmt.Errorf("Configuration of cluster manager '%s' in the destination backend does not match the source, the source has been kept.", expectedState.Name)
+	}
+
+	copiedTerraformState, err := destinationBackend.TerraformState(expectedState.Name)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(copiedTerraformState, expectedTerraformState) {
+		return fmt.Errorf("Terraform state of cluster manager '%s' in the destination backend does not match the source, the source has been kept.", expectedState.Name)
+	}
+
+	return nil
+}
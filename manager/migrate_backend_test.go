@@ -0,0 +1,177 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+type testTerraformBackendConfig struct {
+	Path string `json:"path"`
+}
+
+func TestMigrateBackendExistingDestination(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	sourceBackend := &mocks.Backend{}
+	sourceBackend.On("States").Return([]string{"dev-manager"}, nil)
+
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{"dev-manager"}, nil)
+
+	expected := "Cluster manager 'dev-manager' already exists in the destination backend."
+
+	err := MigrateBackend(sourceBackend, destinationBackend, "dev-manager")
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
+
+func TestMigrateBackend(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	sourceState, _ := state.New("dev-manager", []byte(`{"terraform":{"backend":{"local":{"path":"/old"}}},"module":{"cluster-manager":{}}}`))
+	terraformState := []byte(`{"version": 3}`)
+
+	expectedState, _ := state.New("dev-manager", []byte(`{"terraform":{"backend":{"manta":{"path":"/new"}}},"module":{"cluster-manager":{}}}`))
+
+	sourceBackend := &mocks.Backend{}
+	sourceBackend.On("States").Return([]string{"dev-manager"}, nil)
+	sourceBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("State", "dev-manager").Return(sourceState, nil)
+	sourceBackend.On("TerraformState", "dev-manager").Return(terraformState, nil)
+	sourceBackend.On("DeleteState", "dev-manager").Return(nil)
+
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{}, nil)
+	destinationBackend.On("StateTerraformConfig", "dev-manager").Return("terraform.backend.manta", testTerraformBackendConfig{Path: "/new"})
+	destinationBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		return string(s.Bytes()) == string(expectedState.Bytes())
+	})).Return(nil)
+	destinationBackend.On("PersistTerraformState", "dev-manager", terraformState).Return(nil)
+	destinationBackend.On("State", "dev-manager").Return(expectedState, nil)
+	destinationBackend.On("TerraformState", "dev-manager").Return(terraformState, nil)
+
+	err := MigrateBackend(sourceBackend, destinationBackend, "dev-manager")
+	if err != nil {
+		t.Error(err)
+	}
+
+	sourceBackend.AssertExpectations(t)
+	destinationBackend.AssertExpectations(t)
+}
+
+func TestMigrateBackendKeepsSourceOnMismatch(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	sourceState, _ := state.New("dev-manager", []byte(`{"terraform":{"backend":{"local":{"path":"/old"}}},"module":{"cluster-manager":{}}}`))
+	terraformState := []byte(`{"version": 3}`)
+
+	sourceBackend := &mocks.Backend{}
+	sourceBackend.On("States").Return([]string{"dev-manager"}, nil)
+	sourceBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("State", "dev-manager").Return(sourceState, nil)
+	sourceBackend.On("TerraformState", "dev-manager").Return(terraformState, nil)
+
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{}, nil)
+	destinationBackend.On("StateTerraformConfig", "dev-manager").Return("terraform.backend.manta", testTerraformBackendConfig{Path: "/new"})
+	destinationBackend.On("PersistState", mock.Anything).Return(nil)
+	destinationBackend.On("PersistTerraformState", "dev-manager", terraformState).Return(nil)
+	destinationBackend.On("State", "dev-manager").Return(sourceState, nil)
+
+	expected := "Configuration of cluster manager 'dev-manager' in the destination backend does not match the source, the source has been kept."
+
+	err := MigrateBackend(sourceBackend, destinationBackend, "dev-manager")
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+
+	sourceBackend.AssertNotCalled(t, "DeleteState", "dev-manager")
+}
+
+func TestMigrateBackendEncryptsSecrets(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+	viper.Set("encryption_passphrase", "correct horse")
+	defer viper.Reset()
+
+	secretKey, err := util.GetSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sourceState, _ := state.New("dev-manager", []byte(`{"terraform":{"backend":{"local":{"path":"/old"}}},"module":{"cluster-manager":{"rancher_admin_password":"hunter2"}}}`))
+	terraformState := []byte(`{"version": 3}`)
+
+	sourceBackend := &mocks.Backend{}
+	sourceBackend.On("States").Return([]string{"dev-manager"}, nil)
+	sourceBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	sourceBackend.On("State", "dev-manager").Return(sourceState, nil)
+	sourceBackend.On("TerraformState", "dev-manager").Return(terraformState, nil)
+	sourceBackend.On("DeleteState", "dev-manager").Return(nil)
+
+	var persistedState state.State
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	destinationBackend.On("States").Return([]string{}, nil)
+	destinationBackend.On("StateTerraformConfig", "dev-manager").Return("terraform.backend.manta", testTerraformBackendConfig{Path: "/new"})
+	destinationBackend.On("PersistState", mock.Anything).Run(func(args mock.Arguments) {
+		persisted := args.Get(0).(state.State)
+		persistedState, _ = state.New("dev-manager", persisted.Bytes())
+	}).Return(nil)
+	destinationBackend.On("PersistTerraformState", "dev-manager", terraformState).Return(nil)
+	destinationBackend.On("State", "dev-manager").Return(func(string) state.State {
+		return persistedState
+	}, nil)
+	destinationBackend.On("TerraformState", "dev-manager").Return(terraformState, nil)
+
+	err = MigrateBackend(sourceBackend, backend.WithSecretEncryption(destinationBackend, secretKey), "dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.IsEncryptedSecret(persistedState.Get("module.cluster-manager.rancher_admin_password")) {
+		t.Error("Expected the secret to be encrypted in the destination backend")
+	}
+	sourceBackend.AssertCalled(t, "DeleteState", "dev-manager")
+}
+
+func TestMigrateBackendLocksDestination(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	sourceBackend := &mocks.Backend{}
+	sourceBackend.On("States").Return([]string{"dev-manager"}, nil)
+
+	lockErr := &backend.LockError{Name: "dev-manager", Info: backend.NewLockInfo("create cluster")}
+	destinationBackend := &mocks.Backend{}
+	destinationBackend.On("Lock", "dev-manager", mock.Anything).Return(lockErr)
+
+	err := MigrateBackend(sourceBackend, destinationBackend, "dev-manager")
+	if err != lockErr {
+		t.Errorf("Expected the lock error, received %v", err)
+	}
+
+	destinationBackend.AssertNotCalled(t, "States")
+	destinationBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/joyent/triton-kubernetes/backend"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/viper"
+)
+
+// selectClusterManager returns the cluster manager given by name or `cluster_manager`,
+// or prompts for one of the existing cluster managers.
+func selectClusterManager(remoteBackend backend.Backend, name string) (string, error) {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+	clusterManagers, err := remoteBackend.States()
+	if err != nil {
+		return "", err
+	}
+
+	if len(clusterManagers) == 0 {
+		return "", fmt.Errorf("No cluster managers.")
+	}
+
+	selectedClusterManager := name
+	if selectedClusterManager == "" {
+		if viper.IsSet("cluster_manager") {
+			selectedClusterManager = viper.GetString("cluster_manager")
+		} else if nonInteractiveMode {
+			return "", errors.New("cluster_manager must be specified")
+		} else {
+			sort.Strings(clusterManagers)
+			prompt := promptui.Select{
+				Label: "Cluster Manager",
+				Items: clusterManagers,
+				Templates: &promptui.SelectTemplates{
+					Label:    "{{ . }}?",
+					Active:   fmt.Sprintf(`%s {{ . | underline }}`, promptui.IconSelect),
+					Inactive: `  {{ . }}`,
+					Selected: fmt.Sprintf(`{{ "%s" | green }} {{ "Cluster Manager:" | bold}} {{ . }}`, promptui.IconGood),
+				},
+			}
+
+			_, value, err := prompt.Run()
+			if err != nil {
+				return "", err
+			}
+
+			selectedClusterManager = value
+		}
+	}
+
+	// Verify selected cluster manager exists
+	found := false
+	for _, clusterManager := range clusterManagers {
+		if selectedClusterManager == clusterManager {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("Selected cluster manager '%s' does not exist.", selectedClusterManager)
+	}
+
+	return selectedClusterManager, nil
+}
@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/viper"
+)
+
+// Rollback restores a previous version of a cluster manager's configuration.
+// The restored configuration is kept as a new version, so a rollback can itself be rolled back.
+func Rollback(remoteBackend backend.Backend, name string, version int) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+
+	if version <= 0 {
+		return errors.New("Version to roll back to must be specified")
+	}
+
+	selectedClusterManager, err := selectClusterManager(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+
+	// Lock the cluster manager configuration for the rest of this run
+	lockInfo := backend.NewLockInfo("rollback manager")
+	err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+
+	currentState, err := remoteBackend.State(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	versionState, err := remoteBackend.HistoryState(selectedClusterManager, version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Changes: %s\n", summarizeChanges(currentState, versionState))
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("Roll back %q to version %d", selectedClusterManager, version)
+		selected := "Roll back"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Rollback canceled.")
+			return nil
+		}
+	}
+
+	// Replace the current configuration
+	versionState.Revision = currentState.Revision
+	err = remoteBackend.PersistState(versionState)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cluster manager '%s' rolled back to version %d.\n", selectedClusterManager, version)
+	fmt.Println("Infrastructure has not been changed, the restored configuration is applied by the next create or destroy command.")
+
+	return nil
+}
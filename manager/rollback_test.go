@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRollbackMissingVersion(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	localBackend := &mocks.Backend{}
+
+	expected := "Version to roll back to must be specified"
+
+	err := Rollback(localBackend, "dev-manager", 0)
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
+
+func TestRollbackMissingClusterManager(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager", "beta-manager"}, nil)
+
+	expected := "Selected cluster manager 'prod-manager' does not exist."
+
+	err := Rollback(localBackend, "prod-manager", 1)
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{},"cluster_triton_dev":{}}}`))
+	currentState.Revision = "2"
+	versionState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{}}}`))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+	localBackend.On("HistoryState", "dev-manager", 1).Return(versionState, nil)
+	localBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		// The current revision is kept so the write is not rejected as a conflict
+		return s.Revision == "2" && string(s.Bytes()) == string(versionState.Bytes())
+	})).Return(nil)
+
+	err := Rollback(localBackend, "dev-manager", 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
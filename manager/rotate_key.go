@@ -0,0 +1,108 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+// RotateKey re-encrypts the secrets of a cluster manager, or of all cluster managers if
+// name is empty, with newKey. Secrets that are still stored in plaintext are encrypted.
+// Every version in the history is re-encrypted as well, so any of them can be rolled back to.
+// oldKey is the key the secrets are currently encrypted with, nil if they aren't.
+func RotateKey(remoteBackend backend.Backend, name string, oldKey, newKey *state.SecretKey) error {
+	clusterManagers := []string{name}
+	if name == "" {
+		var err error
+		clusterManagers, err = remoteBackend.States()
+		if err != nil {
+			return err
+		}
+	} else {
+		// Validates the name
+		_, err := selectClusterManager(remoteBackend, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, clusterManager := range clusterManagers {
+		exists, err := remoteBackend.StateExists(clusterManager)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		err = rotateClusterManagerKey(remoteBackend, clusterManager, oldKey, newKey)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Secrets of cluster manager '%s' re-encrypted.\n", clusterManager)
+	}
+
+	return nil
+}
+
+func rotateClusterManagerKey(remoteBackend backend.Backend, name string, oldKey, newKey *state.SecretKey) error {
+	// Lock the cluster manager configuration while it is re-encrypted
+	lockInfo := backend.NewLockInfo("rotate-key")
+	err := remoteBackend.Lock(name, lockInfo)
+	if err != nil {
+		return err
+	}
+	defer remoteBackend.Unlock(name, lockInfo.ID)
+
+	// The history first, the current configuration is only re-encrypted once all versions are
+	history, err := remoteBackend.History(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range history {
+		versionState, err := remoteBackend.HistoryState(name, entry.Version)
+		if err != nil {
+			return err
+		}
+
+		err = rotateSecrets(&versionState, oldKey, newKey)
+		if err != nil {
+			return fmt.Errorf("Version %d of cluster manager '%s': %s", entry.Version, name, err)
+		}
+
+		err = remoteBackend.PersistHistoryState(versionState, entry.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	currentState, err := remoteBackend.State(name)
+	if err != nil {
+		return err
+	}
+
+	err = rotateSecrets(&currentState, oldKey, newKey)
+	if err != nil {
+		return fmt.Errorf("Cluster manager '%s': %s", name, err)
+	}
+
+	return remoteBackend.PersistState(currentState)
+}
+
+// rotateSecrets re-encrypts the secrets of currentState with newKey. Versions an interrupted
+// rotate-key already re-encrypted are left as they are, so it can be run again.
+func rotateSecrets(currentState *state.State, oldKey, newKey *state.SecretKey) error {
+	if currentState.HasEncryptedSecrets() {
+		decrypted, err := currentState.Clone()
+		if err != nil {
+			return err
+		}
+		if decrypted.DecryptSecrets(newKey) == nil {
+			return currentState.EncryptSecrets(newKey)
+		}
+	}
+
+	return currentState.RotateSecrets(oldKey, newKey)
+}
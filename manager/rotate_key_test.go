@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRotateKey(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	newKey, _ := state.NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+	devState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"rancher_admin_password":"hunter2"}}}`))
+	oldState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"rancher_admin_password":"hunter1"}}}`))
+	versionState, _ := devState.Clone()
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager", "canceled-manager"}, nil)
+	localBackend.On("StateExists", "dev-manager").Return(true, nil)
+	localBackend.On("StateExists", "canceled-manager").Return(false, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(devState, nil)
+	localBackend.On("History", "dev-manager").Return([]backend.HistoryEntry{{Version: 1}, {Version: 2}}, nil)
+	localBackend.On("HistoryState", "dev-manager", 1).Return(oldState, nil)
+	localBackend.On("HistoryState", "dev-manager", 2).Return(versionState, nil)
+	localBackend.On("PersistHistoryState", mock.MatchedBy(func(s state.State) bool {
+		return s.HasEncryptedSecrets() && !strings.Contains(string(s.Bytes()), "hunter")
+	}), mock.Anything).Return(nil).Twice()
+	localBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		return s.HasEncryptedSecrets() && !strings.Contains(string(s.Bytes()), "hunter2")
+	})).Return(nil)
+
+	err := RotateKey(localBackend, "", nil, newKey)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+	localBackend.AssertNotCalled(t, "State", "canceled-manager")
+}
+
+func TestRotateKeyInterrupted(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	oldKey, _ := state.NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+	newKey, _ := state.NewKeyfileSecretKey([]byte("fedcba9876543210fedcba9876543210"))
+
+	// Version 1 was re-encrypted before rotate-key was interrupted, version 2 wasn't
+	rotatedState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"rancher_admin_password":"hunter1"}}}`))
+	rotatedState.EncryptSecrets(newKey)
+	devState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"rancher_admin_password":"hunter2"}}}`))
+	devState.EncryptSecrets(oldKey)
+	versionState, _ := devState.Clone()
+
+	decryptsWithNewKey := mock.MatchedBy(func(s state.State) bool {
+		decrypted, _ := s.Clone()
+		return s.HasEncryptedSecrets() && decrypted.DecryptSecrets(newKey) == nil
+	})
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("StateExists", "dev-manager").Return(true, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(devState, nil)
+	localBackend.On("History", "dev-manager").Return([]backend.HistoryEntry{{Version: 1}, {Version: 2}}, nil)
+	localBackend.On("HistoryState", "dev-manager", 1).Return(rotatedState, nil)
+	localBackend.On("HistoryState", "dev-manager", 2).Return(versionState, nil)
+	localBackend.On("PersistHistoryState", decryptsWithNewKey, 1).Return(nil)
+	localBackend.On("PersistHistoryState", decryptsWithNewKey, 2).Return(nil)
+	localBackend.On("PersistState", decryptsWithNewKey).Return(nil)
+
+	err := RotateKey(localBackend, "dev-manager", oldKey, newKey)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
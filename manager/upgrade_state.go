@@ -0,0 +1,78 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/joyent/triton-kubernetes/util"
+
+	"github.com/spf13/viper"
+)
+
+// UpgradeState stores a cluster manager's configuration upgraded to the current schema version.
+// Configurations are upgraded in memory whenever they are loaded, this persists the upgrade.
+// With dryRun the upgrades and the changed modules are only printed.
+func UpgradeState(remoteBackend backend.Backend, name string, dryRun bool) error {
+	nonInteractiveMode := viper.GetBool("non-interactive")
+
+	selectedClusterManager, err := selectClusterManager(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+
+	if !dryRun {
+		// Lock the cluster manager configuration for the rest of this run
+		lockInfo := backend.NewLockInfo("state upgrade")
+		err = remoteBackend.Lock(selectedClusterManager, lockInfo)
+		if err != nil {
+			return err
+		}
+		defer remoteBackend.Unlock(selectedClusterManager, lockInfo.ID)
+	}
+
+	currentState, err := remoteBackend.State(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	storedState := currentState.Stored()
+	upgrades := currentState.AppliedUpgrades()
+	if len(upgrades) == 0 {
+		fmt.Printf("Cluster manager '%s' is already at schema version %d.\n", selectedClusterManager, currentState.SchemaVersion())
+		return nil
+	}
+
+	fmt.Printf("Cluster manager '%s' is at schema version %d, the current version is %d.\n", selectedClusterManager, storedState.SchemaVersion(), state.SchemaVersion)
+	fmt.Println("Upgrades:")
+	for _, upgrade := range upgrades {
+		fmt.Printf("  %s\n", upgrade)
+	}
+	fmt.Printf("Changes: %s\n", summarizeChanges(storedState, currentState))
+
+	if dryRun {
+		return nil
+	}
+
+	if !nonInteractiveMode {
+		label := fmt.Sprintf("Upgrade %q to schema version %d", selectedClusterManager, state.SchemaVersion)
+		selected := "Upgrade"
+		confirmed, err := util.PromptForConfirmation(label, selected)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Upgrade canceled.")
+			return nil
+		}
+	}
+
+	err = remoteBackend.PersistState(currentState)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cluster manager '%s' upgraded to schema version %d.\n", selectedClusterManager, state.SchemaVersion)
+
+	return nil
+}
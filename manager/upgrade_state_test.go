@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+)
+
+const legacyManagerConfig = `{"module":{"cluster-manager":{"name":"dev-manager"},"cluster_triton_dev":{"name":"dev"}}}`
+
+func TestUpgradeStateDryRun(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(legacyManagerConfig))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	err := UpgradeState(localBackend, "dev-manager", true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertNotCalled(t, "Lock", mock.Anything, mock.Anything)
+	localBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
+
+func TestUpgradeState(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(legacyManagerConfig))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+	localBackend.On("PersistState", mock.MatchedBy(func(s state.State) bool {
+		return s.SchemaVersion() == state.SchemaVersion
+	})).Return(nil)
+
+	err := UpgradeState(localBackend, "dev-manager", false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertExpectations(t)
+}
+
+func TestUpgradeStateCurrentVersion(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	upgradedState, _ := state.Load("dev-manager", []byte(legacyManagerConfig))
+	currentState, _ := state.Load("dev-manager", upgradedState.Bytes())
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("Lock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("Unlock", "dev-manager", mock.Anything).Return(nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	err := UpgradeState(localBackend, "dev-manager", false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	localBackend.AssertNotCalled(t, "PersistState", mock.Anything)
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+// ValidateState checks a cluster manager's configuration and prints the problems found.
+// An error is returned if there are any, so they are caught before terraform runs.
+func ValidateState(remoteBackend backend.Backend, name string) error {
+	selectedClusterManager, err := selectClusterManager(remoteBackend, name)
+	if err != nil {
+		return err
+	}
+
+	currentState, err := remoteBackend.State(selectedClusterManager)
+	if err != nil {
+		return err
+	}
+
+	findings := currentState.Validate()
+	if len(findings) == 0 {
+		fmt.Printf("Configuration of cluster manager '%s' is valid.\n", selectedClusterManager)
+		return nil
+	}
+
+	err = printFindings(findings)
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("Found %d problem(s) in the configuration of cluster manager '%s'.", len(findings), selectedClusterManager)
+}
+
+func printFindings(findings []state.Finding) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tPROBLEM")
+	for _, finding := range findings {
+		fmt.Fprintf(w, "%s\t%s\n", finding.Module, finding.Message)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/backend/mocks"
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateStateFindings(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"dev-manager"}}}`))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	expected := "Found 1 problem(s) in the configuration of cluster manager 'dev-manager'."
+
+	err := ValidateState(localBackend, "dev-manager")
+	if err == nil || expected != err.Error() {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
+
+func TestValidateStateValid(t *testing.T) {
+	viper.Reset()
+	viper.Set("non-interactive", true)
+
+	currentState, _ := state.Load("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"dev-manager","source":"./terraform/modules/triton-rancher"}}}`))
+
+	localBackend := &mocks.Backend{}
+	localBackend.On("States").Return([]string{"dev-manager"}, nil)
+	localBackend.On("State", "dev-manager").Return(currentState, nil)
+
+	err := ValidateState(localBackend, "dev-manager")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,88 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+// addModuleOutputs adds a root output for every module, named like the module. Terraform
+// only keeps the outputs of the root module, so this is how `terraform output -json`
+// gets to the outputs of a module.
+func addModuleOutputs(terraformState *state.State) error {
+	modules, ok := terraformState.Value("module")
+	if !ok {
+		return nil
+	}
+
+	moduleMap, ok := modules.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	for key := range moduleMap {
+		err := terraformState.Set(fmt.Sprintf("output.%s", key), map[string]interface{}{
+			"value": fmt.Sprintf("${module.%s}", key),
+			// Modules output secrets, e.g. rancher_secret_key
+			"sensitive": true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// terraformOutput is an output as printed by `terraform output -json`.
+type terraformOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Output decodes the outputs of the named module into result like json.Unmarshal, e.g. into
+// a struct with a `json:"rancher_url"` field.
+func (runner terraformRunner) Output(state state.State, moduleName string, result interface{}) error {
+	// Use the cluster manager's cached working directory
+	shellOptions, cleanup, err := runner.prepareWorkingDirectory(state)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	outputs, err := terraformOutputs(&shellOptions)
+	if err != nil {
+		return err
+	}
+
+	// Root outputs are only stored once terraform applied them, refreshing here would write
+	// the terraform state without holding the cluster manager's lock
+	output, ok := outputs[moduleName]
+	if !ok {
+		return fmt.Errorf("Module '%s' has no outputs yet, terraform stores them when it applies the configuration, e.g. on `triton-kubernetes create` or `triton-kubernetes apply`.", moduleName)
+	}
+
+	err = json.Unmarshal(output.Value, result)
+	if err != nil {
+		return fmt.Errorf("Could not read the outputs of module '%s': %s", moduleName, err)
+	}
+
+	return nil
+}
+
+// terraformOutputs runs `terraform output -json` and returns the outputs by name.
+func terraformOutputs(shellOptions *ShellOptions) (map[string]terraformOutput, error) {
+	content, err := runTerraformOutput(shellOptions, "output", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]terraformOutput{}
+	err = json.Unmarshal(content, &outputs)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read terraform outputs: %s", err)
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+func TestAddModuleOutputs(t *testing.T) {
+	terraformState, _ := state.New("dev-manager", []byte(`{
+		"module": {
+			"cluster-manager": {"source": "triton-rancher"},
+			"cluster_triton_dev": {"source": "triton-rancher-k8s"}
+		}
+	}`))
+
+	err := addModuleOutputs(&terraformState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"cluster-manager", "cluster_triton_dev"} {
+		value := terraformState.Get("output." + key + ".value")
+		if value != "${module."+key+"}" {
+			t.Errorf("Expected output %s to be the module, got %q", key, value)
+		}
+		sensitive, _ := terraformState.Value("output." + key + ".sensitive")
+		if sensitive != true {
+			t.Errorf("Expected output %s to be sensitive", key)
+		}
+	}
+}
+
+func TestAddModuleOutputsWithoutModules(t *testing.T) {
+	terraformState, _ := state.New("dev-manager", []byte(`{}`))
+
+	err := addModuleOutputs(&terraformState)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := terraformState.Value("output"); ok {
+		t.Error("Expected no outputs without modules")
+	}
+}
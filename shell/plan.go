@@ -0,0 +1,174 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/spf13/viper"
+)
+
+// File terraform plan writes the plan to in the working directory
+const planFileName = "triton-kubernetes.tfplan"
+
+// PlanChange is a resource terraform plans to change.
+type PlanChange struct {
+	Address string
+	Actions []string
+}
+
+// Symbol returns the symbol terraform uses for the change, e.g. + for create.
+func (change PlanChange) Symbol() string {
+	switch strings.Join(change.Actions, ",") {
+	case "create":
+		return "+"
+	case "update":
+		return "~"
+	case "delete":
+		return "-"
+	case "delete,create":
+		return "-/+"
+	case "create,delete":
+		return "+/-"
+	}
+
+	return "?"
+}
+
+// PlanSummary is what terraform plans to do.
+type PlanSummary struct {
+	Changes []PlanChange
+
+	Add     int
+	Change  int
+	Destroy int
+}
+
+// Plan runs terraform plan with args, e.g. -destroy and -target arguments, and prints
+// a summary of the resources terraform would add, change or destroy. Nothing is applied.
+// With --out the plan is saved together with plannedState to be applied later, see ApplyPlan.
+func (runner terraformRunner) Plan(currentState state.State, args []string, plannedState state.State) error {
+	// Use the cluster manager's cached working directory
+	shellOptions, cleanup, err := runner.prepareWorkingDirectory(currentState)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	// Run terraform plan
+	allArgs := append([]string{"plan", "-input=false", "-out=" + planFileName}, args...)
+	err = runTerraform(&shellOptions, allArgs...)
+	if err != nil {
+		return err
+	}
+
+	output, err := runTerraformOutput(&shellOptions, "show", "-json", planFileName)
+	if err != nil {
+		return err
+	}
+
+	summary, err := parsePlan(output)
+	if err != nil {
+		return err
+	}
+
+	outPath := viper.GetString("out")
+	printPlanSummary(runner.stdout, summary, outPath != "")
+	if outPath == "" {
+		return nil
+	}
+
+	plan, err := ioutil.ReadFile(filepath.Join(shellOptions.WorkingDir, planFileName))
+	if err != nil {
+		return err
+	}
+
+	err = WriteSavedPlan(outPath, SavedPlan{
+		Name:     currentState.Name,
+		Created:  time.Now().UTC(),
+		Revision: currentState.Revision,
+		Args:     args,
+		Config:   plannedState.Bytes(),
+		Plan:     plan,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(runner.stdout, "Plan saved to %s, apply it with `triton-kubernetes apply %s`.\n", outPath, outPath)
+
+	return nil
+}
+
+// parsePlan reads the output of `terraform show -json` for a plan file.
+func parsePlan(output []byte) (PlanSummary, error) {
+	plan := struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}{}
+
+	err := json.Unmarshal(output, &plan)
+	if err != nil {
+		return PlanSummary{}, fmt.Errorf("Could not read terraform plan: %s", err)
+	}
+
+	summary := PlanSummary{Changes: []PlanChange{}}
+	for _, resourceChange := range plan.ResourceChanges {
+		change := PlanChange{Address: resourceChange.Address, Actions: resourceChange.Change.Actions}
+
+		// Replacing a resource counts as both adding and destroying it, like terraform does
+		counted := false
+		for _, action := range change.Actions {
+			switch action {
+			case "create":
+				summary.Add++
+				counted = true
+			case "update":
+				summary.Change++
+				counted = true
+			case "delete":
+				summary.Destroy++
+				counted = true
+			}
+		}
+		if counted {
+			summary.Changes = append(summary.Changes, change)
+		}
+	}
+
+	sort.Slice(summary.Changes, func(i, j int) bool {
+		return summary.Changes[i].Address < summary.Changes[j].Address
+	})
+
+	return summary, nil
+}
+
+// printPlanSummary prints summary, saved tells whether the plan is saved with --out to be
+// applied later.
+func printPlanSummary(w io.Writer, summary PlanSummary, saved bool) {
+	fmt.Fprintln(w)
+	if len(summary.Changes) == 0 {
+		fmt.Fprintln(w, "No changes, the infrastructure matches the configuration.")
+	} else {
+		fmt.Fprintln(w, "Resources terraform would change:")
+		for _, change := range summary.Changes {
+			fmt.Fprintf(w, "  %-3s %s\n", change.Symbol(), change.Address)
+		}
+	}
+	fmt.Fprintf(w, "Plan: %d to add, %d to change, %d to destroy.", summary.Add, summary.Change, summary.Destroy)
+	if saved {
+		fmt.Fprintln(w, " Nothing was applied yet, the configuration is stored once the saved plan is applied.")
+	} else {
+		fmt.Fprintln(w, " Nothing was applied and the configuration was not stored.")
+	}
+}
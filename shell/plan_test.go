@@ -0,0 +1,127 @@
+package shell
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePlan(t *testing.T) {
+	output := []byte(`{
+		"format_version": "0.1",
+		"resource_changes": [
+			{"address": "module.node_triton_dev_worker.triton_machine.host", "change": {"actions": ["create"]}},
+			{"address": "module.cluster-manager.triton_machine.rancher", "change": {"actions": ["update"]}},
+			{"address": "module.cluster_triton_dev.rancher_cluster.cluster", "change": {"actions": ["delete", "create"]}},
+			{"address": "module.cluster-manager.null_resource.install", "change": {"actions": ["no-op"]}},
+			{"address": "module.cluster-manager.data.external.lookup", "change": {"actions": ["read"]}}
+		]
+	}`)
+
+	summary, err := parsePlan(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Add != 2 || summary.Change != 1 || summary.Destroy != 1 {
+		t.Errorf("Expected 2 to add, 1 to change and 1 to destroy, got %d, %d and %d", summary.Add, summary.Change, summary.Destroy)
+	}
+
+	expected := []string{
+		"~ module.cluster-manager.triton_machine.rancher",
+		"-/+ module.cluster_triton_dev.rancher_cluster.cluster",
+		"+ module.node_triton_dev_worker.triton_machine.host",
+	}
+	if len(summary.Changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d", len(expected), len(summary.Changes))
+	}
+	for i, change := range summary.Changes {
+		actual := change.Symbol() + " " + change.Address
+		if actual != expected[i] {
+			t.Errorf("Expected change %d to be %q, got %q", i, expected[i], actual)
+		}
+	}
+}
+
+func TestPrintPlanSummary(t *testing.T) {
+	summary := PlanSummary{Changes: []PlanChange{}, Add: 1}
+
+	tests := []struct {
+		saved    bool
+		expected string
+	}{
+		{false, "\nNo changes, the infrastructure matches the configuration.\nPlan: 1 to add, 0 to change, 0 to destroy. Nothing was applied and the configuration was not stored.\n"},
+		{true, "\nNo changes, the infrastructure matches the configuration.\nPlan: 1 to add, 0 to change, 0 to destroy. Nothing was applied yet, the configuration is stored once the saved plan is applied.\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		printPlanSummary(&buf, summary, test.saved)
+		if buf.String() != test.expected {
+			t.Errorf("Wrong output, expected %q, received %q", test.expected, buf.String())
+		}
+	}
+}
+
+func TestParsePlanInvalid(t *testing.T) {
+	_, err := parsePlan([]byte("not json"))
+	if err == nil {
+		t.Error("Expected an error for output that isn't a plan")
+	}
+}
+
+func TestSavedPlanDeletesManager(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{}, false},
+		{[]string{"-destroy"}, true},
+		{[]string{"-destroy", "-target=module.cluster_triton_dev"}, false},
+	}
+
+	for _, test := range tests {
+		savedPlan := SavedPlan{Args: test.args}
+		if savedPlan.DeletesManager() != test.expected {
+			t.Errorf("Expected DeletesManager to be %t for %v", test.expected, test.args)
+		}
+	}
+}
+
+func TestSavedPlanRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	planPath := filepath.Join(dir, "plan.tfplan")
+	err = WriteSavedPlan(planPath, SavedPlan{
+		Name:     "dev-manager",
+		Revision: "3",
+		Args:     []string{"-target=module.node_triton_dev_5f_worker"},
+		Config:   []byte(`{"module":{"cluster-manager":{"name":"dev-manager"}}}`),
+		Plan:     []byte{0x50, 0x4b, 0x03, 0x04},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedPlan, err := ReadSavedPlan(planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(savedPlan.Plan) != "PK\x03\x04" {
+		t.Errorf("Plan wasn't kept, got %q", savedPlan.Plan)
+	}
+
+	savedState, err := savedPlan.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if savedState.Revision != "3" || savedState.Get("module.cluster-manager.name") != "dev-manager" {
+		t.Errorf("Configuration wasn't kept, got revision %q and %s", savedState.Revision, savedState.Bytes())
+	}
+}
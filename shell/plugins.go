@@ -0,0 +1,398 @@
+package shell
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// Name of the manifest in a plugin mirror
+const pluginManifestFileName = "manifest.json"
+
+// The third party providers terraform can't install by itself, used unless plugin_manifest
+// is set or the plugin_mirror has a manifest.
+const defaultPluginManifest = `{
+	"providers": [
+		{
+			"name": "rke",
+			"namespace": "yamamoto-febc",
+			"version": "0.4.0",
+			"url": "https://github.com/yamamoto-febc/terraform-provider-rke/releases/download/{version}/terraform-provider-rke_{version}_{os}-{arch}.zip",
+			"sha256": {
+				"darwin_386": "b8b3085b06307619a98b83dd9901f8504169e83948843dc1af79aa058dfc03ee",
+				"darwin_amd64": "c6abfdee7c4db0bd8d068172c9c97beee4f382e1578ad0588f0347a725fd5562",
+				"linux_386": "4b8b3d76efd4e64813de820342c79cf60bffe6726d6a15a2306d636f5ae1dbfe",
+				"linux_amd64": "3c9bc09b389d00a4e130e4674c5aae6a5284417dbbc4de3c7e14a9a20eabe51c",
+				"windows_386": "b8a4594edadf9489b331939453b61cdbd383f380645f50f1411b6796155b7f73",
+				"windows_amd64": "1975fa24b2aa5830d884649a34adee6136e213cf5edd021513232d77eb50820c"
+			}
+		}
+	]
+}`
+
+// PluginManifest lists the third party providers to install into terraform's working directory.
+type PluginManifest struct {
+	Providers []PluginProvider `json:"providers"`
+}
+
+// PluginProvider is a provider released as a zip archive per platform.
+type PluginProvider struct {
+	Name string `json:"name"`
+
+	// Namespace of the provider's source address the modules require, e.g. yamamoto-febc
+	// for yamamoto-febc/rke
+	Namespace string `json:"namespace"`
+	Version   string `json:"version"`
+
+	// URL of the archive, {version}, {os} and {arch} are replaced, e.g. {os} with linux
+	URL string `json:"url"`
+
+	// SHA256 checksums of the archives by platform, e.g. linux_amd64
+	SHA256 map[string]string `json:"sha256"`
+}
+
+// archiveURL returns the URL of the provider's archive for a platform, e.g. linux and amd64.
+func (provider PluginProvider) archiveURL(goos, goarch string) string {
+	replacer := strings.NewReplacer("{version}", provider.Version, "{os}", goos, "{arch}", goarch)
+	return replacer.Replace(provider.URL)
+}
+
+// archiveName returns the file name of the provider's archive for a platform, which is
+// also its name in a plugin mirror.
+func (provider PluginProvider) archiveName(goos, goarch string) string {
+	return path.Base(provider.archiveURL(goos, goarch))
+}
+
+// platforms returns the platforms the provider has checksums for, sorted.
+func (provider PluginProvider) platforms() []string {
+	platforms := []string{}
+	for platform := range provider.SHA256 {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	return platforms
+}
+
+func parsePluginManifest(content []byte) (PluginManifest, error) {
+	manifest := PluginManifest{}
+	err := json.Unmarshal(content, &manifest)
+	if err != nil {
+		return PluginManifest{}, fmt.Errorf("Could not read plugin manifest: %s", err)
+	}
+
+	for _, provider := range manifest.Providers {
+		if provider.Name == "" || provider.Namespace == "" || provider.Version == "" || provider.URL == "" {
+			return PluginManifest{}, errors.New("Plugin manifest providers need a name, namespace, version and url")
+		}
+	}
+
+	return manifest, nil
+}
+
+// pluginMirror returns the plugin_mirror setting, a directory or an http(s) URL, or an
+// empty string if plugins are downloaded from their release URLs.
+func pluginMirror() (string, error) {
+	mirror := viper.GetString("plugin_mirror")
+	if mirror == "" || isURL(mirror) {
+		return mirror, nil
+	}
+
+	return homedir.Expand(mirror)
+}
+
+// loadPluginManifest returns the manifest given by plugin_manifest, the one in the plugin
+// mirror, or the default manifest, in that order.
+func loadPluginManifest() (PluginManifest, error) {
+	if viper.IsSet("plugin_manifest") {
+		manifestPath, err := homedir.Expand(viper.GetString("plugin_manifest"))
+		if err != nil {
+			return PluginManifest{}, err
+		}
+
+		content, err := ioutil.ReadFile(manifestPath)
+		if err != nil {
+			return PluginManifest{}, err
+		}
+
+		return parsePluginManifest(content)
+	}
+
+	mirror, err := pluginMirror()
+	if err != nil {
+		return PluginManifest{}, err
+	}
+	if mirror != "" {
+		content, err := fetch(joinLocation(mirror, pluginManifestFileName))
+		if err == nil {
+			return parsePluginManifest(content)
+		}
+		if !os.IsNotExist(err) {
+			return PluginManifest{}, err
+		}
+	}
+
+	return parsePluginManifest([]byte(defaultPluginManifest))
+}
+
+// fetchPluginArchive returns the provider's archive for a platform from the plugin mirror,
+// or from its release URL if there is no mirror, after verifying its checksum.
+func fetchPluginArchive(provider PluginProvider, mirror, goos, goarch string) ([]byte, error) {
+	platform := fmt.Sprintf("%s_%s", goos, goarch)
+	checksum, ok := provider.SHA256[platform]
+	if !ok {
+		return nil, fmt.Errorf("Plugin %s %s has no checksum for %s", provider.Name, provider.Version, platform)
+	}
+
+	location := provider.archiveURL(goos, goarch)
+	if mirror != "" {
+		location = joinLocation(mirror, provider.archiveName(goos, goarch))
+	}
+
+	content, err := fetch(location)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Plugin %s %s for %s is not in the plugin mirror, run `triton-kubernetes plugins sync`", provider.Name, provider.Version, platform)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyChecksum(content, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("Plugin %s %s for %s: %s", provider.Name, provider.Version, platform, err)
+	}
+
+	return content, nil
+}
+
+func verifyChecksum(content []byte, expected string) error {
+	sum := sha256.Sum256(content)
+	actual := hex.EncodeToString(sum[:])
+	if actual != strings.ToLower(expected) {
+		return fmt.Errorf("checksum %s doesn't match the expected %s", actual, expected)
+	}
+
+	return nil
+}
+
+// installThirdPartyProviders installs the plugins of the plugin manifest for this platform.
+// This func will install terraform plugins regardless of whether they're needed for this particular execution.
+// Terraform doesn't currently support automatically installing third party plugins, issue being tracked here
+// https://github.com/hashicorp/terraform/issues/17154
+//
+// Plugins are unpacked once into cacheDirectory and copied into the working directory from there,
+// in the layout terraform looks for local providers in, see pluginInstallDirectory.
+func installThirdPartyProviders(workingDirectory, cacheDirectory string, manifest PluginManifest, stdout io.Writer) error {
+	mirror, err := pluginMirror()
+	if err != nil {
+		return err
+	}
+
+	thirdPartyDirectory := filepath.Join(cacheDirectory, "third-party")
+	err = os.MkdirAll(thirdPartyDirectory, 0700)
+	if err != nil {
+		return err
+	}
+
+	for _, provider := range manifest.Providers {
+		archiveName := provider.archiveName(runtime.GOOS, runtime.GOARCH)
+		pluginCachePath := filepath.Join(thirdPartyDirectory, strings.TrimSuffix(archiveName, ".zip"))
+
+		err := cachePlugin(provider, mirror, pluginCachePath, stdout)
+		if err != nil {
+			return err
+		}
+
+		err = copyPlugins(pluginCachePath, pluginInstallDirectory(workingDirectory, provider))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pluginInstallDirectory returns the directory terraform 0.13 and later finds the provider in,
+// below the working directory's terraform.d/plugins, e.g.
+// terraform.d/plugins/registry.terraform.io/yamamoto-febc/rke/0.4.0/linux_amd64.
+func pluginInstallDirectory(workingDirectory string, provider PluginProvider) string {
+	return filepath.Join(workingDirectory, "terraform.d", "plugins", "registry.terraform.io",
+		provider.Namespace, provider.Name, provider.Version, fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH))
+}
+
+// cachePlugin unpacks the provider's archive for this platform into pluginCachePath, unless
+// it's already there. Runs for different cluster managers share the cache, so they wait for
+// each other instead of unpacking the same plugin underneath each other.
+func cachePlugin(provider PluginProvider, mirror, pluginCachePath string, stdout io.Writer) error {
+	unlock, err := lockFile(pluginCachePath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	completePath := filepath.Join(pluginCachePath, ".complete")
+	if _, err := os.Stat(completePath); err == nil {
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "Installing plugin %s %s...\n", provider.Name, provider.Version)
+
+	archive, err := fetchPluginArchive(provider, mirror, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
+	err = unzip(archive, pluginCachePath)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(completePath, []byte{}, 0600)
+}
+
+// SyncPlugins downloads the plugins of the plugin manifest for every platform it lists
+// into the mirror directory, together with the manifest. Plugins already in the mirror
+// with the right checksum are kept.
+func SyncPlugins(mirror string) error {
+	if mirror == "" || isURL(mirror) {
+		return errors.New("plugin_mirror must be a directory to sync plugins into")
+	}
+
+	manifest, err := loadPluginManifest()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(mirror, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, provider := range manifest.Providers {
+		for _, platform := range provider.platforms() {
+			parts := strings.SplitN(platform, "_", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("Plugin %s %s has an invalid platform '%s'", provider.Name, provider.Version, platform)
+			}
+			goos, goarch := parts[0], parts[1]
+
+			archivePath := filepath.Join(mirror, provider.archiveName(goos, goarch))
+			existing, err := ioutil.ReadFile(archivePath)
+			if err == nil && verifyChecksum(existing, provider.SHA256[platform]) == nil {
+				fmt.Printf("%s %s for %s is up to date\n", provider.Name, provider.Version, platform)
+				continue
+			}
+
+			fmt.Printf("Downloading %s %s for %s...\n", provider.Name, provider.Version, platform)
+			archive, err := fetchPluginArchive(provider, "", goos, goarch)
+			if err != nil {
+				return err
+			}
+
+			err = ioutil.WriteFile(archivePath, archive, 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(mirror, pluginManifestFileName), append(content, '\n'), 0644)
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func joinLocation(base, name string) string {
+	if isURL(base) {
+		return strings.TrimSuffix(base, "/") + "/" + name
+	}
+
+	return filepath.Join(base, name)
+}
+
+// fetch returns the content of a file or http(s) URL. A missing file and a 404 both
+// return an error for which os.IsNotExist is true.
+func fetch(location string) ([]byte, error) {
+	if !isURL(location) {
+		return ioutil.ReadFile(location)
+	}
+
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: "get", Path: location, Err: os.ErrNotExist}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Could not download %s: %s", location, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// unzip extracts the files of a zip archive into dst, plugins are made executable.
+func unzip(archive []byte, dst string) error {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dst, 0700)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		// Plugin archives are flat, anything else is not extracted
+		name := filepath.Base(file.Name)
+		if name != file.Name || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("Unexpected file '%s' in plugin archive", file.Name)
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadAll(src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dst, name), content, 0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package shell
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// testPluginArchive returns a plugin archive with terraform-provider-fake_v1.0.0 and its checksum.
+func testPluginArchive(t *testing.T) ([]byte, string) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	file, err := zipWriter.Create("terraform-provider-fake_v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("plugin"))
+	err = zipWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), hex.EncodeToString(sum[:])
+}
+
+// writeTestMirror writes a plugin mirror with the fake provider for this platform to dir.
+func writeTestMirror(t *testing.T, dir string) {
+	archive, checksum := testPluginArchive(t)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archiveName := fmt.Sprintf("terraform-provider-fake_1.0.0_%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	err = ioutil.WriteFile(filepath.Join(dir, archiveName), archive, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := fmt.Sprintf(`{"providers": [{
+		"name": "fake",
+		"namespace": "example",
+		"version": "1.0.0",
+		"url": "https://example.com/{version}/terraform-provider-fake_{version}_{os}-{arch}.zip",
+		"sha256": {"%s_%s": "%s"}
+	}]}`, runtime.GOOS, runtime.GOARCH, checksum)
+	err = ioutil.WriteFile(filepath.Join(dir, pluginManifestFileName), []byte(manifest), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultPluginManifest(t *testing.T) {
+	viper.Reset()
+
+	manifest, err := loadPluginManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Providers) != 1 || manifest.Providers[0].Name != "rke" {
+		t.Fatalf("Expected the rke provider, got %+v", manifest.Providers)
+	}
+
+	provider := manifest.Providers[0]
+	expected := "https://github.com/yamamoto-febc/terraform-provider-rke/releases/download/0.4.0/terraform-provider-rke_0.4.0_linux-amd64.zip"
+	if provider.archiveURL("linux", "amd64") != expected {
+		t.Errorf("Expected %s, got %s", expected, provider.archiveURL("linux", "amd64"))
+	}
+	if len(provider.platforms()) != 6 {
+		t.Errorf("Expected checksums for 6 platforms, got %v", provider.platforms())
+	}
+}
+
+func TestInstallThirdPartyProvidersFromMirror(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mirror := filepath.Join(dir, "mirror")
+	writeTestMirror(t, mirror)
+
+	viper.Reset()
+	viper.Set("plugin_mirror", mirror)
+	defer viper.Reset()
+
+	manifest, err := loadPluginManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workingDir := filepath.Join(dir, "work")
+	err = installThirdPartyProviders(workingDir, filepath.Join(dir, "cache"), manifest, os.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The layout terraform 0.13 and later finds the example/fake provider in
+	pluginPath := filepath.Join(workingDir, "terraform.d", "plugins", "registry.terraform.io", "example", "fake", "1.0.0", runtime.GOOS+"_"+runtime.GOARCH, "terraform-provider-fake_v1.0.0")
+	content, err := ioutil.ReadFile(pluginPath)
+	if err != nil || string(content) != "plugin" {
+		t.Errorf("Expected the plugin to be installed, got %q, %v", content, err)
+	}
+}
+
+func TestPluginManifestNeedsNamespace(t *testing.T) {
+	_, err := parsePluginManifest([]byte(`{"providers": [{"name": "fake", "version": "1.0.0", "url": "https://example.com/fake.zip"}]}`))
+	if err == nil {
+		t.Error("Expected a provider without a namespace to be refused")
+	}
+}
+
+func TestInstallThirdPartyProvidersChecksumMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mirror := filepath.Join(dir, "mirror")
+	writeTestMirror(t, mirror)
+
+	// Replace the archive the manifest has the checksum of
+	archiveName := fmt.Sprintf("terraform-provider-fake_1.0.0_%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	ioutil.WriteFile(filepath.Join(mirror, archiveName), []byte("tampered"), 0600)
+
+	viper.Reset()
+	viper.Set("plugin_mirror", mirror)
+	defer viper.Reset()
+
+	manifest, err := loadPluginManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = installThirdPartyProviders(filepath.Join(dir, "work"), filepath.Join(dir, "cache"), manifest, os.Stdout)
+	if err == nil {
+		t.Error("Expected the tampered archive to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache", "third-party", strings.TrimSuffix(archiveName, ".zip"))); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be cached")
+	}
+}
+
+func TestInstallThirdPartyProvidersMissingFromMirror(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A mirror without a manifest uses the default manifest, whose plugins it doesn't have
+	viper.Reset()
+	viper.Set("plugin_mirror", dir)
+	defer viper.Reset()
+
+	manifest, err := loadPluginManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = installThirdPartyProviders(filepath.Join(dir, "work"), filepath.Join(dir, "cache"), manifest, os.Stdout)
+	expected := fmt.Sprintf("Plugin rke 0.4.0 for %s_%s is not in the plugin mirror, run `triton-kubernetes plugins sync`", runtime.GOOS, runtime.GOARCH)
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
+
+func TestSyncPluginsKeepsVerifiedArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The archive is in the mirror already, so nothing is downloaded
+	source := filepath.Join(dir, "source")
+	writeTestMirror(t, source)
+
+	viper.Reset()
+	viper.Set("plugin_manifest", filepath.Join(source, pluginManifestFileName))
+	defer viper.Reset()
+
+	err = SyncPlugins(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := ioutil.ReadFile(filepath.Join(source, pluginManifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parsePluginManifest(manifest)
+	if err != nil || len(parsed.Providers) != 1 || parsed.Providers[0].Name != "fake" {
+		t.Errorf("Expected the manifest to be written to the mirror, got %s", manifest)
+	}
+}
+
+func TestSyncPluginsToURL(t *testing.T) {
+	expected := "plugin_mirror must be a directory to sync plugins into"
+
+	err := SyncPlugins("https://example.com/plugins")
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wrong output, expected %s, received %v", expected, err)
+	}
+}
@@ -13,6 +13,12 @@ func runShellCommand(options *ShellOptions, command string, args ...string) erro
 
 	if options != nil {
 		cmd.Dir = options.WorkingDir
+		if options.Stdout != nil {
+			cmd.Stdout = options.Stdout
+		}
+		if len(options.Env) > 0 {
+			cmd.Env = append(os.Environ(), options.Env...)
+		}
 	}
 
 	err := cmd.Start()
@@ -27,3 +33,30 @@ func runShellCommand(options *ShellOptions, command string, args ...string) erro
 
 	return nil
 }
+
+// runShellCommandOutput runs a command like runShellCommand, but returns its output
+// instead of printing it.
+func runShellCommandOutput(options *ShellOptions, command string, args ...string) ([]byte, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	if options != nil {
+		cmd.Dir = options.WorkingDir
+		if len(options.Env) > 0 {
+			cmd.Env = append(os.Environ(), options.Env...)
+		}
+	}
+
+	return cmd.Output()
+}
+
+// runTerraform runs the terraform binary of options with args.
+func runTerraform(options *ShellOptions, args ...string) error {
+	return runShellCommand(options, options.Terraform, args...)
+}
+
+// runTerraformOutput runs the terraform binary of options with args and returns its output.
+func runTerraformOutput(options *ShellOptions, args ...string) ([]byte, error) {
+	return runShellCommandOutput(options, options.Terraform, args...)
+}
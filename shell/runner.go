@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"io"
+	"os"
+
+	"github.com/joyent/triton-kubernetes/backend"
+	"github.com/joyent/triton-kubernetes/state"
+	"github.com/spf13/viper"
+)
+
+// Runner runs terraform for a cluster manager's configuration. The create, destroy and get
+// flows only talk to terraform through a Runner, so tests can replace it, see shelltest.
+type Runner interface {
+	// Init prepares the working directory of currentState and runs terraform init if needed.
+	Init(currentState state.State) error
+
+	// Plan runs terraform plan with args and prints a summary, nothing is applied.
+	// plannedState is the configuration that is stored once a plan saved with --out is
+	// applied, e.g. currentState without the modules a destroy plan destroys.
+	Plan(currentState state.State, args []string, plannedState state.State) error
+
+	// Apply runs terraform apply.
+	Apply(currentState state.State) error
+
+	// ApplyPlan applies exactly the changes of a plan saved with --out.
+	ApplyPlan(savedPlan SavedPlan) error
+
+	// Destroy runs terraform destroy with args, e.g. -target arguments.
+	Destroy(currentState state.State, args []string) error
+
+	// StateRm removes addresses from the terraform state without destroying anything.
+	StateRm(currentState state.State, addresses []string) error
+
+	// Output decodes the outputs of the named module into result like json.Unmarshal.
+	Output(currentState state.State, moduleName string, result interface{}) error
+
+	// RemoveWorkingDirectory removes the named cluster manager's working directory.
+	RemoveWorkingDirectory(name string) error
+}
+
+// terraformRunner runs the terraform binary in the cluster manager's cached working directory.
+type terraformRunner struct {
+	// Where terraform's output and progress messages go
+	stdout io.Writer
+
+	// Environment variables for terraform's backend, see backend.Backend's TerraformEnv
+	backendEnv []string
+}
+
+// NewRunner returns a Runner that runs terraform for cluster managers stored in
+// remoteBackend, its output goes to os.Stdout.
+func NewRunner(remoteBackend backend.Backend) Runner {
+	return NewRunnerWithOutput(remoteBackend, os.Stdout)
+}
+
+// NewRunnerWithOutput returns a Runner like NewRunner whose output goes to stdout, e.g.
+// os.Stderr to keep os.Stdout for results.
+func NewRunnerWithOutput(remoteBackend backend.Backend, stdout io.Writer) Runner {
+	return terraformRunner{stdout: stdout, backendEnv: remoteBackend.TerraformEnv()}
+}
+
+func (runner terraformRunner) Init(currentState state.State) error {
+	// Nothing runs that needs terraform to be initialized
+	if viper.GetBool("terraform-configuration") {
+		return nil
+	}
+
+	_, cleanup, err := runner.prepareWorkingDirectory(currentState)
+	defer cleanup()
+
+	return err
+}
@@ -0,0 +1,128 @@
+package shell
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+// Version of the saved plan layout written by WriteSavedPlan
+const savedPlanVersion = 1
+
+// SavedPlan is a terraform plan saved with --out, together with the configuration that is
+// stored once it's applied, e.g. without the modules a destroy plan destroys. Nothing in
+// it is encrypted: only secrets that were already encrypted in the stored configuration
+// stay encrypted, secrets added by the planned command are in plaintext, and the terraform
+// plan holds the values terraform works with in plaintext.
+type SavedPlan struct {
+	Version int       `json:"version"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+
+	// Revision of the stored configuration the plan was made against, empty for a new
+	// cluster manager
+	Revision string `json:"revision"`
+
+	// Arguments terraform plan was run with, e.g. -destroy and -target arguments
+	Args []string `json:"args"`
+
+	Config json.RawMessage `json:"config"`
+	Plan   []byte          `json:"plan"`
+}
+
+// DeletesManager returns true if the plan destroys all of the cluster manager's
+// infrastructure, in which case its configuration is deleted once the plan is applied.
+func (savedPlan SavedPlan) DeletesManager() bool {
+	destroy := false
+	for _, arg := range savedPlan.Args {
+		if arg == "-destroy" {
+			destroy = true
+		}
+		if strings.HasPrefix(arg, "-target=") {
+			return false
+		}
+	}
+
+	return destroy
+}
+
+// State returns the configuration that is stored once the plan is applied.
+func (savedPlan SavedPlan) State() (state.State, error) {
+	savedState, err := state.Load(savedPlan.Name, savedPlan.Config)
+	if err != nil {
+		return state.State{}, err
+	}
+	savedState.Revision = savedPlan.Revision
+
+	return savedState, nil
+}
+
+// WriteSavedPlan writes savedPlan to path, readable by the owner only.
+func WriteSavedPlan(path string, savedPlan SavedPlan) error {
+	savedPlan.Version = savedPlanVersion
+
+	content, err := json.MarshalIndent(savedPlan, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// ReadSavedPlan reads a plan written by WriteSavedPlan.
+func ReadSavedPlan(path string) (SavedPlan, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SavedPlan{}, err
+	}
+
+	savedPlan := SavedPlan{}
+	err = json.Unmarshal(content, &savedPlan)
+	if err != nil || savedPlan.Version == 0 {
+		return SavedPlan{}, fmt.Errorf("'%s' is not a plan saved with --out", path)
+	}
+	if savedPlan.Version > savedPlanVersion {
+		return SavedPlan{}, fmt.Errorf("Plan version %d is not supported, update triton-kubernetes", savedPlan.Version)
+	}
+	if savedPlan.Name == "" || len(savedPlan.Config) == 0 || len(savedPlan.Plan) == 0 {
+		return SavedPlan{}, errors.New("Plan is incomplete, save it again with --out")
+	}
+
+	return savedPlan, nil
+}
+
+// ApplyPlan applies exactly the changes of savedPlan. Terraform refuses the plan if the
+// infrastructure's state changed since the plan was made.
+func (runner terraformRunner) ApplyPlan(savedPlan SavedPlan) error {
+	savedState, err := savedPlan.State()
+	if err != nil {
+		return err
+	}
+
+	// Use the cluster manager's cached working directory. Terraform applies the plan with
+	// the configuration the plan was made with, which the plan file holds itself.
+	shellOptions, cleanup, err := runner.prepareWorkingDirectory(savedState)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(shellOptions.WorkingDir, planFileName), savedPlan.Plan, 0600)
+	if err != nil {
+		return err
+	}
+
+	// Run terraform apply with the plan, no approval is asked for a saved plan
+	err = runTerraform(&shellOptions, "apply", "-input=false", planFileName)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
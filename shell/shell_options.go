@@ -1,5 +1,16 @@
 package shell
 
+import "io"
+
 type ShellOptions struct {
 	WorkingDir string
+
+	// Path of the terraform binary runTerraform runs
+	Terraform string
+
+	// Environment variables set in addition to the current environment, e.g. KEY=value
+	Env []string
+
+	// Where the command's output goes, os.Stdout if nil
+	Stdout io.Writer
 }
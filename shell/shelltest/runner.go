@@ -0,0 +1,132 @@
+// Package shelltest provides a shell.Runner for tests that records terraform invocations
+// instead of running terraform.
+package shelltest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/joyent/triton-kubernetes/shell"
+	"github.com/joyent/triton-kubernetes/state"
+)
+
+// Invocation is a terraform command a Runner was asked to run.
+type Invocation struct {
+	// Command is the Runner method, e.g. destroy
+	Command string
+
+	// Args are the terraform arguments, e.g. -target arguments, addresses or the module name
+	Args []string
+
+	// State is the configuration terraform would have run with
+	State state.State
+
+	// PlannedState is the configuration a saved plan would store, only set for plan
+	PlannedState state.State
+}
+
+// Runner records the terraform invocations of a test. Errors returns an error for a command
+// instead of recording it, Outputs holds the outputs of modules as JSON by module name.
+type Runner struct {
+	Invocations []Invocation
+	Errors      map[string]error
+	Outputs     map[string]string
+}
+
+// New returns a Runner without invocations.
+func New() *Runner {
+	return &Runner{
+		Invocations: []Invocation{},
+		Errors:      map[string]error{},
+		Outputs:     map[string]string{},
+	}
+}
+
+// Commands returns the recorded commands, e.g. [init destroy].
+func (runner *Runner) Commands() []string {
+	commands := []string{}
+	for _, invocation := range runner.Invocations {
+		commands = append(commands, invocation.Command)
+	}
+
+	return commands
+}
+
+// Last returns the last recorded invocation of command and false if there is none.
+func (runner *Runner) Last(command string) (Invocation, bool) {
+	for i := len(runner.Invocations) - 1; i >= 0; i-- {
+		if runner.Invocations[i].Command == command {
+			return runner.Invocations[i], true
+		}
+	}
+
+	return Invocation{}, false
+}
+
+func (runner *Runner) record(command string, currentState state.State, args []string) error {
+	if err, ok := runner.Errors[command]; ok {
+		return err
+	}
+
+	runner.Invocations = append(runner.Invocations, Invocation{
+		Command: command,
+		Args:    append([]string{}, args...),
+		State:   currentState,
+	})
+
+	return nil
+}
+
+func (runner *Runner) Init(currentState state.State) error {
+	return runner.record("init", currentState, []string{})
+}
+
+func (runner *Runner) Plan(currentState state.State, args []string, plannedState state.State) error {
+	err := runner.record("plan", currentState, args)
+	if err != nil {
+		return err
+	}
+	runner.Invocations[len(runner.Invocations)-1].PlannedState = plannedState
+
+	return nil
+}
+
+func (runner *Runner) Apply(currentState state.State) error {
+	return runner.record("apply", currentState, []string{})
+}
+
+func (runner *Runner) ApplyPlan(savedPlan shell.SavedPlan) error {
+	savedState, err := savedPlan.State()
+	if err != nil {
+		return err
+	}
+
+	return runner.record("apply-plan", savedState, savedPlan.Args)
+}
+
+func (runner *Runner) Destroy(currentState state.State, args []string) error {
+	return runner.record("destroy", currentState, args)
+}
+
+func (runner *Runner) StateRm(currentState state.State, addresses []string) error {
+	return runner.record("state-rm", currentState, addresses)
+}
+
+func (runner *Runner) Output(currentState state.State, moduleName string, result interface{}) error {
+	err := runner.record("output", currentState, []string{moduleName})
+	if err != nil {
+		return err
+	}
+
+	output, ok := runner.Outputs[moduleName]
+	if !ok {
+		return fmt.Errorf("Module '%s' has no outputs, it has not been created yet.", moduleName)
+	}
+
+	return json.Unmarshal([]byte(output), result)
+}
+
+func (runner *Runner) RemoveWorkingDirectory(name string) error {
+	currentState, _ := state.New(name, []byte("{}"))
+	return runner.record("remove-working-directory", currentState, []string{})
+}
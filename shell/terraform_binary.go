@@ -0,0 +1,243 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// Terraform versions the generated configuration and the modules work with
+const SupportedTerraformVersions = ">= 0.13.0, < 2.0.0"
+
+// Terraform version installed by InstallTerraform unless another one is given
+const PinnedTerraformVersion = "1.5.7"
+
+// Directory InstallTerraform installs terraform into, unless bin_dir is set
+const defaultBinDirectory = "~/.triton-kubernetes/bin"
+
+// Where terraform releases are downloaded from, unless terraform_mirror is set
+const defaultTerraformMirror = "https://releases.hashicorp.com/terraform"
+
+var terraformVersionPattern = regexp.MustCompile(`^Terraform v(\S+)`)
+
+// Terraform binaries whose version was already checked in this run, by path
+var checkedTerraformBinaries = map[string]*version.Version{}
+
+// binDirectory returns the directory InstallTerraform installs terraform into.
+func binDirectory() (string, error) {
+	binDir := defaultBinDirectory
+	if viper.IsSet("bin_dir") {
+		binDir = viper.GetString("bin_dir")
+	}
+
+	return homedir.Expand(binDir)
+}
+
+func terraformExecutableName() string {
+	if runtime.GOOS == "windows" {
+		return "terraform.exe"
+	}
+
+	return "terraform"
+}
+
+// findTerraform returns the path of the terraform binary to run: the one set by
+// terraform_path, the one installed by InstallTerraform, or the first one on PATH.
+func findTerraform() (string, error) {
+	if viper.GetString("terraform_path") != "" {
+		terraformPath, err := homedir.Expand(viper.GetString("terraform_path"))
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := os.Stat(terraformPath); err != nil {
+			return "", fmt.Errorf("terraform_path '%s' can't be used: %s", terraformPath, err)
+		}
+
+		return terraformPath, nil
+	}
+
+	binDir, err := binDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	installedPath := filepath.Join(binDir, terraformExecutableName())
+	if _, err := os.Stat(installedPath); err == nil {
+		return installedPath, nil
+	}
+
+	terraformPath, err := exec.LookPath("terraform")
+	if err != nil {
+		return "", errors.New("terraform was not found on PATH, set terraform_path or run `triton-kubernetes terraform install`")
+	}
+
+	return terraformPath, nil
+}
+
+// TerraformVersion returns the version of the terraform binary at terraformPath.
+func TerraformVersion(terraformPath string) (*version.Version, error) {
+	cmd := exec.Command(terraformPath, "version")
+	// Don't let terraform look for a newer version while it's asked for its own
+	cmd.Env = append(os.Environ(), "CHECKPOINT_DISABLE=1")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Could not run '%s version': %s", terraformPath, err)
+	}
+
+	firstLine, _ := bufio.NewReader(bytes.NewReader(output)).ReadString('\n')
+	matches := terraformVersionPattern.FindStringSubmatch(strings.TrimSpace(firstLine))
+	if matches == nil {
+		return nil, fmt.Errorf("Could not read the version of '%s' from %q", terraformPath, strings.TrimSpace(firstLine))
+	}
+
+	return version.NewVersion(matches[1])
+}
+
+// checkTerraformVersion returns an error if v is not one of the SupportedTerraformVersions.
+func checkTerraformVersion(v *version.Version) error {
+	constraints, err := version.NewConstraint(SupportedTerraformVersions)
+	if err != nil {
+		return err
+	}
+
+	if !constraints.Check(v) {
+		return fmt.Errorf("Terraform %s is not supported, triton-kubernetes needs terraform %s", v, SupportedTerraformVersions)
+	}
+
+	return nil
+}
+
+// Terraform returns the path of the terraform binary to run, after checking that its version
+// is one of the SupportedTerraformVersions. The version is only checked once per binary.
+func Terraform() (string, error) {
+	terraformPath, err := findTerraform()
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := checkedTerraformBinaries[terraformPath]; ok {
+		return terraformPath, nil
+	}
+
+	v, err := TerraformVersion(terraformPath)
+	if err != nil {
+		return "", err
+	}
+
+	err = checkTerraformVersion(v)
+	if err != nil {
+		return "", fmt.Errorf("%s, '%s' can't be used. Set terraform_path or run `triton-kubernetes terraform install`", err, terraformPath)
+	}
+
+	checkedTerraformBinaries[terraformPath] = v
+
+	return terraformPath, nil
+}
+
+// InstallTerraform downloads terraform terraformVersion for this platform from terraform_mirror,
+// verifies it against the release's SHA256SUMS and installs it into bin_dir, where it's
+// used instead of the terraform on PATH. It returns the path of the installed binary.
+func InstallTerraform(terraformVersion string) (string, error) {
+	v, err := version.NewVersion(terraformVersion)
+	if err != nil {
+		return "", fmt.Errorf("Invalid terraform version '%s': %s", terraformVersion, err)
+	}
+
+	binDir, err := binDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	err = checkTerraformVersion(v)
+	if err != nil {
+		return "", err
+	}
+
+	mirror := defaultTerraformMirror
+	if viper.IsSet("terraform_mirror") {
+		mirror = viper.GetString("terraform_mirror")
+	}
+	if !isURL(mirror) {
+		mirror, err = homedir.Expand(mirror)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Releases are laid out like on releases.hashicorp.com, e.g.
+	// 1.5.7/terraform_1.5.7_linux_amd64.zip next to 1.5.7/terraform_1.5.7_SHA256SUMS
+	releaseLocation := joinLocation(mirror, v.String())
+	archiveName := fmt.Sprintf("terraform_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+
+	fmt.Printf("Downloading terraform %s for %s_%s...\n", v, runtime.GOOS, runtime.GOARCH)
+
+	sums, err := fetch(joinLocation(releaseLocation, fmt.Sprintf("terraform_%s_SHA256SUMS", v)))
+	if err != nil {
+		return "", err
+	}
+	checksum, err := checksumFor(sums, archiveName)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := fetch(joinLocation(releaseLocation, archiveName))
+	if err != nil {
+		return "", err
+	}
+	err = verifyChecksum(archive, checksum)
+	if err != nil {
+		return "", fmt.Errorf("Terraform %s for %s_%s: %s", v, runtime.GOOS, runtime.GOARCH, err)
+	}
+
+	err = os.MkdirAll(binDir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	// Unpack next to the installed binary and move it into place, a failed install
+	// leaves the previous terraform working
+	tmpDir, err := ioutil.TempDir(binDir, ".terraform-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = unzip(archive, tmpDir)
+	if err != nil {
+		return "", err
+	}
+
+	terraformPath := filepath.Join(binDir, terraformExecutableName())
+	err = os.Rename(filepath.Join(tmpDir, terraformExecutableName()), terraformPath)
+	if err != nil {
+		return "", err
+	}
+	delete(checkedTerraformBinaries, terraformPath)
+
+	return terraformPath, nil
+}
+
+// checksumFor returns the checksum of fileName in the content of a SHA256SUMS file.
+func checksumFor(sums []byte, fileName string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == fileName {
+			return fields[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("There is no checksum for %s", fileName)
+}
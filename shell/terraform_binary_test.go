@@ -0,0 +1,173 @@
+package shell
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+)
+
+func TestTerraformFromPath(t *testing.T) {
+	_, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	terraformPath, err := Terraform()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(filepath.Dir(terraformPath)) != "bin" {
+		t.Errorf("Expected the terraform on PATH, got %s", terraformPath)
+	}
+}
+
+func TestTerraformPathSetting(t *testing.T) {
+	logPath, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	expected := filepath.Join(filepath.Dir(logPath), "terraform-1.0.11")
+	writeFakeTerraform(t, expected, "1.0.11", logPath)
+	viper.Set("terraform_path", expected)
+
+	terraformPath, err := Terraform()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terraformPath != expected {
+		t.Errorf("Expected %s, got %s", expected, terraformPath)
+	}
+
+	viper.Set("terraform_path", filepath.Join(filepath.Dir(logPath), "missing"))
+	_, err = Terraform()
+	if err == nil || !strings.Contains(err.Error(), "terraform_path") {
+		t.Errorf("Expected a missing terraform_path to fail, got %v", err)
+	}
+}
+
+func TestTerraformUnsupportedVersion(t *testing.T) {
+	logPath, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	oldTerraform := filepath.Join(filepath.Dir(logPath), "terraform-0.12.31")
+	writeFakeTerraform(t, oldTerraform, "0.12.31", logPath)
+	viper.Set("terraform_path", oldTerraform)
+
+	_, err := Terraform()
+	if err == nil || !strings.Contains(err.Error(), "Terraform 0.12.31") {
+		t.Errorf("Expected terraform 0.12.31 to be refused, got %v", err)
+	}
+
+	// Nothing else runs with an unsupported terraform
+	currentState, _ := state.New("dev-manager", []byte(`{}`))
+	_, cleanup, err := testRunner.prepareWorkingDirectory(currentState)
+	cleanup()
+	if err == nil {
+		t.Error("Expected preparing the working directory to fail")
+	}
+	if log, _ := ioutil.ReadFile(logPath); len(log) > 0 {
+		t.Errorf("Expected terraform not to run, got:\n%s", log)
+	}
+}
+
+// writeTestTerraformRelease writes a terraform release for this platform to mirror, laid
+// out like on releases.hashicorp.com, with the given checksum or the archive's if empty.
+func writeTestTerraformRelease(t *testing.T, mirror, terraformVersion, checksum string) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"terraform":   "#!/bin/sh\necho \"Terraform v" + terraformVersion + "\"\n",
+		"LICENSE.txt": "license",
+	} {
+		file, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+	}
+	err := zipWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checksum == "" {
+		sum := sha256.Sum256(buf.Bytes())
+		checksum = hex.EncodeToString(sum[:])
+	}
+
+	releaseDir := filepath.Join(mirror, terraformVersion)
+	os.MkdirAll(releaseDir, 0700)
+	archiveName := fmt.Sprintf("terraform_%s_%s_%s.zip", terraformVersion, runtime.GOOS, runtime.GOARCH)
+	sums := fmt.Sprintf("%s  terraform_%s_other_arch.zip\n%s  %s\n", strings.Repeat("0", 64), terraformVersion, checksum, archiveName)
+	ioutil.WriteFile(filepath.Join(releaseDir, archiveName), buf.Bytes(), 0600)
+	ioutil.WriteFile(filepath.Join(releaseDir, fmt.Sprintf("terraform_%s_SHA256SUMS", terraformVersion)), []byte(sums), 0600)
+}
+
+func TestInstallTerraform(t *testing.T) {
+	logPath, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	mirror := filepath.Join(filepath.Dir(logPath), "releases")
+	writeTestTerraformRelease(t, mirror, "1.3.9", "")
+	viper.Set("terraform_mirror", mirror)
+
+	installedPath, err := InstallTerraform("1.3.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The installed terraform is used instead of the one on PATH
+	terraformPath, err := Terraform()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terraformPath != installedPath {
+		t.Errorf("Expected the installed terraform %s, got %s", installedPath, terraformPath)
+	}
+
+	v, err := TerraformVersion(terraformPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "1.3.9" {
+		t.Errorf("Expected terraform 1.3.9, got %s", v)
+	}
+}
+
+func TestInstallTerraformChecksumMismatch(t *testing.T) {
+	logPath, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	mirror := filepath.Join(filepath.Dir(logPath), "releases")
+	writeTestTerraformRelease(t, mirror, "1.3.9", strings.Repeat("0", 64))
+	viper.Set("terraform_mirror", mirror)
+
+	_, err := InstallTerraform("1.3.9")
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Expected a checksum error, got %v", err)
+	}
+
+	binDir, _ := binDirectory()
+	if _, err := os.Stat(filepath.Join(binDir, terraformExecutableName())); !os.IsNotExist(err) {
+		t.Error("Expected terraform not to be installed")
+	}
+}
+
+func TestInstallTerraformUnsupportedVersion(t *testing.T) {
+	_, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	_, err := InstallTerraform("0.12.31")
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Expected terraform 0.12.31 to be refused, got %v", err)
+	}
+}
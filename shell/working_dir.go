@@ -0,0 +1,210 @@
+package shell
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/joyent/triton-kubernetes/state"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// Directory terraform working directories and plugins are cached in, unless cache_dir is set
+const defaultCacheDirectory = "~/.triton-kubernetes/cache"
+
+// File in a cluster manager's cache directory recording what terraform init ran for
+const initStampFileName = ".triton-kubernetes-init"
+
+// File in a cluster manager's cache directory runs lock while they use the cached data
+const lockFileName = ".triton-kubernetes-lock"
+
+// Lock file terraform 0.14 and later writes to the working directory during init
+const dependencyLockFileName = ".terraform.lock.hcl"
+
+// cacheDirectory returns the directory terraform working directories and plugins are cached in.
+func cacheDirectory() (string, error) {
+	cacheDir := defaultCacheDirectory
+	if viper.IsSet("cache_dir") {
+		cacheDir = viper.GetString("cache_dir")
+	}
+
+	return homedir.Expand(cacheDir)
+}
+
+// workingDirectory returns the directory the named cluster manager's terraform data, e.g.
+// its modules and backend configuration, is cached in.
+func workingDirectory(name string) (string, error) {
+	cacheDir, err := cacheDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "managers", name), nil
+}
+
+// prepareWorkingDirectory writes the terraform config of currentState to a temporary working
+// directory and runs terraform init, unless it already ran for the same config and plugins.
+// Terraform's data directory is cached per cluster manager and plugins are shared between
+// cluster managers through terraform's plugin cache.
+//
+// Terraform's output and progress messages go to the runner's stdout.
+//
+// The cached data is locked until the returned func is called, which also removes the
+// working directory with the plaintext config and plan. It must be called once terraform
+// is done, also when an error is returned.
+func (runner terraformRunner) prepareWorkingDirectory(currentState state.State) (ShellOptions, func(), error) {
+	cleanup := func() {}
+
+	// Nothing runs unless terraform is a supported version
+	terraformPath, err := Terraform()
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	cacheDir, err := cacheDirectory()
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	managerDir, err := workingDirectory(currentState.Name)
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	pluginCacheDir := filepath.Join(cacheDir, "plugins")
+	for _, dir := range []string{managerDir, pluginCacheDir} {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return ShellOptions{}, cleanup, err
+		}
+	}
+
+	// Concurrent runs for the same cluster manager, e.g. get while apply runs, wait for
+	// each other instead of initializing the cached data underneath each other
+	unlock, err := lockFile(filepath.Join(managerDir, lockFileName))
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+	cleanup = unlock
+
+	workingDir, err := ioutil.TempDir("", "triton-kubernetes-")
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+	cleanup = func() {
+		os.RemoveAll(workingDir)
+		unlock()
+	}
+
+	// Save the terraform config to the working directory
+	err = writeTerraformConfig(workingDir, currentState)
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	shellOptions := ShellOptions{
+		WorkingDir: workingDir,
+		Terraform:  terraformPath,
+		Env: append([]string{
+			"TF_PLUGIN_CACHE_DIR=" + pluginCacheDir,
+			"TF_DATA_DIR=" + filepath.Join(managerDir, ".terraform"),
+		}, runner.backendEnv...),
+		Stdout: runner.stdout,
+	}
+
+	manifest, err := loadPluginManifest()
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	// Install third party providers
+	err = installThirdPartyProviders(workingDir, cacheDir, manifest, runner.stdout)
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	stamp, err := initStamp(workingDir, manifest)
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	stampPath := filepath.Join(managerDir, initStampFileName)
+	previousStamp, err := ioutil.ReadFile(stampPath)
+	if err == nil && string(previousStamp) == stamp {
+		// Terraform 0.14 and later checks the providers against the lock file init wrote
+		err = copyFileIfExists(filepath.Join(managerDir, dependencyLockFileName), filepath.Join(workingDir, dependencyLockFileName))
+		if err != nil {
+			return ShellOptions{}, cleanup, err
+		}
+
+		return shellOptions, cleanup, nil
+	}
+
+	// Forget the previous stamp first, an interrupted init must run again
+	os.Remove(stampPath)
+
+	// Run terraform init, the backend is configured as if the directory was new
+	err = runTerraform(&shellOptions, "init", "-input=false", "-reconfigure")
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	err = copyFileIfExists(filepath.Join(workingDir, dependencyLockFileName), filepath.Join(managerDir, dependencyLockFileName))
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	err = ioutil.WriteFile(stampPath, []byte(stamp), 0600)
+	if err != nil {
+		return ShellOptions{}, cleanup, err
+	}
+
+	return shellOptions, cleanup, nil
+}
+
+// copyFileIfExists copies the file at src to dst, nothing happens if there is no file at src.
+func copyFileIfExists(src, dst string) error {
+	content, err := ioutil.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dst, content, 0600)
+}
+
+// initStamp identifies what terraform init has to run for: the terraform config in
+// workingDir and the third party plugins of the manifest.
+func initStamp(workingDir string, manifest PluginManifest) (string, error) {
+	config, err := ioutil.ReadFile(filepath.Join(workingDir, "main.tf.json"))
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write(config)
+	for _, provider := range manifest.Providers {
+		fmt.Fprintf(hash, "\n%s %s %s", provider.Name, provider.Version, provider.SHA256[fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH)])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// RemoveWorkingDirectory removes the cached terraform data of the named cluster manager,
+// e.g. once it has been destroyed.
+func (runner terraformRunner) RemoveWorkingDirectory(name string) error {
+	workingDir, err := workingDirectory(name)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(workingDir)
+}
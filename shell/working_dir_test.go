@@ -0,0 +1,194 @@
+package shell
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+)
+
+// Runner for a backend without terraform environment variables
+var testRunner = terraformRunner{stdout: os.Stdout}
+
+// writeFakeTerraform writes a terraform to path that prints terraformVersion and logs the
+// arguments of any other command to logPath. init writes a dependency lock file.
+func writeFakeTerraform(t *testing.T, path, terraformVersion, logPath string) {
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = version ]; then echo \"Terraform v" + terraformVersion + "\"; exit 0; fi\n" +
+		"if [ \"$1\" = init ]; then echo locked > .terraform.lock.hcl; fi\n" +
+		"echo \"$@\" >> " + logPath + "\n"
+	err := ioutil.WriteFile(path, []byte(script), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setupFakeTerraform puts a terraform on PATH that only logs its arguments, and points the
+// cache and the plugin mirror at a temporary directory.
+func setupFakeTerraform(t *testing.T) (string, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake terraform is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "triton-kubernetes-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binDir := filepath.Join(dir, "bin")
+	logPath := filepath.Join(dir, "terraform.log")
+	os.MkdirAll(binDir, 0700)
+	writeFakeTerraform(t, filepath.Join(binDir, "terraform"), PinnedTerraformVersion, logPath)
+
+	cacheDir := filepath.Join(dir, "cache")
+	mirror := filepath.Join(dir, "mirror")
+	writeTestMirror(t, mirror)
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", binDir+string(os.PathListSeparator)+oldPath)
+	viper.Reset()
+	viper.Set("cache_dir", cacheDir)
+	viper.Set("bin_dir", filepath.Join(dir, "installed"))
+	viper.Set("plugin_mirror", mirror)
+
+	return logPath, func() {
+		os.Setenv("PATH", oldPath)
+		viper.Reset()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPrepareWorkingDirectorySkipsInit(t *testing.T) {
+	logPath, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	currentState, _ := state.New("dev-manager", []byte(`{"module":{"cluster-manager":{"name":"dev-manager"}}}`))
+
+	prepare := func() ShellOptions {
+		shellOptions, cleanup, err := testRunner.prepareWorkingDirectory(currentState)
+		defer cleanup()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(filepath.Join(shellOptions.WorkingDir, "terraform.d", "plugins", "registry.terraform.io", "example", "fake", "1.0.0", runtime.GOOS+"_"+runtime.GOARCH, "terraform-provider-fake_v1.0.0")); err != nil {
+			t.Error("Expected the third party plugins to be installed", err)
+		}
+		// Runs that skip init still get the providers init locked
+		if _, err := os.Stat(filepath.Join(shellOptions.WorkingDir, ".terraform.lock.hcl")); err != nil {
+			t.Error("Expected the dependency lock file in the working directory", err)
+		}
+		return shellOptions
+	}
+
+	shellOptions := prepare()
+	prepare()
+
+	// The plaintext config doesn't outlive the run
+	if _, err := os.Stat(shellOptions.WorkingDir); !os.IsNotExist(err) {
+		t.Error("Expected the working directory to be removed")
+	}
+
+	// Terraform's data is cached outside of the working directory
+	managerDir, _ := workingDirectory("dev-manager")
+	expectedEnv := "TF_DATA_DIR=" + filepath.Join(managerDir, ".terraform")
+	if len(shellOptions.Env) != 2 || shellOptions.Env[1] != expectedEnv {
+		t.Errorf("Expected %s, got %v", expectedEnv, shellOptions.Env)
+	}
+
+	// A changed config needs init again
+	currentState.Set("module.cluster-manager.name", "prod-manager")
+	prepare()
+
+	log, _ := ioutil.ReadFile(logPath)
+	expected := "init -input=false -reconfigure\ninit -input=false -reconfigure\n"
+	if string(log) != expected {
+		t.Errorf("Expected terraform to be initialized twice, got:\n%s", log)
+	}
+}
+
+func TestPrepareWorkingDirectoryWaitsForOtherRuns(t *testing.T) {
+	_, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	currentState, _ := state.New("dev-manager", []byte(`{}`))
+	_, cleanup, err := testRunner.prepareWorkingDirectory(currentState)
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	prepared := make(chan error)
+	go func() {
+		_, otherCleanup, err := testRunner.prepareWorkingDirectory(currentState)
+		otherCleanup()
+		prepared <- err
+	}()
+
+	select {
+	case <-prepared:
+		t.Fatal("Expected the second run to wait for the first one")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cleanup()
+	select {
+	case err := <-prepared:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the second run to continue once the first one is done")
+	}
+}
+
+func TestRemoveWorkingDirectory(t *testing.T) {
+	_, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	currentState, _ := state.New("dev-manager", []byte(`{}`))
+	_, cleanup, err := testRunner.prepareWorkingDirectory(currentState)
+	cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testRunner.RemoveWorkingDirectory("dev-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	managerDir, _ := workingDirectory("dev-manager")
+	if _, err := os.Stat(managerDir); !os.IsNotExist(err) {
+		t.Error("Expected the cached terraform data to be removed")
+	}
+}
+
+func TestPrepareWorkingDirectoryBackendEnv(t *testing.T) {
+	_, teardown := setupFakeTerraform(t)
+	defer teardown()
+
+	runner := terraformRunner{stdout: os.Stdout, backendEnv: []string{"AWS_ACCESS_KEY_ID=test-key"}}
+
+	currentState, _ := state.New("dev-manager", []byte(`{}`))
+	shellOptions, cleanup, err := runner.prepareWorkingDirectory(currentState)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, env := range shellOptions.Env {
+		if env == "AWS_ACCESS_KEY_ID=test-key" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected terraform to run with the backend's environment, got %v", shellOptions.Env)
+	}
+}
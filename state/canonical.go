@@ -0,0 +1,36 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// canonicalJSON serializes data the same way every time, whatever order its keys were set
+// in and whether parts of it are structs or maps: object keys are sorted, indentation uses
+// tabs, characters like < and & are not escaped and the output ends with a newline.
+func canonicalJSON(data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Turn structs into maps, which encoding/json writes with sorted keys
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var normalized interface{}
+	err = decoder.Decode(&normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	err = encoder.Encode(normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"testing"
+)
+
+func TestBytesCanonical(t *testing.T) {
+	type backendConfig struct {
+		Path    string `json:"path"`
+		Account string `json:"account"`
+	}
+
+	first, _ := New("dev-manager", []byte(`{"module":{"b":{"count":3,"url":"http://a?b=1&c=2"},"a":{"size":1.5}}}`))
+	err := first.SetTerraformBackendConfig("terraform.backend.manta", backendConfig{Path: "/path", Account: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := New("dev-manager", []byte(`{"terraform":{"backend":{"manta":{"path":"/path","account":"dev"}}},"module":{"a":{"size":1.5},"b":{"url":"http://a?b=1&c=2","count":3}}}`))
+
+	expected := `{
+	"module": {
+		"a": {
+			"size": 1.5
+		},
+		"b": {
+			"count": 3,
+			"url": "http://a?b=1&c=2"
+		}
+	},
+	"terraform": {
+		"backend": {
+			"manta": {
+				"account": "dev",
+				"path": "/path"
+			}
+		}
+	}
+}
+`
+
+	if string(first.Bytes()) != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", first.Bytes(), expected)
+	}
+	if string(second.Bytes()) != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", second.Bytes(), expected)
+	}
+}
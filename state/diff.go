@@ -0,0 +1,53 @@
+package state
+
+import (
+	"sort"
+)
+
+// ModuleChanges compares the modules of two states and returns the keys of the
+// modules that were added, removed or changed going from `from` to `to`.
+func ModuleChanges(from, to State) (added, removed, changed []string) {
+	fromModules := from.modules()
+	toModules := to.modules()
+
+	for key, toModule := range toModules {
+		fromModule, ok := fromModules[key]
+		if !ok {
+			added = append(added, key)
+		} else if fromModule != toModule {
+			changed = append(changed, key)
+		}
+	}
+
+	for key := range fromModules {
+		if _, ok := toModules[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+
+	return
+}
+
+// Returns map of module key to the module's serialised configuration
+func (state *State) modules() map[string]string {
+	result := map[string]string{}
+
+	children, err := state.configJSON.S("module").ChildrenMap()
+	if err != nil {
+		return result
+	}
+
+	for key, child := range children {
+		content, err := canonicalJSON(child.Data())
+		if err != nil {
+			content = child.Bytes()
+		}
+		result[key] = string(content)
+	}
+
+	return result
+}
@@ -0,0 +1,236 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Module roles recorded in the metadata of each module block.
+const (
+	RoleManager = "manager"
+	RoleCluster = "cluster"
+	RoleNode    = "node"
+	RoleBackup  = "backup"
+)
+
+// Module blocks keep their metadata in the "//" field, which terraform ignores as a comment.
+const metadataField = "//"
+
+// ModuleMetadata describes what a module block belongs to, so lookups don't
+// depend on parsing module keys.
+type ModuleMetadata struct {
+	Role     string `json:"role,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Cluster  string `json:"cluster,omitempty"`
+}
+
+// ClusterKey returns the module key of a cluster, `cluster_{provider}_{clusterName}`.
+func ClusterKey(provider, name string) string {
+	return fmt.Sprintf("cluster_%s_%s", provider, escapeKeyPart(name))
+}
+
+// NodeKey returns the module key of a node, `node_{provider}_{clusterName}__{nodeName}`.
+// Names are escaped so they never contain `__`, which makes the key unambiguous.
+func NodeKey(provider, clusterName, name string) string {
+	return fmt.Sprintf("node_%s_%s__%s", provider, escapeKeyPart(clusterName), escapeKeyPart(name))
+}
+
+// BackupKey returns the module key of a cluster's backup, `backup_{clusterKey}`.
+func BackupKey(clusterKey string) string {
+	return fmt.Sprintf("backup_%s", clusterKey)
+}
+
+// escapeKeyPart replaces every byte other than letters, digits and `-` with `_{hex}`,
+// e.g. `dev_cluster` becomes `dev_5fcluster`.
+func escapeKeyPart(name string) string {
+	var result strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' {
+			result.WriteByte(c)
+		} else {
+			fmt.Fprintf(&result, "_%02x", c)
+		}
+	}
+
+	return result.String()
+}
+
+func unescapeKeyPart(part string) string {
+	var result strings.Builder
+	for i := 0; i < len(part); i++ {
+		var c byte
+		if part[i] == '_' && i+2 < len(part) {
+			_, err := fmt.Sscanf(part[i+1:i+3], "%02x", &c)
+			if err == nil {
+				result.WriteByte(c)
+				i += 2
+				continue
+			}
+		}
+		result.WriteByte(part[i])
+	}
+
+	return result.String()
+}
+
+// parseClusterKey returns the provider and cluster name of a `cluster_{provider}_{clusterName}` key.
+// Providers never contain `_`, so everything after the provider is the escaped cluster name.
+func parseClusterKey(clusterKey string) (provider, name string, err error) {
+	parts := strings.SplitN(clusterKey, "_", 3)
+	if len(parts) < 3 || parts[0] != "cluster" {
+		err = fmt.Errorf("Could not get cluster key parts, cluster does not follow format `cluster_{provider}_{clusterName}` '%s'", clusterKey)
+		return
+	}
+
+	return parts[1], unescapeKeyPart(parts[2]), nil
+}
+
+// metadata returns the metadata of the module with the given key, and false if it has none.
+func (state *State) metadata(key string) (ModuleMetadata, bool) {
+	module := state.configJSON.Search("module", key, metadataField)
+	data, ok := module.Data().(map[string]interface{})
+	if !ok {
+		return ModuleMetadata{}, false
+	}
+
+	metadata := ModuleMetadata{}
+	metadata.Role, _ = data["role"].(string)
+	metadata.Provider, _ = data["provider"].(string)
+	metadata.Cluster, _ = data["cluster"].(string)
+
+	return metadata, metadata.Role != ""
+}
+
+func (state *State) setMetadata(key string, metadata ModuleMetadata) error {
+	value := map[string]interface{}{"role": metadata.Role}
+	if metadata.Provider != "" {
+		value["provider"] = metadata.Provider
+	}
+	if metadata.Cluster != "" {
+		value["cluster"] = metadata.Cluster
+	}
+
+	_, err := state.configJSON.Set(value, "module", key, metadataField)
+	return err
+}
+
+// ClusterProvider returns the provider of the cluster with the given key, e.g. triton.
+func (state *State) ClusterProvider(clusterKey string) (string, error) {
+	provider, _, err := state.clusterOf(clusterKey)
+	return provider, err
+}
+
+// clusterOf returns the provider and name of the cluster with the given key, from its
+// metadata, or from the key if the cluster isn't part of the state.
+func (state *State) clusterOf(clusterKey string) (provider, name string, err error) {
+	if metadata, ok := state.metadata(clusterKey); ok && metadata.Role == RoleCluster {
+		return metadata.Provider, metadata.Cluster, nil
+	}
+
+	return parseClusterKey(clusterKey)
+}
+
+// checkModuleKey returns an error if key is already used by a module with other metadata,
+// so that a new module can never overwrite another cluster's or node's module.
+func (state *State) checkModuleKey(key string, metadata ModuleMetadata, name string) error {
+	if !state.configJSON.Exists("module", key) {
+		return nil
+	}
+
+	existing, ok := state.metadata(key)
+	if !ok {
+		return nil
+	}
+
+	existingName := ""
+	if existing.Role == RoleNode {
+		existingName, _ = state.configJSON.Search("module", key, "hostname").Data().(string)
+	}
+	if existing != metadata || (metadata.Role == RoleNode && existingName != name) {
+		return fmt.Errorf("Module '%s' already belongs to another %s", key, existing.Role)
+	}
+
+	return nil
+}
+
+var clusterReferenceRegexp = regexp.MustCompile(`\$\{module\.(cluster_[^.}]+)\.`)
+
+// migrateModuleMetadata adds metadata to the module blocks of states written before
+// modules had metadata, using the module keys and the fields of the blocks.
+func (state *State) migrateModuleMetadata() {
+	children, err := state.configJSON.S("module").ChildrenMap()
+	if err != nil {
+		return
+	}
+
+	// Clusters first, nodes and backups use their metadata
+	for key, child := range children {
+		if _, ok := state.metadata(key); ok {
+			continue
+		}
+
+		switch {
+		case key == "cluster-manager":
+			state.setMetadata(key, ModuleMetadata{Role: RoleManager})
+		case strings.HasPrefix(key, "cluster_"):
+			metadata := ModuleMetadata{Role: RoleCluster}
+			if parts := strings.SplitN(key, "_", 3); len(parts) == 3 {
+				metadata.Provider = parts[1]
+			}
+			metadata.Cluster, _ = child.Path("name").Data().(string)
+			state.setMetadata(key, metadata)
+		}
+	}
+
+	for key, child := range children {
+		if _, ok := state.metadata(key); ok {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(key, "node_"):
+			clusterKey := legacyNodeClusterKey(key, child.Data())
+			if clusterKey == "" {
+				continue
+			}
+			provider, name, err := state.clusterOf(clusterKey)
+			if err != nil {
+				continue
+			}
+			state.setMetadata(key, ModuleMetadata{Role: RoleNode, Provider: provider, Cluster: name})
+		case strings.HasPrefix(key, "backup_"):
+			provider, name, err := state.clusterOf(strings.TrimPrefix(key, "backup_"))
+			if err != nil {
+				continue
+			}
+			state.setMetadata(key, ModuleMetadata{Role: RoleBackup, Provider: provider, Cluster: name})
+		}
+	}
+}
+
+// legacyNodeClusterKey returns the key of the cluster a node without metadata belongs to.
+// Nodes reference their cluster's module, older keys `node_{provider}_{clusterName}_{nodeName}`
+// are only split when there is no reference.
+func legacyNodeClusterKey(nodeKey string, data interface{}) string {
+	fields, _ := data.(map[string]interface{})
+
+	token, _ := fields["rancher_cluster_registration_token"].(string)
+	if match := clusterReferenceRegexp.FindStringSubmatch(token); match != nil {
+		return match[1]
+	}
+
+	rest := strings.TrimPrefix(nodeKey, "node_")
+	hostname, _ := fields["hostname"].(string)
+	if hostname != "" && strings.HasSuffix(rest, "_"+hostname) {
+		return "cluster_" + strings.TrimSuffix(rest, "_"+hostname)
+	}
+
+	separator := strings.LastIndex(rest, "_")
+	if separator <= 0 {
+		return ""
+	}
+
+	return "cluster_" + rest[:separator]
+}
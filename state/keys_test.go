@@ -0,0 +1,203 @@
+package state
+
+import (
+	"testing"
+)
+
+func TestClusterKeyRoundTrip(t *testing.T) {
+	for _, name := range []string{"dev", "dev-cluster", "dev_cluster", "_dev_", "dev.example.com", "dev_5fcluster"} {
+		key := ClusterKey("triton", name)
+
+		provider, parsedName, err := parseClusterKey(key)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if provider != "triton" || parsedName != name {
+			t.Errorf("parseClusterKey(%s), got: %s %s, want: triton %s", key, provider, parsedName, name)
+		}
+	}
+}
+
+func TestNodeKeysDontCollide(t *testing.T) {
+	pairs := [][2]string{
+		{"a", "b_c"},
+		{"a_b", "c"},
+		{"a_", "5f"},
+		{"a", "5f_"},
+		{"a", "_b"},
+	}
+
+	seen := map[string][2]string{}
+	for _, pair := range pairs {
+		key := NodeKey("triton", pair[0], pair[1])
+		if other, ok := seen[key]; ok {
+			t.Errorf("%v and %v both have key %s", other, pair, key)
+		}
+		seen[key] = pair
+	}
+}
+
+func TestNodesWithUnderscoreNames(t *testing.T) {
+	stateObj, err := New("dev-manager", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cluster := range []string{"a", "a_b"} {
+		err = stateObj.SetCluster("triton", cluster, Cluster{Name: cluster})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both nodes would have been `node_triton_a_b_c`
+	err = stateObj.SetNode(ClusterKey("triton", "a"), "b_c", Node{Hostname: "b_c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stateObj.SetNode(ClusterKey("triton", "a_b"), "c", Node{Hostname: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := stateObj.Nodes(ClusterKey("triton", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes["b_c"] == "" {
+		t.Errorf("nodes of cluster 'a', got: %v", nodes)
+	}
+
+	nodes, err = stateObj.Nodes(ClusterKey("triton", "a_b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes["c"] == "" {
+		t.Errorf("nodes of cluster 'a_b', got: %v", nodes)
+	}
+}
+
+// A cluster whose name is a prefix of another cluster's name
+func TestNodesWithPrefixClusterNames(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(`{
+		"module": {
+			"cluster_triton_dev": {"name": "dev"},
+			"cluster_triton_dev_prod": {"name": "dev_prod"},
+			"node_triton_dev_prod_worker-1": {
+				"hostname": "worker-1",
+				"rancher_cluster_registration_token": "${module.cluster_triton_dev_prod.rancher_cluster_registration_token}"
+			},
+			"node_triton_dev_worker-2": {
+				"hostname": "worker-2",
+				"rancher_cluster_registration_token": "${module.cluster_triton_dev.rancher_cluster_registration_token}"
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := stateObj.Nodes("cluster_triton_dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes["worker-2"] != "node_triton_dev_worker-2" {
+		t.Errorf("nodes of cluster 'dev', got: %v", nodes)
+	}
+
+	nodes, err = stateObj.Nodes("cluster_triton_dev_prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes["worker-1"] != "node_triton_dev_prod_worker-1" {
+		t.Errorf("nodes of cluster 'dev_prod', got: %v", nodes)
+	}
+}
+
+func TestMigrateModuleMetadata(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(`{
+		"module": {
+			"cluster-manager": {"name": "dev-manager"},
+			"cluster_aws_my_cluster": {"name": "my_cluster"},
+			"node_aws_my_cluster_my_node": {"hostname": "my_node"},
+			"backup_cluster_aws_my_cluster": {"aws_s3_bucket": "backups"}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]ModuleMetadata{
+		"cluster-manager":               {Role: RoleManager},
+		"cluster_aws_my_cluster":        {Role: RoleCluster, Provider: "aws", Cluster: "my_cluster"},
+		"node_aws_my_cluster_my_node":   {Role: RoleNode, Provider: "aws", Cluster: "my_cluster"},
+		"backup_cluster_aws_my_cluster": {Role: RoleBackup, Provider: "aws", Cluster: "my_cluster"},
+	}
+	for key, want := range expected {
+		got, ok := stateObj.metadata(key)
+		if !ok || got != want {
+			t.Errorf("metadata of %s, got: %+v, want: %+v", key, got, want)
+		}
+	}
+
+	// Existing modules keep their keys
+	err = stateObj.SetCluster("aws", "my_cluster", Cluster{Name: "my_cluster"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters, err := stateObj.Clusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters["my_cluster"] != "cluster_aws_my_cluster" {
+		t.Errorf("clusters, got: %v", clusters)
+	}
+}
+
+func TestSetNodeKeyTaken(t *testing.T) {
+	key := NodeKey("triton", "dev", "worker-1")
+	stateObj, err := Load("dev-manager", []byte(`{
+		"module": {
+			"cluster_triton_dev": {"name": "dev"},
+			"`+key+`": {
+				"//": {"role": "node", "provider": "triton", "cluster": "other"},
+				"hostname": "worker-1"
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stateObj.SetNode("cluster_triton_dev", "worker-1", Node{Hostname: "worker-1"})
+	if err == nil {
+		t.Error("expected an error when the node key belongs to another cluster")
+	}
+}
+
+func TestClusterProvider(t *testing.T) {
+	// The key was renamed, only the metadata knows the provider
+	stateObj, err := Load("dev-manager", []byte(`{
+		"module": {
+			"cluster_dev": {
+				"//": {"role": "cluster", "provider": "triton", "cluster": "dev"},
+				"name": "dev"
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := stateObj.ClusterProvider("cluster_dev")
+	if err != nil || provider != "triton" {
+		t.Errorf("provider, got: %s, %v, want: triton", provider, err)
+	}
+
+	// Clusters that aren't part of the state fall back to their key
+	provider, err = stateObj.ClusterProvider("cluster_aws_prod")
+	if err != nil || provider != "aws" {
+		t.Errorf("provider, got: %s, %v, want: aws", provider, err)
+	}
+}
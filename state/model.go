@@ -0,0 +1,350 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The types below are typed views of the terraform module blocks in a state.
+// Fields that aren't part of a type (e.g. machine packages) are kept in Fields,
+// so decoding a module block and encoding it again gives back the same JSON.
+//
+// Modules are read with Manager, Cluster, Node and ClusterBackup, written with SetManager,
+// SetCluster, SetNode and SetBackup, and removed by key with DeleteModule.
+
+// TritonCredentials are the fields used to access a Triton data center.
+type TritonCredentials struct {
+	TritonAccount string `json:"triton_account"`
+	TritonKeyPath string `json:"triton_key_path"`
+	TritonKeyID   string `json:"triton_key_id"`
+	TritonURL     string `json:"triton_url"`
+}
+
+// AWSCredentials are the fields used to access AWS.
+type AWSCredentials struct {
+	AWSAccessKey string `json:"aws_access_key"`
+	AWSSecretKey string `json:"aws_secret_key"`
+	AWSRegion    string `json:"aws_region"`
+}
+
+// GCPCredentials are the fields used to access GCP.
+type GCPCredentials struct {
+	GCPPathToCredentials string `json:"gcp_path_to_credentials"`
+	GCPProjectID         string `json:"gcp_project_id"`
+	GCPComputeRegion     string `json:"gcp_compute_region"`
+}
+
+// AzureCredentials are the fields used to access Azure.
+type AzureCredentials struct {
+	AzureSubscriptionID string `json:"azure_subscription_id"`
+	AzureClientID       string `json:"azure_client_id"`
+	AzureClientSecret   string `json:"azure_client_secret"`
+	AzureTenantID       string `json:"azure_tenant_id"`
+	AzureEnvironment    string `json:"azure_environment"`
+	AzureLocation       string `json:"azure_location"`
+}
+
+// VSphereCredentials are the fields used to access a vSphere server.
+type VSphereCredentials struct {
+	VSphereUser     string `json:"vsphere_user"`
+	VSpherePassword string `json:"vsphere_password"`
+	VSphereServer   string `json:"vsphere_server"`
+}
+
+// BareMetalHost are the fields used to reach an existing machine over ssh.
+type BareMetalHost struct {
+	Host        string `json:"host"`
+	BastionHost string `json:"bastion_host"`
+	SSHUser     string `json:"ssh_user"`
+	KeyPath     string `json:"key_path"`
+}
+
+// Manager is the `module.cluster-manager` block.
+type Manager struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+
+	DockerEngineInstallURL string `json:"docker_engine_install_url"`
+
+	RancherAdminPassword    string `json:"rancher_admin_password"`
+	RancherServerImage      string `json:"rancher_server_image"`
+	RancherAgentImage       string `json:"rancher_agent_image"`
+	RancherRegistry         string `json:"rancher_registry"`
+	RancherRegistryUsername string `json:"rancher_registry_username"`
+	RancherRegistryPassword string `json:"rancher_registry_password"`
+
+	TritonCredentials
+	AWSCredentials
+	GCPCredentials
+	AzureCredentials
+	BareMetalHost
+
+	Metadata ModuleMetadata `json:"//"`
+
+	// All fields of the module block, including the ones not covered above
+	Fields map[string]interface{} `json:"-"`
+}
+
+// Cluster is a `module.cluster_{provider}_{clusterName}` block.
+type Cluster struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+
+	DockerEngineInstallURL string `json:"docker_engine_install_url"`
+
+	RancherAPIURL    string `json:"rancher_api_url"`
+	RancherAccessKey string `json:"rancher_access_key"`
+	RancherSecretKey string `json:"rancher_secret_key"`
+
+	KubernetesVersion         string `json:"k8s_version"`
+	KubernetesNetworkProvider string `json:"k8s_network_provider"`
+
+	RancherRegistry         string `json:"rancher_registry"`
+	RancherRegistryUsername string `json:"rancher_registry_username"`
+	RancherRegistryPassword string `json:"rancher_registry_password"`
+
+	KubernetesRegistry         string `json:"k8s_registry"`
+	KubernetesRegistryUsername string `json:"k8s_registry_username"`
+	KubernetesRegistryPassword string `json:"k8s_registry_password"`
+
+	// Only set for clusters registered into Rancher outside of triton-kubernetes
+	RancherClusterID string `json:"rancher_cluster_id"`
+
+	TritonCredentials
+	AWSCredentials
+	GCPCredentials
+	AzureCredentials
+	VSphereCredentials
+
+	Metadata ModuleMetadata `json:"//"`
+
+	// All fields of the module block, including the ones not covered above
+	Fields map[string]interface{} `json:"-"`
+}
+
+// Node is a `module.node_{provider}_{clusterName}__{nodeName}` block, see NodeKey.
+type Node struct {
+	Source   string `json:"source"`
+	Hostname string `json:"hostname"`
+
+	DockerEngineInstallURL string `json:"docker_engine_install_url"`
+
+	RancherAPIURL                   string            `json:"rancher_api_url"`
+	RancherClusterRegistrationToken string            `json:"rancher_cluster_registration_token"`
+	RancherClusterCAChecksum        string            `json:"rancher_cluster_ca_checksum"`
+	RancherHostLabels               RancherHostLabels `json:"rancher_host_labels"`
+
+	RancherAgentImage string `json:"rancher_agent_image"`
+
+	RancherRegistry         string `json:"rancher_registry"`
+	RancherRegistryUsername string `json:"rancher_registry_username"`
+	RancherRegistryPassword string `json:"rancher_registry_password"`
+
+	TritonCredentials
+	AWSCredentials
+	GCPCredentials
+	AzureCredentials
+	VSphereCredentials
+	BareMetalHost
+
+	Metadata ModuleMetadata `json:"//"`
+
+	// All fields of the module block, including the ones not covered above
+	Fields map[string]interface{} `json:"-"`
+}
+
+// RancherHostLabels are the roles of a node.
+type RancherHostLabels struct {
+	Control string `json:"control,omitempty"`
+	Etcd    string `json:"etcd,omitempty"`
+	Worker  string `json:"worker,omitempty"`
+}
+
+// Backup is a `module.backup_{clusterKey}` block.
+type Backup struct {
+	Source string `json:"source"`
+
+	RancherAPIURL    string `json:"rancher_api_url"`
+	RancherAccessKey string `json:"rancher_access_key"`
+	RancherSecretKey string `json:"rancher_secret_key"`
+	RancherClusterID string `json:"rancher_cluster_id"`
+
+	TritonCredentials
+	MantaSubuser string `json:"manta_subuser"`
+
+	AWSCredentials
+	AWSS3Bucket string `json:"aws_s3_bucket"`
+
+	Metadata ModuleMetadata `json:"//"`
+
+	// All fields of the module block, including the ones not covered above
+	Fields map[string]interface{} `json:"-"`
+}
+
+// The unexported types have the same fields, without the custom (un)marshalling
+type manager Manager
+type cluster Cluster
+type node Node
+type backup Backup
+
+func (m Manager) MarshalJSON() ([]byte, error) {
+	return encodeModule(manager(m), m.Fields)
+}
+
+func (m *Manager) UnmarshalJSON(data []byte) error {
+	fields, err := decodeModule(data, (*manager)(m))
+	m.Fields = fields
+	return err
+}
+
+func (c Cluster) MarshalJSON() ([]byte, error) {
+	return encodeModule(cluster(c), c.Fields)
+}
+
+func (c *Cluster) UnmarshalJSON(data []byte) error {
+	fields, err := decodeModule(data, (*cluster)(c))
+	c.Fields = fields
+	return err
+}
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	return encodeModule(node(n), n.Fields)
+}
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	fields, err := decodeModule(data, (*node)(n))
+	n.Fields = fields
+	return err
+}
+
+func (b Backup) MarshalJSON() ([]byte, error) {
+	return encodeModule(backup(b), b.Fields)
+}
+
+func (b *Backup) UnmarshalJSON(data []byte) error {
+	fields, err := decodeModule(data, (*backup)(b))
+	b.Fields = fields
+	return err
+}
+
+// Manager returns the cluster manager module.
+func (state *State) Manager() (Manager, error) {
+	var result Manager
+	err := state.module("cluster-manager", &result)
+	return result, err
+}
+
+// Cluster returns the module of the cluster with the given key.
+func (state *State) Cluster(clusterKey string) (Cluster, error) {
+	var result Cluster
+	err := state.module(clusterKey, &result)
+	return result, err
+}
+
+// Node returns the module of the node with the given key.
+func (state *State) Node(nodeKey string) (Node, error) {
+	var result Node
+	err := state.module(nodeKey, &result)
+	return result, err
+}
+
+// ClusterBackup returns the backup module of the cluster with the given key.
+func (state *State) ClusterBackup(clusterKey string) (Backup, error) {
+	var result Backup
+	err := state.module(fmt.Sprintf("backup_%s", clusterKey), &result)
+	return result, err
+}
+
+func (state *State) module(key string, v interface{}) error {
+	module := state.configJSON.Search("module", key)
+	if module.Data() == nil {
+		return fmt.Errorf("Could not find module '%s'", key)
+	}
+
+	return json.Unmarshal(module.Bytes(), v)
+}
+
+// setModule stores obj, one of the types above, as `module.{key}`. obj is stored as the
+// JSON it encodes to, so the module can be read back like the modules of a parsed state.
+func (state *State) setModule(key string, obj interface{}) error {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var module map[string]interface{}
+	err = json.Unmarshal(raw, &module)
+	if err != nil {
+		return err
+	}
+
+	_, err = state.configJSON.Set(module, "module", key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// decodeModule decodes a module block into typed and returns all fields of the block.
+func decodeModule(data []byte, typed interface{}) (map[string]interface{}, error) {
+	err := json.Unmarshal(data, typed)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// encodeModule merges the fields of typed into fields. Typed fields with a zero value
+// are only written if the block already had them, so that fields a provider doesn't
+// use aren't added to its module.
+func encodeModule(typed interface{}, fields map[string]interface{}) ([]byte, error) {
+	raw, err := json.Marshal(typed)
+	if err != nil {
+		return nil, err
+	}
+
+	typedFields := map[string]interface{}{}
+	err = json.Unmarshal(raw, &typedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{}
+	for key, value := range fields {
+		result[key] = value
+	}
+	for key, value := range typedFields {
+		if _, ok := fields[key]; ok || !isZeroValue(value) {
+			result[key] = value
+		}
+	}
+
+	return json.Marshal(result)
+}
+
+func isZeroValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+
+	return false
+}
@@ -0,0 +1,237 @@
+package state
+
+import (
+	"bytes"
+	"testing"
+)
+
+const modelTestConfig = `{
+	"module": {
+		"cluster-manager": {
+			"source": "github.com/joyent/triton-kubernetes//terraform/modules/triton-rancher?ref=master",
+			"name": "dev-manager",
+			"rancher_admin_password": "admin",
+			"triton_account": "dev",
+			"triton_key_path": "~/.ssh/id_rsa",
+			"triton_key_id": "2c:53:bc:63:97:9e:79:3f:91:35:5e:f4:c8:23:88:37",
+			"triton_url": "https://us-east-1.api.joyent.com",
+			"triton_network_names": ["Joyent-SDC-Public"],
+			"master_triton_machine_package": "k4-highcpu-kvm-1.75G"
+		},
+		"cluster_triton_dev-cluster": {
+			"source": "github.com/joyent/triton-kubernetes//terraform/modules/triton-rancher-k8s?ref=master",
+			"name": "dev-cluster",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_access_key": "${module.cluster-manager.rancher_access_key}",
+			"rancher_secret_key": "${module.cluster-manager.rancher_secret_key}",
+			"k8s_version": "v1.10.3-rancher2-1",
+			"k8s_network_provider": "calico",
+			"rancher_registry": "",
+			"triton_account": "dev",
+			"triton_key_path": "~/.ssh/id_rsa",
+			"triton_key_id": "2c:53:bc:63:97:9e:79:3f:91:35:5e:f4:c8:23:88:37"
+		},
+		"node_triton_dev-cluster_dev-node-1": {
+			"source": "github.com/joyent/triton-kubernetes//terraform/modules/triton-rancher-k8s-host?ref=master",
+			"hostname": "dev-node-1",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_cluster_registration_token": "${module.cluster_triton_dev-cluster.rancher_cluster_registration_token}",
+			"rancher_cluster_ca_checksum": "${module.cluster_triton_dev-cluster.rancher_cluster_ca_checksum}",
+			"rancher_host_labels": {"worker": "true"},
+			"triton_account": "dev",
+			"triton_key_path": "~/.ssh/id_rsa",
+			"triton_key_id": "2c:53:bc:63:97:9e:79:3f:91:35:5e:f4:c8:23:88:37",
+			"triton_machine_package": "k4-highcpu-kvm-1.75G"
+		},
+		"backup_cluster_triton_dev-cluster": {
+			"source": "github.com/joyent/triton-kubernetes//terraform/modules/k8s-backup-s3?ref=master",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_access_key": "${module.cluster-manager.rancher_access_key}",
+			"rancher_secret_key": "${module.cluster-manager.rancher_secret_key}",
+			"rancher_cluster_id": "${module.cluster_triton_dev-cluster.rancher_cluster_id}",
+			"aws_access_key": "AKIA",
+			"aws_secret_key": "secret",
+			"aws_region": "us-east-1",
+			"aws_s3_bucket": "backups"
+		}
+	}
+}`
+
+func TestModelAccessors(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(modelTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := stateObj.Manager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.Name != "dev-manager" || manager.TritonURL != "https://us-east-1.api.joyent.com" {
+		t.Errorf("unexpected manager: %+v", manager)
+	}
+
+	cluster, err := stateObj.Cluster("cluster_triton_dev-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Name != "dev-cluster" || cluster.KubernetesNetworkProvider != "calico" || cluster.TritonAccount != "dev" {
+		t.Errorf("unexpected cluster: %+v", cluster)
+	}
+
+	node, err := stateObj.Node("node_triton_dev-cluster_dev-node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Hostname != "dev-node-1" || node.RancherHostLabels.Worker != "true" || node.Fields["triton_machine_package"] != "k4-highcpu-kvm-1.75G" {
+		t.Errorf("unexpected node: %+v", node)
+	}
+
+	backup, err := stateObj.ClusterBackup("cluster_triton_dev-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.AWSS3Bucket != "backups" || backup.AWSRegion != "us-east-1" {
+		t.Errorf("unexpected backup: %+v", backup)
+	}
+
+	_, err = stateObj.Cluster("cluster_triton_missing")
+	if err == nil {
+		t.Error("expected an error for a missing cluster")
+	}
+}
+
+func TestModelRoundTrip(t *testing.T) {
+	original, err := Load("dev-manager", []byte(modelTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := original.Manager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := original.Cluster("cluster_triton_dev-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := original.Node("node_triton_dev-cluster_dev-node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := original.ClusterBackup("cluster_triton_dev-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Modules are written back over the modules they were read from
+	before := original.Bytes()
+
+	err = original.SetManager(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = original.SetCluster("triton", "dev-cluster", cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = original.SetNode("cluster_triton_dev-cluster", "dev-node-1", node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = original.SetBackup("cluster_triton_dev-cluster", backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(before, original.Bytes()) {
+		t.Errorf("round trip changed the configuration, got:\n%s\nwant:\n%s", original.Bytes(), before)
+	}
+}
+
+func TestModelChangedFields(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(modelTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := stateObj.Cluster("cluster_triton_dev-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.TritonURL = "https://us-west-1.api.joyent.com"
+	cluster.KubernetesVersion = ""
+
+	err = stateObj.SetCluster("triton", "dev-cluster", cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value := stateObj.Get("module.cluster_triton_dev-cluster.triton_url"); value != "https://us-west-1.api.joyent.com" {
+		t.Errorf("triton_url, got: %s, want: %s", value, "https://us-west-1.api.joyent.com")
+	}
+	if !stateObj.configJSON.ExistsP("module.cluster_triton_dev-cluster.k8s_version") {
+		t.Error("expected k8s_version to be kept after being cleared")
+	}
+	// Fields of other providers aren't added
+	if stateObj.configJSON.ExistsP("module.cluster_triton_dev-cluster.aws_access_key") {
+		t.Error("expected aws_access_key not to be added to a triton cluster")
+	}
+}
+
+// Modules added from a struct are visible without re-parsing the state
+func TestClustersAddedFromStruct(t *testing.T) {
+	stateObj, err := New("dev-manager", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stateObj.SetCluster("triton", "dev-cluster", Cluster{Name: "dev-cluster"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stateObj.SetNode("cluster_triton_dev-cluster", "dev-node-1", Node{Hostname: "dev-node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := stateObj.Clusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clusters["dev-cluster"] != "cluster_triton_dev-cluster" {
+		t.Errorf("clusters, got: %v", clusters)
+	}
+
+	nodes, err := stateObj.Nodes("cluster_triton_dev-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes["dev-node-1"] != "node_triton_dev-cluster__dev-node-1" {
+		t.Errorf("nodes, got: %v", nodes)
+	}
+}
+
+func TestDeleteModule(t *testing.T) {
+	stateObj, err := New("dev-manager", []byte(modelTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stateObj.DeleteModule("node_triton_dev-cluster_dev-node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := stateObj.Nodes("cluster_triton_dev-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 0 {
+		t.Errorf("Wrong output, expected no nodes, received %v", nodes)
+	}
+	_, err = stateObj.Node("node_triton_dev-cluster_dev-node-1")
+	if err == nil {
+		t.Error("Expected the deleted node module not to be found")
+	}
+}
@@ -0,0 +1,130 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// Secret values can be given as references, the reference is stored in the configuration
+// and only resolved when the secret is needed:
+//
+//	env:NAME       value of the environment variable NAME, see AllowSecretReferences
+//	file:PATH      contents of the file at PATH, see AllowSecretReferences
+//	exec:COMMAND   output of COMMAND, run with `sh -c`, see AllowExecReferences
+//	literal:VALUE  VALUE as is, e.g. for a secret that starts with env:
+//
+// Trailing newlines are removed from file contents and command output.
+const (
+	envReferencePrefix     = "env:"
+	fileReferencePrefix    = "file:"
+	execReferencePrefix    = "exec:"
+	literalReferencePrefix = "literal:"
+)
+
+// ErrExecReferencesNotAllowed is returned for an exec: reference unless AllowExecReferences was called.
+var ErrExecReferencesNotAllowed = errors.New("exec: references only run with --allow-exec-secrets, which is only allowed with the local backend")
+
+// ErrSecretReferencesNotAllowed is returned for an env: or file: reference after
+// AllowSecretReferences(false) was called.
+var ErrSecretReferencesNotAllowed = errors.New("env: and file: references of a configuration in a remote backend are only resolved with --allow-secret-references")
+
+// Anyone who can write a configuration could run commands through exec: references on the
+// machine of everyone who reads it, so they only run for invocations that opted in
+var execReferencesAllowed = false
+
+// AllowExecReferences makes ResolveSecretReference run the commands of exec: references.
+func AllowExecReferences(allowed bool) {
+	execReferencesAllowed = allowed
+}
+
+// Anyone who can write a configuration in a shared backend could read environment variables
+// and files on the machine of everyone who reads it, e.g. into a node's user data, through env:
+// and file: references, so for remote backends they're only resolved for invocations that opted in
+var secretReferencesAllowed = true
+
+// AllowSecretReferences sets whether ResolveSecretReference resolves env: and file: references.
+func AllowSecretReferences(allowed bool) {
+	secretReferencesAllowed = allowed
+}
+
+// IsSecretReference reports whether value is a secret reference.
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, envReferencePrefix) ||
+		strings.HasPrefix(value, fileReferencePrefix) ||
+		strings.HasPrefix(value, execReferencePrefix)
+}
+
+// HasExecReferences reports whether any secret of the configuration is an exec: reference.
+func (state *State) HasExecReferences() bool {
+	found := false
+	state.walkSecrets(func(path, value string) (string, error) {
+		if strings.HasPrefix(value, execReferencePrefix) {
+			found = true
+		}
+		return value, nil
+	})
+
+	return found
+}
+
+// ResolveSecretReference returns the secret a reference points to, other values are returned as is.
+func ResolveSecretReference(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, envReferencePrefix):
+		if !secretReferencesAllowed {
+			return "", ErrSecretReferencesNotAllowed
+		}
+		name := strings.TrimPrefix(value, envReferencePrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("Environment variable '%s' is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, fileReferencePrefix):
+		if !secretReferencesAllowed {
+			return "", ErrSecretReferencesNotAllowed
+		}
+		expandedPath, err := homedir.Expand(strings.TrimPrefix(value, fileReferencePrefix))
+		if err != nil {
+			return "", err
+		}
+		content, err := ioutil.ReadFile(expandedPath)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case strings.HasPrefix(value, execReferencePrefix):
+		if !execReferencesAllowed {
+			return "", ErrExecReferencesNotAllowed
+		}
+		command := strings.TrimPrefix(value, execReferencePrefix)
+		if command == "" {
+			return "", errors.New("exec reference without a command")
+		}
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("Command '%s' failed: %s", command, err)
+		}
+		return strings.TrimRight(string(output), "\r\n"), nil
+	case strings.HasPrefix(value, literalReferencePrefix):
+		return strings.TrimPrefix(value, literalReferencePrefix), nil
+	}
+
+	return value, nil
+}
+
+// ResolveSecretReferences replaces all secret references with the secrets they point to.
+func (state *State) ResolveSecretReferences() error {
+	return state.walkSecrets(func(path, value string) (string, error) {
+		return ResolveSecretReference(value)
+	})
+}
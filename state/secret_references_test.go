@@ -0,0 +1,120 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestResolveSecretReference(t *testing.T) {
+	os.Setenv("TK_TEST_SECRET", "from-env")
+	defer os.Unsetenv("TK_TEST_SECRET")
+
+	secretFile, err := ioutil.TempFile("", "tk-secret-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(secretFile.Name())
+	secretFile.WriteString("from-file\n")
+	secretFile.Close()
+
+	AllowExecReferences(true)
+	defer AllowExecReferences(false)
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"env:TK_TEST_SECRET", "from-env"},
+		{"file:" + secretFile.Name(), "from-file"},
+		{"exec:echo from-exec", "from-exec"},
+		{"literal", "literal"},
+		{"literal:env:NOT_A_REFERENCE", "env:NOT_A_REFERENCE"},
+		{"literal:literal:", "literal:"},
+	}
+
+	for _, test := range tests {
+		actual, err := ResolveSecretReference(test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.value, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("%s: wrong output, expected %s, received %s", test.value, test.expected, actual)
+		}
+	}
+
+	_, err = ResolveSecretReference("env:TK_TEST_MISSING_SECRET")
+	if err == nil {
+		t.Error("Expected unset environment variable to fail")
+	}
+}
+
+func TestExecReferencesNotAllowed(t *testing.T) {
+	_, err := ResolveSecretReference("exec:echo from-exec")
+	if err != ErrExecReferencesNotAllowed {
+		t.Errorf("Expected exec: references to be refused, got %v", err)
+	}
+
+	stateObj, _ := New("dev", []byte(`{"module":{"cluster-manager":{"name":"dev","rancher_admin_password":"exec:echo from-exec"}}}`))
+	if !stateObj.HasExecReferences() {
+		t.Error("Expected the exec: reference to be found")
+	}
+	err = stateObj.ResolveSecretReferences()
+	if err == nil {
+		t.Error("Expected resolving the configuration to fail")
+	}
+
+	literalState, _ := New("dev", []byte(`{"module":{"cluster-manager":{"name":"dev","rancher_admin_password":"literal:exec:echo from-exec"}}}`))
+	if literalState.HasExecReferences() {
+		t.Error("Expected a literal: value not to be an exec: reference")
+	}
+}
+
+func TestSecretReferencesNotAllowed(t *testing.T) {
+	os.Setenv("TK_TEST_SECRET", "from-env")
+	defer os.Unsetenv("TK_TEST_SECRET")
+
+	AllowSecretReferences(false)
+	defer AllowSecretReferences(true)
+
+	for _, value := range []string{"env:TK_TEST_SECRET", "file:~/.ssh/id_rsa"} {
+		_, err := ResolveSecretReference(value)
+		if err != ErrSecretReferencesNotAllowed {
+			t.Errorf("%s: expected the reference to be refused, got %v", value, err)
+		}
+	}
+
+	actual, err := ResolveSecretReference("literal:env:TK_TEST_SECRET")
+	if err != nil || actual != "env:TK_TEST_SECRET" {
+		t.Errorf("Expected literal: values to be resolved, got %s, %v", actual, err)
+	}
+}
+
+func TestSecretReferencesAreStored(t *testing.T) {
+	os.Setenv("TK_TEST_SECRET", "from-env")
+	defer os.Unsetenv("TK_TEST_SECRET")
+
+	key, _ := NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+
+	stateObj, _ := New("dev", []byte(`{"module":{"cluster-manager":{"name":"dev","rancher_admin_password":"env:TK_TEST_SECRET"}}}`))
+
+	// References aren't secrets, they are kept as is
+	err := stateObj.EncryptSecrets(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := stateObj.Get("module.cluster-manager.rancher_admin_password")
+	if password != "env:TK_TEST_SECRET" {
+		t.Errorf("Wrong output, expected %s, received %s", "env:TK_TEST_SECRET", password)
+	}
+
+	err = stateObj.ResolveSecretReferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	password = stateObj.Get("module.cluster-manager.rancher_admin_password")
+	if password != "from-env" {
+		t.Errorf("Wrong output, expected %s, received %s", "from-env", password)
+	}
+}
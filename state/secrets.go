@@ -0,0 +1,454 @@
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted values are stored as `tk-encrypted:v2:{salt}:{wrapped data key}:{ciphertext}`.
+// Each value is sealed with a random data key, the data key is sealed with a key encryption
+// key derived from the SecretKey and the salt. Rotating the SecretKey only re-wraps data keys.
+//
+// The ciphertext is bound to a context, the state name and the JSON path of the value, so a
+// value moved to another key or configuration doesn't decrypt. v1 values, which aren't bound
+// and whose keyfile key encryption keys are plain hashes, are still decrypted.
+const (
+	encryptedValuePrefix   = "tk-encrypted:v2:"
+	encryptedValuePrefixV1 = "tk-encrypted:v1:"
+)
+
+// Info of the HKDF deriving key encryption keys from keyfiles
+const keyfileKEKInfo = "triton-kubernetes key encryption key"
+
+// Printed instead of a redacted secret
+const redactedValue = "(redacted)"
+
+// Keys of the configuration values that hold secrets, wherever they appear in the configuration.
+// The generic access_key, secret_key and password also match e.g. the terraform backend block.
+var secretKeys = map[string]bool{
+	"access_key":                         true,
+	"aws_access_key":                     true,
+	"aws_secret_key":                     true,
+	"azure_client_secret":                true,
+	"gcp_path_to_credentials":            true,
+	"k8s_registry_password":              true,
+	"password":                           true,
+	"rancher_access_key":                 true,
+	"rancher_admin_password":             true,
+	"rancher_cluster_registration_token": true,
+	"rancher_registry_password":          true,
+	"rancher_secret_key":                 true,
+	"secret_key":                         true,
+	"vsphere_password":                   true,
+}
+
+// SecretKey is the key secrets in a state are encrypted with.
+type SecretKey struct {
+	material   []byte
+	passphrase bool
+
+	// Key encryption keys by version and salt, deriving them from a passphrase is slow
+	derived map[string][]byte
+}
+
+// NewKeyfileSecretKey returns a SecretKey using the contents of a keyfile,
+// e.g. 32 bytes from /dev/urandom.
+func NewKeyfileSecretKey(content []byte) (*SecretKey, error) {
+	if len(content) < 16 {
+		return nil, errors.New("Encryption keyfile must contain at least 16 bytes")
+	}
+
+	return &SecretKey{
+		material: content,
+		derived:  map[string][]byte{},
+	}, nil
+}
+
+// NewPassphraseSecretKey returns a SecretKey derived from a passphrase.
+func NewPassphraseSecretKey(passphrase string) (*SecretKey, error) {
+	if passphrase == "" {
+		return nil, errors.New("Encryption passphrase cannot be blank")
+	}
+
+	return &SecretKey{
+		material:   []byte(passphrase),
+		passphrase: true,
+		derived:    map[string][]byte{},
+	}, nil
+}
+
+func (key *SecretKey) keyEncryptionKey(version int, salt []byte) ([]byte, error) {
+	cacheKey := strconv.Itoa(version) + ":" + string(salt)
+	if kek, ok := key.derived[cacheKey]; ok {
+		return kek, nil
+	}
+
+	var kek []byte
+	switch {
+	case key.passphrase:
+		var err error
+		kek, err = scrypt.Key(key.material, salt, 32768, 8, 1, 32)
+		if err != nil {
+			return nil, err
+		}
+	case version == 1:
+		sum := sha256.Sum256(append(append([]byte{}, salt...), key.material...))
+		kek = sum[:]
+	default:
+		kek = make([]byte, 32)
+		_, err := io.ReadFull(hkdf.New(sha256.New, key.material, salt, []byte(keyfileKEKInfo)), kek)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key.derived[cacheKey] = kek
+	return kek, nil
+}
+
+// EncryptSecrets encrypts all secret values that aren't encrypted yet.
+// Terraform interpolations (e.g. `${module.cluster-manager.rancher_secret_key}`) and
+// secret references are left as is.
+func (state *State) EncryptSecrets(key *SecretKey) error {
+	var salt, dataKey, wrappedDataKey []byte
+
+	return state.walkSecrets(func(path, value string) (string, error) {
+		if IsEncryptedSecret(value) || IsSecretReference(value) || strings.Contains(value, "${") || value == "" {
+			return value, nil
+		}
+
+		// All values encrypted together share a data key
+		if dataKey == nil {
+			var err error
+			salt, err = randomBytes(16)
+			if err != nil {
+				return "", err
+			}
+			dataKey, err = randomBytes(32)
+			if err != nil {
+				return "", err
+			}
+			kek, err := key.keyEncryptionKey(2, salt)
+			if err != nil {
+				return "", err
+			}
+			wrappedDataKey, err = seal(kek, dataKey, nil)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		ciphertext, err := seal(dataKey, []byte(value), state.secretContext(path))
+		if err != nil {
+			return "", err
+		}
+
+		return formatEncryptedValue(salt, wrappedDataKey, ciphertext), nil
+	})
+}
+
+// DecryptSecrets replaces all encrypted values with their plaintext.
+func (state *State) DecryptSecrets(key *SecretKey) error {
+	return state.walkSecrets(func(path, value string) (string, error) {
+		return decryptSecret(key, value, state.secretContext(path))
+	})
+}
+
+// EncryptSecret encrypts a single value with a data key of its own, e.g. a file that isn't
+// part of a configuration. The value is bound to context, e.g. what it is, and can only be
+// decrypted by DecryptSecret with the same context.
+func EncryptSecret(key *SecretKey, value, context string) (string, error) {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	kek, err := key.keyEncryptionKey(2, salt)
+	if err != nil {
+		return "", err
+	}
+	wrappedDataKey, err := seal(kek, dataKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataKey, []byte(value), []byte(context))
+	if err != nil {
+		return "", err
+	}
+
+	return formatEncryptedValue(salt, wrappedDataKey, ciphertext), nil
+}
+
+// DecryptSecret returns the plaintext of an encrypted value, other values are returned as is.
+// context must be the one the value was encrypted with, v1 values aren't bound to one.
+func DecryptSecret(key *SecretKey, value, context string) (string, error) {
+	return decryptSecret(key, value, []byte(context))
+}
+
+func decryptSecret(key *SecretKey, value string, context []byte) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+
+	version, salt, wrappedDataKey, ciphertext, err := parseEncryptedValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := unwrapDataKey(key, version, salt, wrappedDataKey)
+	if err != nil {
+		return "", err
+	}
+
+	if version == 1 {
+		context = nil
+	}
+	plaintext, err := open(dataKey, ciphertext, context)
+	if err != nil {
+		return "", errors.New("encrypted value doesn't belong here, it was moved from another key or configuration")
+	}
+
+	return string(plaintext), nil
+}
+
+// RotateSecrets re-wraps the data keys of encrypted values with newKey. Secrets that
+// aren't encrypted yet are encrypted with newKey. oldKey can be nil if nothing is encrypted.
+// v1 values are encrypted again as v2 values.
+func (state *State) RotateSecrets(oldKey, newKey *SecretKey) error {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return err
+	}
+	newKEK, err := newKey.keyEncryptionKey(2, salt)
+	if err != nil {
+		return err
+	}
+
+	err = state.walkSecrets(func(path, value string) (string, error) {
+		if !IsEncryptedSecret(value) {
+			return value, nil
+		}
+		if oldKey == nil {
+			return "", errors.New("State contains encrypted secrets, the current encryption key must be provided")
+		}
+
+		version, oldSalt, wrappedDataKey, ciphertext, err := parseEncryptedValue(value)
+		if err != nil {
+			return "", err
+		}
+
+		// Left in plaintext for EncryptSecrets below
+		if version == 1 {
+			return decryptSecret(oldKey, value, nil)
+		}
+
+		dataKey, err := unwrapDataKey(oldKey, version, oldSalt, wrappedDataKey)
+		if err != nil {
+			return "", err
+		}
+
+		// Catch values moved to another key before they're re-wrapped
+		_, err = open(dataKey, ciphertext, state.secretContext(path))
+		if err != nil {
+			return "", errors.New("encrypted value doesn't belong here, it was moved from another key or configuration")
+		}
+
+		rewrappedDataKey, err := seal(newKEK, dataKey, nil)
+		if err != nil {
+			return "", err
+		}
+
+		return formatEncryptedValue(salt, rewrappedDataKey, ciphertext), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return state.EncryptSecrets(newKey)
+}
+
+// HasEncryptedSecrets reports whether the state contains encrypted values.
+func (state *State) HasEncryptedSecrets() bool {
+	found := false
+	state.walkSecrets(func(path, value string) (string, error) {
+		if IsEncryptedSecret(value) {
+			found = true
+		}
+		return value, nil
+	})
+
+	return found
+}
+
+// RedactBackendSecrets replaces the secrets of the terraform backend block, e.g. the S3
+// backend's secret_key, so the state can be printed. Only use it on a state that isn't persisted.
+func (state *State) RedactBackendSecrets() {
+	walkSecretValues(state.configJSON.Search("terraform", "backend").Data(), "terraform.backend", func(path, value string) (string, error) {
+		if value == "" {
+			return value, nil
+		}
+		return redactedValue, nil
+	})
+}
+
+// walkSecrets replaces every secret string value with the result of fn, which is given the
+// JSON path of the value, e.g. `module.cluster-manager.rancher_admin_password`.
+func (state *State) walkSecrets(fn func(path, value string) (string, error)) error {
+	return walkSecretValues(state.configJSON.Data(), "", fn)
+}
+
+func walkSecretValues(data interface{}, path string, fn func(path, value string) (string, error)) error {
+	switch node := data.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			if value, ok := child.(string); ok && secretKeys[key] {
+				newValue, err := fn(joinPath(path, key), value)
+				if err != nil {
+					return fmt.Errorf("Could not process secret '%s': %s", key, err)
+				}
+				node[key] = newValue
+				continue
+			}
+
+			err := walkSecretValues(child, joinPath(path, key), fn)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, child := range node {
+			err := walkSecretValues(child, joinPath(path, strconv.Itoa(i)), fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// secretContext is the context a secret value at path is encrypted with.
+func (state *State) secretContext(path string) []byte {
+	return []byte(state.Name + "\x00" + path)
+}
+
+// IsEncryptedSecret reports whether value was encrypted by EncryptSecrets or EncryptSecret.
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, encryptedValuePrefix) || strings.HasPrefix(value, encryptedValuePrefixV1)
+}
+
+func formatEncryptedValue(salt, wrappedDataKey, ciphertext []byte) string {
+	return encryptedValuePrefix + strings.Join([]string{
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(wrappedDataKey),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	}, ":")
+}
+
+func parseEncryptedValue(value string) (version int, salt, wrappedDataKey, ciphertext []byte, err error) {
+	version = 2
+	if strings.HasPrefix(value, encryptedValuePrefixV1) {
+		version = 1
+	}
+
+	parts := strings.Split(value[len(encryptedValuePrefix):], ":")
+	if len(parts) != 3 {
+		err = errors.New("malformed encrypted value")
+		return
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		decoded[i], err = base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			err = errors.New("malformed encrypted value")
+			return
+		}
+	}
+
+	return version, decoded[0], decoded[1], decoded[2], nil
+}
+
+func unwrapDataKey(key *SecretKey, version int, salt, wrappedDataKey []byte) ([]byte, error) {
+	kek, err := key.keyEncryptionKey(version, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := open(kek, wrappedDataKey, nil)
+	if err != nil {
+		return nil, errors.New("wrong encryption key")
+	}
+
+	return dataKey, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, the nonce is prepended to the ciphertext.
+// additionalData is authenticated but not encrypted, open must be given the same.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(rand.Reader, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
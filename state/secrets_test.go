@@ -0,0 +1,217 @@
+package state
+
+import (
+	"strings"
+	"testing"
+)
+
+const secretsConfig = `{
+	"terraform": {"backend": {"s3": {"bucket": "tk", "secret_key": "s3-secret"}}},
+	"module": {
+		"cluster-manager": {"name": "dev", "rancher_admin_password": "hunter2"},
+		"cluster_aws_dev": {"aws_secret_key": "aws-secret", "rancher_secret_key": "${module.cluster-manager.rancher_secret_key}"}
+	}
+}`
+
+func TestEncryptSecrets(t *testing.T) {
+	key, _ := NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+
+	stateObj, err := New("dev", []byte(secretsConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stateObj.EncryptSecrets(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, plaintext := range []string{"s3-secret", "hunter2", "aws-secret"} {
+		if strings.Contains(string(stateObj.Bytes()), plaintext) {
+			t.Errorf("Expected %s to be encrypted", plaintext)
+		}
+	}
+	if !stateObj.HasEncryptedSecrets() {
+		t.Error("Expected state to have encrypted secrets")
+	}
+
+	// Interpolations and other values are left alone
+	interpolation := stateObj.Get("module.cluster_aws_dev.rancher_secret_key")
+	if interpolation != "${module.cluster-manager.rancher_secret_key}" {
+		t.Errorf("Wrong output, expected interpolation to be kept, received %s", interpolation)
+	}
+	if stateObj.Get("module.cluster-manager.name") != "dev" {
+		t.Error("Expected name to be kept")
+	}
+
+	// Encrypting again doesn't change encrypted values
+	encrypted := string(stateObj.Bytes())
+	stateObj.EncryptSecrets(key)
+	if encrypted != string(stateObj.Bytes()) {
+		t.Error("Expected encrypted values not to be encrypted twice")
+	}
+
+	err = stateObj.DecryptSecrets(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password := stateObj.Get("module.cluster-manager.rancher_admin_password")
+	if password != "hunter2" {
+		t.Errorf("Wrong output, expected %s, received %s", "hunter2", password)
+	}
+	secretKey := stateObj.Get("terraform.backend.s3.secret_key")
+	if secretKey != "s3-secret" {
+		t.Errorf("Wrong output, expected %s, received %s", "s3-secret", secretKey)
+	}
+}
+
+func TestDecryptSecretsWrongKey(t *testing.T) {
+	key, _ := NewPassphraseSecretKey("correct horse")
+	wrongKey, _ := NewPassphraseSecretKey("battery staple")
+
+	stateObj, _ := New("dev", []byte(secretsConfig))
+	stateObj.EncryptSecrets(key)
+
+	err := stateObj.DecryptSecrets(wrongKey)
+	if err == nil {
+		t.Error("Expected decrypting with the wrong key to fail")
+	}
+}
+
+func TestDecryptSecretsMovedValue(t *testing.T) {
+	key, _ := NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+
+	stateObj, _ := New("dev", []byte(secretsConfig))
+	stateObj.EncryptSecrets(key)
+
+	// Moved to another key of the same configuration
+	moved, _ := New("dev", stateObj.Bytes())
+	moved.Set("module.cluster_aws_dev.aws_secret_key", moved.Get("module.cluster-manager.rancher_admin_password"))
+	err := moved.DecryptSecrets(key)
+	if err == nil {
+		t.Error("Expected a value moved to another key not to decrypt")
+	}
+
+	// Moved to another configuration
+	renamed, _ := New("prod", stateObj.Bytes())
+	err = renamed.DecryptSecrets(key)
+	if err == nil {
+		t.Error("Expected a value moved to another configuration not to decrypt")
+	}
+}
+
+func TestDecryptSecretsV1(t *testing.T) {
+	key, _ := NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+	newKey, _ := NewPassphraseSecretKey("correct horse")
+
+	// A v1 value: a hashed key encryption key and no context
+	salt, _ := randomBytes(16)
+	dataKey, _ := randomBytes(32)
+	kek, _ := key.keyEncryptionKey(1, salt)
+	wrappedDataKey, _ := seal(kek, dataKey, nil)
+	ciphertext, _ := seal(dataKey, []byte("hunter2"), nil)
+	value := encryptedValuePrefixV1 + strings.TrimPrefix(formatEncryptedValue(salt, wrappedDataKey, ciphertext), encryptedValuePrefix)
+
+	stateObj, _ := New("dev", []byte(`{"module":{"cluster-manager":{"rancher_admin_password":""}}}`))
+	stateObj.Set("module.cluster-manager.rancher_admin_password", value)
+	if !stateObj.HasEncryptedSecrets() {
+		t.Error("Expected state to have encrypted secrets")
+	}
+
+	decrypted, _ := stateObj.Clone()
+	err := decrypted.DecryptSecrets(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := decrypted.Get("module.cluster-manager.rancher_admin_password"); password != "hunter2" {
+		t.Errorf("Wrong output, expected hunter2, received %s", password)
+	}
+
+	// Rotating encrypts v1 values again as v2 values
+	err = stateObj.RotateSecrets(key, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := stateObj.Get("module.cluster-manager.rancher_admin_password")
+	if !strings.HasPrefix(rotated, encryptedValuePrefix) {
+		t.Errorf("Wrong output, expected a v2 value, received %s", rotated)
+	}
+	err = stateObj.DecryptSecrets(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := stateObj.Get("module.cluster-manager.rancher_admin_password"); password != "hunter2" {
+		t.Errorf("Wrong output, expected hunter2, received %s", password)
+	}
+}
+
+func TestRotateSecrets(t *testing.T) {
+	oldKey, _ := NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+	newKey, _ := NewPassphraseSecretKey("correct horse")
+
+	stateObj, _ := New("dev", []byte(secretsConfig))
+	stateObj.EncryptSecrets(oldKey)
+
+	// Secrets added after the last encryption are still in plaintext
+	stateObj.SetCluster("gcp", "beta", Cluster{Name: "beta", Fields: map[string]interface{}{"password": "gke-password"}})
+
+	err := stateObj.RotateSecrets(oldKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(stateObj.Bytes()), "gke-password") {
+		t.Error("Expected plaintext secret to be encrypted")
+	}
+
+	rotated, _ := New("dev", stateObj.Bytes())
+	err = rotated.DecryptSecrets(oldKey)
+	if err == nil {
+		t.Error("Expected the old key to no longer decrypt secrets")
+	}
+
+	err = stateObj.DecryptSecrets(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stateObj.Get("module.cluster-manager.rancher_admin_password") != "hunter2" {
+		t.Error("Expected rancher_admin_password to decrypt with the new key")
+	}
+	if stateObj.Get("module.cluster_gcp_beta.password") != "gke-password" {
+		t.Error("Expected password to decrypt with the new key")
+	}
+}
+
+func TestRotateSecretsWithoutOldKey(t *testing.T) {
+	key, _ := NewKeyfileSecretKey([]byte("0123456789abcdef0123456789abcdef"))
+	newKey, _ := NewPassphraseSecretKey("correct horse")
+
+	stateObj, _ := New("dev", []byte(secretsConfig))
+	stateObj.EncryptSecrets(key)
+
+	err := stateObj.RotateSecrets(nil, newKey)
+	if err == nil {
+		t.Error("Expected rotating encrypted secrets without the current key to fail")
+	}
+}
+
+func TestRedactBackendSecrets(t *testing.T) {
+	stateObj, err := New("dev", []byte(secretsConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateObj.RedactBackendSecrets()
+
+	if value := stateObj.Get("terraform.backend.s3.secret_key"); value != redactedValue {
+		t.Errorf("Wrong output, expected %s, received %s", redactedValue, value)
+	}
+	if value := stateObj.Get("terraform.backend.s3.bucket"); value != "tk" {
+		t.Errorf("Wrong output, expected tk, received %s", value)
+	}
+	// Secrets outside of the backend block are the configuration's own
+	if value := stateObj.Get("module.cluster-manager.rancher_admin_password"); value != "hunter2" {
+		t.Errorf("Wrong output, expected hunter2, received %s", value)
+	}
+}
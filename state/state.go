@@ -1,15 +1,22 @@
 package state
 
 import (
-	"fmt"
-	"strings"
-
 	"github.com/Jeffail/gabs"
 )
 
 type State struct {
-	Name       string
+	Name string
+
+	// Revision identifies the stored configuration this state was read from
+	// (e.g. an ETag or content hash). It is set by the backend and is empty
+	// for a configuration that hasn't been persisted yet.
+	Revision string
+
 	configJSON *gabs.Container
+
+	// The configuration as stored and the upgrades applied to it, see Load
+	stored          *gabs.Container
+	appliedUpgrades []string
 }
 
 func New(name string, raw []byte) (State, error) {
@@ -33,8 +40,19 @@ func (state *State) Get(path string) string {
 	return value
 }
 
-func (state *State) SetManager(obj interface{}) error {
-	_, err := state.configJSON.SetP(obj, "module.cluster-manager")
+// Value returns the value at path, e.g. `module.cluster_triton_dev.k8s_version`,
+// and false if there is none.
+func (state *State) Value(path string) (interface{}, bool) {
+	if !state.configJSON.ExistsP(path) {
+		return nil, false
+	}
+
+	return state.configJSON.Path(path).Data(), true
+}
+
+// Set sets the value at path, creating the objects on the way if needed.
+func (state *State) Set(path string, value interface{}) error {
+	_, err := state.configJSON.SetP(value, path)
 	if err != nil {
 		return err
 	}
@@ -42,6 +60,16 @@ func (state *State) SetManager(obj interface{}) error {
 	return nil
 }
 
+// SetManager stores the cluster manager module.
+func (state *State) SetManager(manager Manager) error {
+	err := state.setModule("cluster-manager", manager)
+	if err != nil {
+		return err
+	}
+
+	return state.setMetadata("cluster-manager", ModuleMetadata{Role: RoleManager})
+}
+
 func (state *State) SetTerraformBackendConfig(tfBackendPath string, tfBackendObj interface{}) error {
 	_, err := state.configJSON.SetP(tfBackendObj, tfBackendPath)
 	if err != nil {
@@ -51,39 +79,88 @@ func (state *State) SetTerraformBackendConfig(tfBackendPath string, tfBackendObj
 	return nil
 }
 
-// Clusters are stored at path `module.cluster_{provider}_{clusterName}`
-func (state *State) AddCluster(provider, name string, obj interface{}) error {
-	_, err := state.configJSON.SetP(obj, fmt.Sprintf("module.cluster_%s_%s", provider, name))
+// SetCluster stores the module of the named cluster.
+// Clusters are stored at path `module.cluster_{provider}_{clusterName}`, see ClusterKey
+func (state *State) SetCluster(provider, name string, cluster Cluster) error {
+	metadata := ModuleMetadata{Role: RoleCluster, Provider: provider, Cluster: name}
+
+	key := state.findModule(metadata, "")
+	if key == "" {
+		key = ClusterKey(provider, name)
+	}
+
+	err := state.checkModuleKey(key, metadata, "")
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = state.setModule(key, cluster)
+	if err != nil {
+		return err
+	}
+
+	return state.setMetadata(key, metadata)
 }
 
-// Nodes are stored at path `module.node_{provider}_{clusterName}_{nodeName}`
-func (state *State) AddNode(clusterKey, name string, obj interface{}) error {
-	provider, clusterName, err := getClusterKeyParts(clusterKey)
+// SetNode stores the module of the named node of a cluster.
+// Nodes are stored at path `module.node_{provider}_{clusterName}__{nodeName}`, see NodeKey
+func (state *State) SetNode(clusterKey, name string, node Node) error {
+	provider, clusterName, err := state.clusterOf(clusterKey)
 	if err != nil {
 		return err
 	}
+	metadata := ModuleMetadata{Role: RoleNode, Provider: provider, Cluster: clusterName}
 
-	_, err = state.configJSON.SetP(obj, fmt.Sprintf("module.node_%s_%s_%s", provider, clusterName, name))
+	key := state.findModule(metadata, name)
+	if key == "" {
+		key = NodeKey(provider, clusterName, name)
+	}
+
+	err = state.checkModuleKey(key, metadata, name)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = state.setModule(key, node)
+	if err != nil {
+		return err
+	}
+
+	return state.setMetadata(key, metadata)
 }
 
-// Backups are stored at path `module.backup_{clusterName}`
-func (state *State) AddBackup(name string, obj interface{}) error {
-	_, err := state.configJSON.SetP(obj, fmt.Sprintf("module.backup_%s", name))
+// SetBackup stores the backup module of a cluster.
+// Backups are stored at path `module.backup_{clusterKey}`
+func (state *State) SetBackup(clusterKey string, backup Backup) error {
+	provider, clusterName, err := state.clusterOf(clusterKey)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	key := BackupKey(clusterKey)
+	err = state.setModule(key, backup)
+	if err != nil {
+		return err
+	}
+
+	return state.setMetadata(key, ModuleMetadata{Role: RoleBackup, Provider: provider, Cluster: clusterName})
+}
+
+// Clone returns a copy of the state that can be changed without changing this one.
+func (state *State) Clone() (State, error) {
+	clone, err := New(state.Name, state.configJSON.Bytes())
+	if err != nil {
+		return State{}, err
+	}
+	clone.Revision = state.Revision
+
+	return clone, nil
+}
+
+// DeleteModule removes the module with the given key, e.g. a cluster key from Clusters
+// or a node key from Nodes, together with its metadata.
+func (state *State) DeleteModule(key string) error {
+	return state.configJSON.Delete("module", key)
 }
 
 func (state *State) Delete(path string) error {
@@ -95,12 +172,19 @@ func (state *State) Delete(path string) error {
 	return nil
 }
 
+// Bytes returns the configuration serialized canonically, see canonicalJSON, so that
+// storing an unchanged configuration never produces a diff.
 func (state *State) Bytes() []byte {
-	return state.configJSON.BytesIndent("", "\t")
+	content, err := canonicalJSON(state.configJSON.Data())
+	if err != nil {
+		// Only values that can't be serialized at all end up here
+		return state.configJSON.BytesIndent("", "\t")
+	}
+
+	return content
 }
 
 // Returns map of cluster name to cluster key
-// Clusters are stored at path `module.cluster_{provider}_{clusterName}`
 func (state *State) Clusters() (map[string]string, error) {
 	result := map[string]string{}
 
@@ -109,13 +193,10 @@ func (state *State) Clusters() (map[string]string, error) {
 		return nil, err
 	}
 
-	for key, child := range children {
-		if strings.Index(key, "cluster_") == 0 {
-			name, ok := child.Path("name").Data().(string)
-			if !ok {
-				continue
-			}
-			result[name] = key
+	for key := range children {
+		metadata, ok := state.metadata(key)
+		if ok && metadata.Role == RoleCluster {
+			result[metadata.Cluster] = key
 		}
 	}
 
@@ -123,64 +204,66 @@ func (state *State) Clusters() (map[string]string, error) {
 }
 
 // Returns map of node name to node key for all nodes in a cluster
-// Nodes are stored at path `module.node_{provider}_{clusterName}_{nodeName}`
 func (state *State) Nodes(clusterKey string) (map[string]string, error) {
 	result := map[string]string{}
 
-	provider, name, err := getClusterKeyParts(clusterKey)
+	provider, name, err := state.clusterOf(clusterKey)
 	if err != nil {
 		return nil, err
 	}
 
-	// Nodes are named `node_{provider}_{clusterName}_{nodeName}`
-	// nodePrefix is `node_{provider}_{clusterName}_`
-	nodePrefix := fmt.Sprintf("node_%s_%s_", provider, name)
-
 	children, err := state.configJSON.S("module").ChildrenMap()
 	if err != nil {
 		return nil, err
 	}
 
 	for key, child := range children {
-		if strings.Index(key, nodePrefix) == 0 {
-			// Retrieving hostname
-			hostname, ok := child.Path("hostname").Data().(string)
-			if !ok {
-				continue
-			}
-
-			result[hostname] = key
+		metadata, ok := state.metadata(key)
+		if !ok || metadata != (ModuleMetadata{Role: RoleNode, Provider: provider, Cluster: name}) {
+			continue
+		}
+
+		// Retrieving hostname
+		hostname, ok := child.Path("hostname").Data().(string)
+		if !ok {
+			continue
 		}
+
+		result[hostname] = key
 	}
 
 	return result, nil
 }
 
-// Backups are stored at path `module.backup_{clusterKey}`
+// Returns the key of a cluster's backup module, or an empty string if it has no backup.
 func (state *State) Backup(clusterKey string) string {
+	key := BackupKey(clusterKey)
+	if !state.configJSON.Exists("module", key) {
+		return ""
+	}
+
+	return key
+}
+
+// findModule returns the key of the module with the given metadata, and for nodes the
+// given hostname, or an empty string if there is none.
+func (state *State) findModule(metadata ModuleMetadata, hostname string) string {
 	children, err := state.configJSON.S("module").ChildrenMap()
 	if err != nil {
 		return ""
 	}
 
-	for key := range children {
-		if key == fmt.Sprintf("backup_%s", clusterKey) {
-			return key
+	for key, child := range children {
+		existing, ok := state.metadata(key)
+		if !ok || existing != metadata {
+			continue
+		}
+		if metadata.Role == RoleNode && child.Path("hostname").Data() != hostname {
+			continue
 		}
-	}
-
-	return ""
-}
 
-func getClusterKeyParts(clusterKey string) (provider, name string, err error) {
-	parts := strings.Split(clusterKey, "_")
-	if len(parts) < 3 {
-		err = fmt.Errorf("Could not get cluster key parts, cluster does not follow format `cluster_{provider}_{clusterName}` '%s'", clusterKey)
-		return
+		return key
 	}
 
-	provider = parts[1]
-	name = parts[2]
-
-	return
+	return ""
 }
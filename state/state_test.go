@@ -23,7 +23,7 @@ func TestSetManager(t *testing.T) {
 		t.Error(err)
 	}
 
-	err = stateObj.SetManager(map[string]interface{}{"field": "test"})
+	err = stateObj.SetManager(Manager{Fields: map[string]interface{}{"field": "test"}})
 	if err != nil {
 		t.Error(err)
 	}
@@ -52,13 +52,13 @@ func TestSetTerraformBackendConfig(t *testing.T) {
 }
 
 // Add test
-func TestAddCluster(t *testing.T) {
+func TestSetCluster(t *testing.T) {
 	stateObj, err := New("AddState", []byte(`{}`))
 	if err != nil {
 		t.Error(err)
 	}
 
-	err1 := stateObj.AddCluster("aws", "name", map[string]interface{}{"field": "test"})
+	err1 := stateObj.SetCluster("aws", "name", Cluster{Fields: map[string]interface{}{"field": "test"}})
 	if err1 != nil {
 		t.Error(err1)
 	}
@@ -69,18 +69,18 @@ func TestAddCluster(t *testing.T) {
 	}
 }
 
-func TestAddNode(t *testing.T) {
+func TestSetNode(t *testing.T) {
 	stateObj, err := New("AddState", []byte(`{}`))
 	if err != nil {
 		t.Error(err)
 	}
 
-	err1 := stateObj.AddNode("cluster_aws_cluster-name", "node-name", map[string]interface{}{"field": "test"})
+	err1 := stateObj.SetNode("cluster_aws_cluster-name", "node-name", Node{Fields: map[string]interface{}{"field": "test"}})
 	if err1 != nil {
 		t.Error(err1)
 	}
 
-	notEmptyPath := stateObj.Get("module.node_aws_cluster-name_node-name.field")
+	notEmptyPath := stateObj.Get("module.node_aws_cluster-name__node-name.field")
 	if notEmptyPath != "test" {
 		t.Errorf("value in state object, got: %s, want: %s", notEmptyPath, "test")
 	}
@@ -106,7 +106,7 @@ func TestDelete(t *testing.T) {
 
 // GetClusters test
 func TestGetClusters(t *testing.T) {
-	stateObj, err := New("ClusterState", []byte(`{
+	stateObj, err := Load("ClusterState", []byte(`{
 		"module":{
 						"cluster_1":{"name":"dev_cluster"},
 						"cluster_2":{"name":"beta_cluster"},
@@ -133,7 +133,7 @@ func TestGetNodes(t *testing.T) {
 
 	clusterKeyString := clusterStateObj.Get("config.triton.key")
 
-	stateObj, err := New("NodeState", []byte(`{
+	stateObj, err := Load("NodeState", []byte(`{
     "module":{
       "node_triton_dev-cluster_1":{"hostname":"dev-worker1"},
       "node_triton_dev-cluster_2":{"hostname":"dev-etcd1"},
@@ -165,7 +165,7 @@ func TestGetNodesWithoutCloudProvider(t *testing.T) {
 
 	clusterKeyString := clusterStateObj.Get("config.triton.key")
 
-	stateObj, err := New("NodeState", []byte(`{
+	stateObj, err := Load("NodeState", []byte(`{
     "module":{
       "node_triton_dev-cluster_1":{"hostname":"dev-worker1"},
       "node_triton_dev-cluster_2":{"hostname":"dev-etcd1"},
@@ -188,3 +188,39 @@ func TestGetNodesWithoutCloudProvider(t *testing.T) {
 	}
 
 }
+
+func TestModuleChanges(t *testing.T) {
+	from, err := New("FromState", []byte(`{
+		"module":{
+			"cluster-manager":{"name":"manager"},
+			"cluster_triton_dev":{"name":"dev"},
+			"node_triton_dev_1":{"hostname":"dev-1"}
+		}
+	}`))
+	if err != nil {
+		t.Error(err)
+	}
+
+	to, err := New("ToState", []byte(`{
+		"module":{
+			"cluster-manager":{"name":"manager"},
+			"cluster_triton_dev":{"name":"dev","k8s_version":"v1.18.12-rancher1-1"},
+			"cluster_aws_prod":{"name":"prod"}
+		}
+	}`))
+	if err != nil {
+		t.Error(err)
+	}
+
+	added, removed, changed := ModuleChanges(from, to)
+
+	if len(added) != 1 || added[0] != "cluster_aws_prod" {
+		t.Errorf("wrong added modules: %v", added)
+	}
+	if len(removed) != 1 || removed[0] != "node_triton_dev_1" {
+		t.Errorf("wrong removed modules: %v", removed)
+	}
+	if len(changed) != 1 || changed[0] != "cluster_triton_dev" {
+		t.Errorf("wrong changed modules: %v", changed)
+	}
+}
@@ -0,0 +1,114 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/Jeffail/gabs"
+)
+
+// SchemaVersion is the version of the configuration format written by this release.
+// Configurations without a version were written before versions were recorded and are version 1.
+const SchemaVersion = 2
+
+// Upgrade moves a configuration from one schema version to the next.
+type Upgrade struct {
+	Description string
+	Apply       func(state *State) error
+}
+
+// upgrades[v] upgrades a configuration from version v to version v+1. Upgrades may
+// rename fields, rewrite module sources or move keys, but must not depend on anything
+// outside the configuration.
+var upgrades = map[int]Upgrade{
+	1: {
+		Description: "Add metadata (role, provider, cluster) to module blocks",
+		Apply: func(state *State) error {
+			state.migrateModuleMetadata()
+			return nil
+		},
+	},
+}
+
+// Load parses a stored configuration and upgrades it to the current schema version.
+func Load(name string, raw []byte) (State, error) {
+	state, err := New(name, raw)
+	if err != nil {
+		return State{}, err
+	}
+
+	if state.SchemaVersion() == SchemaVersion {
+		return state, nil
+	}
+
+	stored, err := gabs.ParseJSON(raw)
+	if err != nil {
+		return State{}, err
+	}
+
+	applied, err := state.upgrade()
+	if err != nil {
+		return State{}, fmt.Errorf("Could not upgrade configuration of '%s': %s", name, err)
+	}
+	state.stored = stored
+	state.appliedUpgrades = applied
+
+	return state, nil
+}
+
+// SchemaVersion returns the schema version of the configuration.
+func (state *State) SchemaVersion() int {
+	version, ok := state.configJSON.Search(metadataField, "schema_version").Data().(float64)
+	if !ok {
+		return 1
+	}
+
+	return int(version)
+}
+
+// AppliedUpgrades returns the descriptions of the upgrades applied when the state was loaded.
+func (state *State) AppliedUpgrades() []string {
+	return state.appliedUpgrades
+}
+
+// Stored returns the state as it was stored, before any upgrades were applied.
+func (state *State) Stored() State {
+	if state.stored == nil {
+		return *state
+	}
+
+	return State{
+		Name:       state.Name,
+		Revision:   state.Revision,
+		configJSON: state.stored,
+	}
+}
+
+func (state *State) upgrade() ([]string, error) {
+	applied := []string{}
+
+	version := state.SchemaVersion()
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("schema version %d is newer than the supported version %d, please update triton-kubernetes", version, SchemaVersion)
+	}
+
+	for ; version < SchemaVersion; version++ {
+		upgrade, ok := upgrades[version]
+		if !ok {
+			return nil, fmt.Errorf("no upgrade from schema version %d", version)
+		}
+
+		err := upgrade.Apply(state)
+		if err != nil {
+			return nil, fmt.Errorf("upgrade from schema version %d failed: %s", version, err)
+		}
+
+		_, err = state.configJSON.Set(float64(version+1), metadataField, "schema_version")
+		if err != nil {
+			return nil, err
+		}
+
+		applied = append(applied, fmt.Sprintf("%d -> %d: %s", version, version+1, upgrade.Description))
+	}
+
+	return applied, nil
+}
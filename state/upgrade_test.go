@@ -0,0 +1,55 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLoadUpgradesOlderSchema(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(`{"module":{"cluster_triton_dev":{"name":"dev"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stateObj.SchemaVersion() != SchemaVersion {
+		t.Errorf("schema version, got: %d, want: %d", stateObj.SchemaVersion(), SchemaVersion)
+	}
+	if len(stateObj.AppliedUpgrades()) != SchemaVersion-1 {
+		t.Errorf("applied upgrades, got: %v", stateObj.AppliedUpgrades())
+	}
+
+	stored := stateObj.Stored()
+	if stored.SchemaVersion() != 1 {
+		t.Errorf("stored schema version, got: %d, want: 1", stored.SchemaVersion())
+	}
+	if _, ok := stored.metadata("cluster_triton_dev"); ok {
+		t.Error("expected the stored state not to be upgraded")
+	}
+}
+
+func TestLoadCurrentSchema(t *testing.T) {
+	upgraded, err := Load("dev-manager", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateObj, err := Load("dev-manager", upgraded.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stateObj.AppliedUpgrades()) != 0 {
+		t.Errorf("applied upgrades, got: %v", stateObj.AppliedUpgrades())
+	}
+	if string(stateObj.Bytes()) != string(upgraded.Bytes()) {
+		t.Errorf("configuration changed, got: %s, want: %s", stateObj.Bytes(), upgraded.Bytes())
+	}
+}
+
+func TestLoadNewerSchema(t *testing.T) {
+	_, err := Load("dev-manager", []byte(fmt.Sprintf(`{"//":{"schema_version":%d}}`, SchemaVersion+1)))
+	if err == nil || !strings.Contains(err.Error(), "newer than the supported version") {
+		t.Errorf("expected an error for a newer schema version, got: %v", err)
+	}
+}
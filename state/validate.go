@@ -0,0 +1,152 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Finding is a problem found in a configuration by Validate.
+type Finding struct {
+	Module  string
+	Message string
+}
+
+// Fields every module of a role must set
+var requiredFields = map[string][]string{
+	RoleManager: {"source", "name"},
+	RoleCluster: {"source", "name", "rancher_api_url", "rancher_access_key", "rancher_secret_key"},
+	RoleNode:    {"source", "hostname", "rancher_api_url", "rancher_cluster_registration_token"},
+	RoleBackup:  {"source", "rancher_api_url", "rancher_access_key", "rancher_secret_key", "rancher_cluster_id"},
+}
+
+// Fields cluster and node modules of a provider must set
+var requiredProviderFields = map[string][]string{
+	"aks":       {"azure_subscription_id", "azure_client_id", "azure_client_secret", "azure_tenant_id", "azure_environment", "azure_location"},
+	"aws":       {"aws_access_key", "aws_secret_key", "aws_region"},
+	"azure":     {"azure_subscription_id", "azure_client_id", "azure_client_secret", "azure_tenant_id", "azure_environment", "azure_location"},
+	"baremetal": {},
+	"gcp":       {"gcp_path_to_credentials", "gcp_project_id", "gcp_compute_region"},
+	"gke":       {"gcp_path_to_credentials", "gcp_project_id", "gcp_compute_region", "gcp_zone"},
+	"imported":  {"rancher_cluster_id"},
+	"triton":    {"triton_account", "triton_key_path", "triton_key_id"},
+	"vsphere":   {"vsphere_user", "vsphere_password", "vsphere_server"},
+}
+
+// Fields bare metal nodes must set, they don't share fields with their cluster
+var requiredBareMetalNodeFields = []string{"host"}
+
+// Nodes of other providers that were adopted use the bare metal host module too
+const bareMetalHostModule = "terraform/modules/bare-metal-rancher-k8s-host"
+
+var moduleReferenceRegexp = regexp.MustCompile(`\$\{module\.([A-Za-z0-9_-]+)\.`)
+
+// Validate checks that the modules of a configuration are complete and consistent: every
+// module has the fields its role and provider require, nodes and backups belong to a cluster
+// that exists, and interpolations only reference modules that exist.
+func (state *State) Validate() []Finding {
+	findings := []Finding{}
+
+	children, err := state.configJSON.S("module").ChildrenMap()
+	if err != nil {
+		return findings
+	}
+
+	for key, child := range children {
+		fields, ok := child.Data().(map[string]interface{})
+		if !ok {
+			findings = append(findings, Finding{key, "module is not an object"})
+			continue
+		}
+
+		metadata, ok := state.metadata(key)
+		if !ok {
+			findings = append(findings, Finding{key, "module has no metadata, run `triton-kubernetes state upgrade`"})
+		} else {
+			for _, field := range requiredModuleFields(metadata, fields) {
+				if value, _ := fields[field].(string); value == "" {
+					findings = append(findings, Finding{key, fmt.Sprintf("required field '%s' is not set", field)})
+				}
+			}
+
+			if metadata.Role == RoleCluster && metadata.Provider != "" {
+				if _, ok := requiredProviderFields[metadata.Provider]; !ok {
+					findings = append(findings, Finding{key, fmt.Sprintf("unknown provider '%s'", metadata.Provider)})
+				}
+			}
+
+			if metadata.Role == RoleNode || metadata.Role == RoleBackup {
+				cluster := ModuleMetadata{Role: RoleCluster, Provider: metadata.Provider, Cluster: metadata.Cluster}
+				if state.findModule(cluster, "") == "" {
+					findings = append(findings, Finding{key, fmt.Sprintf("%s cluster '%s' does not exist", metadata.Provider, metadata.Cluster)})
+				}
+			}
+		}
+
+		for _, reference := range moduleReferences(fields) {
+			if _, ok := children[reference]; !ok {
+				findings = append(findings, Finding{key, fmt.Sprintf("references module '%s', which does not exist", reference)})
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Module != findings[j].Module {
+			return findings[i].Module < findings[j].Module
+		}
+		return findings[i].Message < findings[j].Message
+	})
+
+	return findings
+}
+
+func requiredModuleFields(metadata ModuleMetadata, module map[string]interface{}) []string {
+	fields := append([]string{}, requiredFields[metadata.Role]...)
+
+	switch metadata.Role {
+	case RoleCluster:
+		fields = append(fields, requiredProviderFields[metadata.Provider]...)
+	case RoleNode:
+		source, _ := module["source"].(string)
+		if metadata.Provider == "baremetal" || strings.Contains(source, bareMetalHostModule) {
+			fields = append(fields, requiredBareMetalNodeFields...)
+		} else {
+			fields = append(fields, requiredProviderFields[metadata.Provider]...)
+		}
+	}
+
+	return fields
+}
+
+// moduleReferences returns the modules referenced by `${module.X.…}` interpolations in data.
+func moduleReferences(data interface{}) []string {
+	found := map[string]bool{}
+	collectModuleReferences(data, found)
+
+	references := []string{}
+	for reference := range found {
+		references = append(references, reference)
+	}
+
+	return references
+}
+
+func collectModuleReferences(data interface{}, found map[string]bool) {
+	switch node := data.(type) {
+	case string:
+		if strings.Contains(node, "${") {
+			for _, match := range moduleReferenceRegexp.FindAllStringSubmatch(node, -1) {
+				found[match[1]] = true
+			}
+		}
+	case map[string]interface{}:
+		for _, child := range node {
+			collectModuleReferences(child, found)
+		}
+	case []interface{}:
+		for _, child := range node {
+			collectModuleReferences(child, found)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package state
+
+import (
+	"testing"
+)
+
+const validConfig = `{
+	"module": {
+		"cluster-manager": {
+			"source": "github.com/joyent/triton-kubernetes//terraform/modules/triton-rancher?ref=master",
+			"name": "dev-manager"
+		},
+		"cluster_triton_dev": {
+			"source": "github.com/joyent/triton-kubernetes//terraform/modules/triton-rancher-k8s?ref=master",
+			"name": "dev",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_access_key": "${module.cluster-manager.rancher_access_key}",
+			"rancher_secret_key": "${module.cluster-manager.rancher_secret_key}",
+			"triton_account": "dev",
+			"triton_key_path": "~/.ssh/id_rsa",
+			"triton_key_id": "2c:53:bc:63:97:9e:79:3f:91:35:5e:f4:c8:23:88:37"
+		},
+		"node_triton_dev_worker-1": {
+			"source": "github.com/joyent/triton-kubernetes//terraform/modules/triton-rancher-k8s-host?ref=master",
+			"hostname": "worker-1",
+			"rancher_api_url": "${module.cluster-manager.rancher_url}",
+			"rancher_cluster_registration_token": "${module.cluster_triton_dev.rancher_cluster_registration_token}",
+			"triton_account": "dev",
+			"triton_key_path": "~/.ssh/id_rsa",
+			"triton_key_id": "2c:53:bc:63:97:9e:79:3f:91:35:5e:f4:c8:23:88:37"
+		}
+	}
+}`
+
+func TestValidateValidConfig(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := stateObj.Validate()
+	if len(findings) != 0 {
+		t.Errorf("expected no findings, got: %v", findings)
+	}
+}
+
+func TestValidateFindings(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing provider field, a backup of a cluster that doesn't exist, and a
+	// node referencing a module that doesn't exist
+	err = stateObj.Delete("module.cluster_triton_dev.triton_key_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stateObj.SetBackup("cluster_aws_prod", Backup{
+		Source:           "github.com/joyent/triton-kubernetes//terraform/modules/k8s-backup-s3?ref=master",
+		RancherAPIURL:    "${module.cluster-manager.rancher_url}",
+		RancherAccessKey: "${module.cluster-manager.rancher_access_key}",
+		RancherSecretKey: "${module.cluster-manager.rancher_secret_key}",
+		RancherClusterID: "${module.cluster_aws_prod.rancher_cluster_id}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stateObj.configJSON.Set("${module.cluster_triton_gone.rancher_cluster_ca_checksum}", "module", "node_triton_dev_worker-1", "rancher_cluster_ca_checksum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Finding{
+		{"backup_cluster_aws_prod", "aws cluster 'prod' does not exist"},
+		{"backup_cluster_aws_prod", "references module 'cluster_aws_prod', which does not exist"},
+		{"cluster_triton_dev", "required field 'triton_key_id' is not set"},
+		{"node_triton_dev_worker-1", "references module 'cluster_triton_gone', which does not exist"},
+	}
+
+	findings := stateObj.Validate()
+	if len(findings) != len(expected) {
+		t.Fatalf("findings, got: %v, want: %v", findings, expected)
+	}
+	for i := range expected {
+		if findings[i] != expected[i] {
+			t.Errorf("finding %d, got: %v, want: %v", i, findings[i], expected[i])
+		}
+	}
+}
+
+func TestValidateNodeWithoutCluster(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stateObj.Delete("module.cluster_triton_dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, finding := range stateObj.Validate() {
+		if finding == (Finding{"node_triton_dev_worker-1", "triton cluster 'dev' does not exist"}) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a finding for the node without a cluster, got: %v", stateObj.Validate())
+	}
+}
+
+func TestValidateAdoptedNode(t *testing.T) {
+	stateObj, err := Load("dev-manager", []byte(validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Adopted nodes use the bare metal host module and don't need triton credentials
+	err = stateObj.SetNode("cluster_triton_dev", "10_0_0_5", Node{
+		Source:                          "github.com/joyent/triton-kubernetes//terraform/modules/bare-metal-rancher-k8s-host?ref=master",
+		Hostname:                        "10.0.0.5",
+		BareMetalHost:                   BareMetalHost{Host: "10.0.0.5"},
+		RancherAPIURL:                   "${module.cluster-manager.rancher_url}",
+		RancherClusterRegistrationToken: "${module.cluster_triton_dev.rancher_cluster_registration_token}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := stateObj.Validate()
+	if len(findings) != 0 {
+		t.Errorf("expected no findings, got: %v", findings)
+	}
+
+	nodes, err := stateObj.Nodes("cluster_triton_dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeKey := nodes["10.0.0.5"]
+
+	err = stateObj.Delete("module." + nodeKey + ".host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Finding{nodeKey, "required field 'host' is not set"}
+	findings = stateObj.Validate()
+	if len(findings) != 1 || findings[0] != expected {
+		t.Errorf("findings, got: %v, want: %v", findings, []Finding{expected})
+	}
+}
@@ -1,4 +1,12 @@
 
 terraform {
   required_version = ">= 0.12"
+
+  # Installed by triton-kubernetes from its plugin manifest, it's not in the registry
+  required_providers {
+    rke = {
+      source  = "yamamoto-febc/rke"
+      version = "0.4.0"
+    }
+  }
 }
@@ -0,0 +1,71 @@
+#!/bin/bash
+
+# Looks up a cluster that was registered into Rancher outside of triton-kubernetes.
+# The cluster itself is never created or changed, only a registration token is created
+# if the cluster doesn't have one yet.
+
+# Exit if any of the intermediate steps fail
+set -e
+
+# Extract arguments from the input into shell variables.
+# jq will ensure that the values are properly quoted
+# and escaped for consumption by the shell.
+eval "$(jq -r '@sh "rancher_api_url=\(.rancher_api_url) rancher_access_key=\(.rancher_access_key) rancher_secret_key=\(.rancher_secret_key) rancher_cluster_id=\(.rancher_cluster_id)"')"
+
+cluster_response=$(curl -X GET \
+	--silent \
+	--insecure \
+	-u $rancher_access_key:$rancher_secret_key \
+	-H 'Accept: application/json' \
+	"$rancher_api_url/v3/clusters/$rancher_cluster_id")
+cluster_id=$(echo $cluster_response | jq -r '.id')
+
+if [ "$cluster_id" != "$rancher_cluster_id" ]; then
+	echo "Rancher cluster $rancher_cluster_id not found!" >&2;
+	exit 1
+fi
+
+# Cluster registration token
+get_registration_token_response=$(curl -X GET \
+	--silent \
+	--insecure \
+	-u $rancher_access_key:$rancher_secret_key \
+	-H 'Accept: application/json' \
+	"$rancher_api_url/v3/clusters/$cluster_id/clusterregistrationtokens")
+registration_token=$(echo $get_registration_token_response | jq -r '.data[0].token')
+
+if [ "$registration_token" == "" ] || [ "$registration_token" == "null" ]; then
+	# Create cluster registration token
+	create_registration_token_response=$(curl -X POST \
+		--silent \
+		--insecure \
+		-u $rancher_access_key:$rancher_secret_key \
+		-H 'Accept: application/json' \
+		-H 'Content-Type: application/json' \
+		-d '{"clusterId":"'$cluster_id'","type":"clusterRegistrationToken"}' \
+		"$rancher_api_url/v3/clusterregistrationtoken")
+
+	registration_token=$(echo $create_registration_token_response | jq -r '.token')
+fi
+
+if [ "$registration_token" == "" ] || [ "$registration_token" == "null" ]; then
+	echo "Unable to create cluster registration token!" >&2 ;
+	exit 1
+fi
+
+# Retrieve CA checksum
+cacerts_response=$(curl -X GET \
+	--silent \
+	--insecure \
+	-u $rancher_access_key:$rancher_secret_key \
+	-H 'Accept: application/json' \
+	"$rancher_api_url/v3/settings/cacerts")
+ca_checksum=$(echo $cacerts_response | jq -r .value | shasum -a 256 | awk '{ print $1 }')
+
+# Safely produce a JSON object containing the result value.
+# jq will ensure that the value is properly quoted
+# and escaped to produce a valid JSON string.
+jq -n --arg cluster_id "$cluster_id" \
+	--arg registration_token "$registration_token" \
+	--arg ca_checksum "$ca_checksum" \
+	'{"cluster_id":$cluster_id,"registration_token":$registration_token,"ca_checksum":$ca_checksum}'
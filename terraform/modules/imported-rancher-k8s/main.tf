@@ -0,0 +1,10 @@
+data "external" "rancher_cluster" {
+  program = ["bash", "${path.module}/files/rancher_cluster_lookup.sh"]
+
+  query = {
+    rancher_api_url    = var.rancher_api_url
+    rancher_access_key = var.rancher_access_key
+    rancher_secret_key = var.rancher_secret_key
+    rancher_cluster_id = var.rancher_cluster_id
+  }
+}
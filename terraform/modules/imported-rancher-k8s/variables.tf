@@ -0,0 +1,19 @@
+variable "name" {
+  description = "Name of the cluster in triton-kubernetes."
+}
+
+variable "rancher_api_url" {
+  description = ""
+}
+
+variable "rancher_access_key" {
+  description = ""
+}
+
+variable "rancher_secret_key" {
+  description = ""
+}
+
+variable "rancher_cluster_id" {
+  description = "ID of the existing Rancher cluster, e.g. c-4x8tq."
+}
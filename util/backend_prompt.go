@@ -9,6 +9,8 @@ import (
 	"github.com/joyent/triton-kubernetes/backend"
 	"github.com/joyent/triton-kubernetes/backend/local"
 	"github.com/joyent/triton-kubernetes/backend/manta"
+	"github.com/joyent/triton-kubernetes/backend/s3"
+	"github.com/joyent/triton-kubernetes/state"
 
 	"github.com/manifoldco/promptui"
 	homedir "github.com/mitchellh/go-homedir"
@@ -16,18 +18,56 @@ import (
 )
 
 func PromptForBackend() (backend.Backend, error) {
+	remoteBackend, err := promptForBackend("", "Backend to persist data")
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypt secrets in the stored configuration if a key is configured
+	secretKey, err := GetSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	if secretKey != nil {
+		return backend.WithSecretEncryption(remoteBackend, secretKey), nil
+	}
+
+	return remoteBackend, nil
+}
+
+// PromptForDestinationBackend returns a second backend, e.g. to migrate state to.
+// It is configured by the same keys as PromptForBackend prefixed with `destination_`,
+// and encrypts secrets with the same key.
+func PromptForDestinationBackend() (backend.Backend, error) {
+	destinationBackend, err := promptForBackend("destination_", "Backend to migrate to")
+	if err != nil {
+		return nil, err
+	}
+
+	secretKey, err := GetSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	if secretKey != nil {
+		return backend.WithSecretEncryption(destinationBackend, secretKey), nil
+	}
+
+	return destinationBackend, nil
+}
+
+func promptForBackend(keyPrefix, label string) (backend.Backend, error) {
 	nonInteractiveMode := viper.GetBool("non-interactive")
 
 	// Ask user what backend to use
 	selectedBackendProvider := ""
-	if viper.IsSet("backend_provider") {
-		selectedBackendProvider = viper.GetString("backend_provider")
+	if viper.IsSet(keyPrefix + "backend_provider") {
+		selectedBackendProvider = viper.GetString(keyPrefix + "backend_provider")
 	} else if nonInteractiveMode {
-		return nil, errors.New("backend_provider must be provided")
+		return nil, errors.New(keyPrefix + "backend_provider must be provided")
 	} else {
 		prompt := promptui.Select{
-			Label: "Backend to persist data",
-			Items: []string{"Local", "Manta"},
+			Label: label,
+			Items: []string{"Local", "Manta", "S3"},
 			Templates: &promptui.SelectTemplates{
 				Label:    "{{ . }}?",
 				Active:   fmt.Sprintf(`%s {{ . | underline }}`, promptui.IconSelect),
@@ -44,16 +84,30 @@ func PromptForBackend() (backend.Backend, error) {
 		selectedBackendProvider = strings.ToLower(value)
 	}
 
+	// exec: references run commands, only for configurations nobody else can write
+	if keyPrefix == "" && viper.GetBool("allow-exec-secrets") {
+		if selectedBackendProvider != "local" {
+			return nil, errors.New("--allow-exec-secrets is only allowed with the local backend")
+		}
+		state.AllowExecReferences(true)
+	}
+
+	// env: and file: references read local secrets, only for configurations nobody else can
+	// write unless the user opted in
+	if keyPrefix == "" {
+		state.AllowSecretReferences(selectedBackendProvider == "local" || viper.GetBool("allow-secret-references"))
+	}
+
 	switch selectedBackendProvider {
 	case "local":
 		return local.New()
 	case "manta":
 		// Triton Account
 		tritonAccount := ""
-		if viper.IsSet("triton_account") {
-			tritonAccount = viper.GetString("triton_account")
+		if viper.IsSet(keyPrefix + "triton_account") {
+			tritonAccount = viper.GetString(keyPrefix + "triton_account")
 		} else if nonInteractiveMode {
-			return nil, errors.New("triton_account must be specified")
+			return nil, errors.New(keyPrefix + "triton_account must be specified")
 		} else {
 			prompt := promptui.Prompt{
 				Label: "Triton Account Name",
@@ -74,10 +128,10 @@ func PromptForBackend() (backend.Backend, error) {
 
 		// Triton Key Path
 		rawTritonKeyPath := ""
-		if viper.IsSet("triton_key_path") {
-			rawTritonKeyPath = viper.GetString("triton_key_path")
+		if viper.IsSet(keyPrefix + "triton_key_path") {
+			rawTritonKeyPath = viper.GetString(keyPrefix + "triton_key_path")
 		} else if nonInteractiveMode {
-			return nil, errors.New("triton_key_path must be specified")
+			return nil, errors.New(keyPrefix + "triton_key_path must be specified")
 		} else {
 			prompt := promptui.Prompt{
 				Label: "Triton Key Path",
@@ -113,8 +167,8 @@ func PromptForBackend() (backend.Backend, error) {
 
 		// Triton Key ID
 		tritonKeyID := ""
-		if viper.IsSet("triton_key_id") {
-			tritonKeyID = viper.GetString("triton_key_id")
+		if viper.IsSet(keyPrefix + "triton_key_id") {
+			tritonKeyID = viper.GetString(keyPrefix + "triton_key_id")
 		} else {
 			keyID, err := GetPublicKeyFingerprintFromPrivateKey(tritonKeyPath)
 			if err != nil {
@@ -125,10 +179,10 @@ func PromptForBackend() (backend.Backend, error) {
 
 		// Triton URL
 		tritonURL := ""
-		if viper.IsSet("triton_url") {
-			tritonURL = viper.GetString("triton_url")
+		if viper.IsSet(keyPrefix + "triton_url") {
+			tritonURL = viper.GetString(keyPrefix + "triton_url")
 		} else if nonInteractiveMode {
-			return nil, errors.New("triton_url must be specified")
+			return nil, errors.New(keyPrefix + "triton_url must be specified")
 		} else {
 			prompt := promptui.Prompt{
 				Label:   "Triton URL",
@@ -144,10 +198,10 @@ func PromptForBackend() (backend.Backend, error) {
 
 		// Manta URL
 		mantaURL := ""
-		if viper.IsSet("manta_url") {
-			mantaURL = viper.GetString("manta_url")
+		if viper.IsSet(keyPrefix + "manta_url") {
+			mantaURL = viper.GetString(keyPrefix + "manta_url")
 		} else if nonInteractiveMode {
-			return nil, errors.New("manta_url must be specified")
+			return nil, errors.New(keyPrefix + "manta_url must be specified")
 		} else {
 			prompt := promptui.Prompt{
 				Label:   "Manta URL",
@@ -162,6 +216,120 @@ func PromptForBackend() (backend.Backend, error) {
 		}
 
 		return manta.New(tritonAccount, tritonKeyPath, tritonKeyID, tritonURL, mantaURL)
+	case "s3":
+		// S3 Access Key
+		s3AccessKey := ""
+		if viper.IsSet(keyPrefix + "s3_access_key") {
+			s3AccessKey = viper.GetString(keyPrefix + "s3_access_key")
+		} else if nonInteractiveMode {
+			return nil, errors.New(keyPrefix + "s3_access_key must be specified")
+		} else {
+			prompt := promptui.Prompt{
+				Label: "S3 Access Key",
+				Validate: func(input string) error {
+					if len(input) == 0 {
+						return errors.New("Invalid S3 Access Key")
+					}
+					return nil
+				},
+			}
+
+			result, err := prompt.Run()
+			if err != nil {
+				return nil, err
+			}
+			s3AccessKey = result
+		}
+
+		// S3 Secret Key
+		s3SecretKey := ""
+		if viper.IsSet(keyPrefix + "s3_secret_key") {
+			s3SecretKey = viper.GetString(keyPrefix + "s3_secret_key")
+		} else if nonInteractiveMode {
+			return nil, errors.New(keyPrefix + "s3_secret_key must be specified")
+		} else {
+			prompt := promptui.Prompt{
+				Label: "S3 Secret Key",
+				Mask:  '*',
+				Validate: func(input string) error {
+					if len(input) == 0 {
+						return errors.New("Invalid S3 Secret Key")
+					}
+					return nil
+				},
+			}
+
+			result, err := prompt.Run()
+			if err != nil {
+				return nil, err
+			}
+			s3SecretKey = result
+		}
+
+		// S3 Region
+		s3Region := ""
+		if viper.IsSet(keyPrefix + "s3_region") {
+			s3Region = viper.GetString(keyPrefix + "s3_region")
+		} else if nonInteractiveMode {
+			return nil, errors.New(keyPrefix + "s3_region must be specified")
+		} else {
+			prompt := promptui.Prompt{
+				Label:   "S3 Region",
+				Default: "us-east-1",
+			}
+
+			result, err := prompt.Run()
+			if err != nil {
+				return nil, err
+			}
+			s3Region = result
+		}
+
+		// S3 Endpoint, only needed for S3 compatible object stores (e.g. MinIO)
+		s3Endpoint := ""
+		if viper.IsSet(keyPrefix + "s3_endpoint") {
+			s3Endpoint = viper.GetString(keyPrefix + "s3_endpoint")
+		} else if !nonInteractiveMode {
+			prompt := promptui.Prompt{
+				Label:   "S3 Endpoint",
+				Default: "AWS",
+			}
+
+			result, err := prompt.Run()
+			if err != nil {
+				return nil, err
+			}
+
+			if result != "AWS" {
+				s3Endpoint = result
+			}
+		}
+
+		// S3 Bucket
+		s3Bucket := ""
+		if viper.IsSet(keyPrefix + "s3_bucket") {
+			s3Bucket = viper.GetString(keyPrefix + "s3_bucket")
+		} else if nonInteractiveMode {
+			return nil, errors.New(keyPrefix + "s3_bucket must be specified")
+		} else {
+			prompt := promptui.Prompt{
+				Label: "S3 Bucket",
+				Validate: func(input string) error {
+					if len(input) == 0 {
+						return errors.New("Invalid S3 Bucket")
+					}
+					return nil
+				},
+			}
+
+			result, err := prompt.Run()
+			if err != nil {
+				return nil, err
+			}
+			s3Bucket = result
+		}
+
+		return s3.New(s3AccessKey, s3SecretKey, s3Region, s3Endpoint, s3Bucket)
 	}
 
 	return nil, fmt.Errorf("Unsupported backend provider '%s'", selectedBackendProvider)
@@ -8,13 +8,13 @@ import (
 
 func TestBackendPromptWithUnsupportedBackendProviderNonInteractiveMode(t *testing.T) {
 	viper.Set("non-interactive", true)
-	viper.Set("backend_provider", "S3")
+	viper.Set("backend_provider", "GCS")
 
 	defer viper.Reset()
 
 	_,err:=PromptForBackend()
 
-	expected:= "Unsupported backend provider 'S3'"
+	expected:= "Unsupported backend provider 'GCS'"
 
 	if err.Error() != expected {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
@@ -105,4 +105,36 @@ func TestNoMantaURLForNonInteractiveMode (t *testing.T) {
 	if err.Error() != expected {
 		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
 	}
-}
\ No newline at end of file
+}
+func TestNoS3AccessKeyForNonInteractiveMode(t *testing.T) {
+	viper.Set("non-interactive", true)
+	viper.Set("backend_provider", "s3")
+
+	defer viper.Reset()
+
+	_, err := PromptForBackend()
+
+	expected := "s3_access_key must be specified"
+
+	if err.Error() != expected {
+		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
+	}
+}
+
+func TestNoS3BucketForNonInteractiveMode(t *testing.T) {
+	viper.Set("non-interactive", true)
+	viper.Set("backend_provider", "s3")
+	viper.Set("s3_access_key", "xyz")
+	viper.Set("s3_secret_key", "xyz")
+	viper.Set("s3_region", "us-east-1")
+
+	defer viper.Reset()
+
+	_, err := PromptForBackend()
+
+	expected := "s3_bucket must be specified"
+
+	if err.Error() != expected {
+		t.Errorf("Wrong output, expected %s, received %s", expected, err.Error())
+	}
+}
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"errors"
+	"io/ioutil"
+
+	"github.com/joyent/triton-kubernetes/state"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// ErrMissingSecretKey is returned when encrypted secrets have to be decrypted without a key.
+var ErrMissingSecretKey = errors.New("Configuration contains encrypted secrets, encryption_keyfile or encryption_passphrase must be specified")
+
+// GetSecretKey returns the key secrets in stored configurations are encrypted with,
+// given by `encryption_keyfile` or `encryption_passphrase`.
+// nil is returned if neither is set, in which case secrets are stored in plaintext.
+func GetSecretKey() (*state.SecretKey, error) {
+	return getSecretKey("encryption_keyfile", "encryption_passphrase")
+}
+
+// GetNewSecretKey returns the key to rotate to, given by `new_encryption_keyfile`
+// or `new_encryption_passphrase`.
+func GetNewSecretKey() (*state.SecretKey, error) {
+	key, err := getSecretKey("new_encryption_keyfile", "new_encryption_passphrase")
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("new_encryption_keyfile or new_encryption_passphrase must be specified")
+	}
+
+	return key, nil
+}
+
+// GetBundleSecretKey returns the key manager bundles are encrypted with, given by
+// `bundle_keyfile` or `bundle_passphrase`. nil is returned if neither is set.
+func GetBundleSecretKey() (*state.SecretKey, error) {
+	return getSecretKey("bundle_keyfile", "bundle_passphrase")
+}
+
+func getSecretKey(keyfileSetting, passphraseSetting string) (*state.SecretKey, error) {
+	if viper.IsSet(keyfileSetting) && viper.IsSet(passphraseSetting) {
+		return nil, errors.New("Only one of " + keyfileSetting + " and " + passphraseSetting + " can be specified")
+	}
+
+	if viper.IsSet(keyfileSetting) {
+		expandedKeyfilePath, err := homedir.Expand(viper.GetString(keyfileSetting))
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := ioutil.ReadFile(expandedKeyfilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return state.NewKeyfileSecretKey(content)
+	}
+
+	if viper.IsSet(passphraseSetting) {
+		return state.NewPassphraseSecretKey(viper.GetString(passphraseSetting))
+	}
+
+	return nil, nil
+}
+
+// DecryptSecrets decrypts the encrypted secrets of currentState in place with the key
+// given by `encryption_keyfile` or `encryption_passphrase`.
+func DecryptSecrets(currentState *state.State) error {
+	if !currentState.HasEncryptedSecrets() {
+		return nil
+	}
+
+	secretKey, err := GetSecretKey()
+	if err != nil {
+		return err
+	}
+	if secretKey == nil {
+		return ErrMissingSecretKey
+	}
+
+	return currentState.DecryptSecrets(secretKey)
+}
+
+// ResolveSecret returns the plaintext of a secret read from the configuration. Encrypted
+// values that aren't bound to a stored state value (see state.EncryptSecret) are decrypted
+// and secret references (env:, file:, exec:, literal:) are resolved. Callers only use the
+// plaintext, e.g. to call a cloud API, and keep storing the value as is. Secrets of a stored
+// state are decrypted with DecryptSecrets instead.
+func ResolveSecret(value string) (string, error) {
+	if state.IsEncryptedSecret(value) {
+		secretKey, err := GetSecretKey()
+		if err != nil {
+			return "", err
+		}
+		if secretKey == nil {
+			return "", ErrMissingSecretKey
+		}
+
+		value, err = state.DecryptSecret(secretKey, value, "")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return state.ResolveSecretReference(value)
+}
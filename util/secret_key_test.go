@@ -0,0 +1,79 @@
+package util
+
+import (
+	"os"
+	"testing"
+
+	"github.com/joyent/triton-kubernetes/state"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolveSecret(t *testing.T) {
+	viper.Set("encryption_passphrase", "correct horse")
+	defer viper.Reset()
+
+	os.Setenv("TK_TEST_SECRET", "from-env")
+	defer os.Unsetenv("TK_TEST_SECRET")
+
+	// As with the local backend
+	state.AllowSecretReferences(true)
+
+	key, err := GetSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := state.EncryptSecret(key, "hunter2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		encrypted:            "hunter2",
+		"env:TK_TEST_SECRET": "from-env",
+		"literal":            "literal",
+	}
+	for value, expected := range tests {
+		actual, err := ResolveSecret(value)
+		if err != nil {
+			t.Errorf("%s: %s", value, err)
+			continue
+		}
+		if actual != expected {
+			t.Errorf("Wrong output, expected %s, received %s", expected, actual)
+		}
+	}
+}
+
+func TestResolveSecretWithoutKey(t *testing.T) {
+	key, _ := state.NewPassphraseSecretKey("correct horse")
+	stateObj, _ := state.New("dev", []byte(`{"module":{"cluster-manager":{"rancher_admin_password":"hunter2"}}}`))
+	stateObj.EncryptSecrets(key)
+
+	_, err := ResolveSecret(stateObj.Get("module.cluster-manager.rancher_admin_password"))
+	if err != ErrMissingSecretKey {
+		t.Errorf("Wrong output, expected %v, received %v", ErrMissingSecretKey, err)
+	}
+}
+
+func TestDecryptSecrets(t *testing.T) {
+	viper.Set("encryption_passphrase", "correct horse")
+	defer viper.Reset()
+
+	key, err := GetSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateObj, _ := state.New("dev", []byte(`{"module":{"cluster-manager":{"rancher_admin_password":"hunter2"}}}`))
+	stateObj.EncryptSecrets(key)
+
+	err = DecryptSecrets(&stateObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := stateObj.Get("module.cluster-manager.rancher_admin_password"); password != "hunter2" {
+		t.Errorf("Wrong output, expected hunter2, received %s", password)
+	}
+}